@@ -4,15 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"time"
 
 	"3d-model-generator-backend/config"
 	"3d-model-generator-backend/internal/cache"
 	"3d-model-generator-backend/internal/evaluation"
+	"3d-model-generator-backend/internal/fetch"
 	"3d-model-generator-backend/internal/handlers"
+	"3d-model-generator-backend/internal/mail"
 	"3d-model-generator-backend/internal/middleware"
 	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/moderation"
+	"3d-model-generator-backend/internal/prompt"
 	"3d-model-generator-backend/internal/services"
+	"3d-model-generator-backend/internal/storage"
+	"3d-model-generator-backend/internal/urls"
 	"3d-model-generator-backend/pkg/tencentcloud"
 
 	"github.com/gin-gonic/gin"
@@ -27,6 +34,12 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if err := cfg.CheckSecrets(); err != nil {
+		if gin.Mode() == gin.ReleaseMode {
+			log.Fatalf("Refusing to start in release mode: %v", err)
+		}
+		log.Printf("Warning: %v, set JWT_SECRET before deploying", err)
+	}
 
 	// 初始化数据库
 	db := initDatabase(cfg.Database.DSN)
@@ -43,18 +56,174 @@ func main() {
 		log.Fatalf("Failed to initialize Tencent client: %v", err)
 	}
 
+	// 初始化存储；按内容哈希命名的上传图片公开访问，任务产物只能通过签名地址访问
+	fileStorage := storage.NewLocalStorage(cfg.Storage.Root, "/uploads")
+	urlSecret := cfg.Storage.URLSecret
+	if urlSecret == "" {
+		urlSecret = cfg.Auth.JWTSecret
+	}
+	fileSigner := storage.NewURLSigner(fileStorage, urlSecret, cfg.Storage.URLTTL,
+		[]string{"images/"}, []string{"results/", "derived/", "thumbnails/"})
+
+	// 初始化对外地址生成
+	urlBuilder, err := urls.NewBuilder(cfg.Server.PublicURL, defaultBaseURL(cfg.Server), cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Failed to initialize URL builder: %v", err)
+	}
+
+	// 初始化远程获取客户端
+	fetcher := fetch.New(fetch.Config{
+		Timeout:      cfg.Fetch.Timeout,
+		MaxRedirects: cfg.Fetch.MaxRedirects,
+		AllowPrivate: cfg.Fetch.AllowPrivate,
+	})
+
+	// 初始化内容审核
+	moderationService, err := initModeration(db, cfg.Moderation)
+	if err != nil {
+		log.Fatalf("Failed to initialize moderation: %v", err)
+	}
+
+	// 初始化提示词处理流水线
+	pipeline, err := initPromptPipeline(cfg.Prompt)
+	if err != nil {
+		log.Fatalf("Failed to initialize prompt pipeline: %v", err)
+	}
+
 	// 初始化服务
-	generationService := services.NewGenerationService(db, cacheService, tencentClient)
+	promptService := services.NewPromptService(db, pipeline, cfg.Prompt.Enhance)
+	modelService := services.NewModelService(db, fileStorage, fetcher, services.ModelOptions{
+		LODLevels:        cfg.Model.LODLevels,
+		ThumbnailSizes:   cfg.Model.ThumbnailSizes,
+		ThumbnailAngles:  cfg.Model.ThumbnailAngles,
+		ThumbnailPitch:   cfg.Model.ThumbnailPitch,
+		TurntableFrames:  cfg.Model.TurntableFrames,
+		TurntableColumns: cfg.Model.TurntableColumns,
+		BundleLicense:    cfg.Model.BundleLicense,
+	})
+	// 断点续传的临时文件保存在存储目录之外，不会通过/uploads对外提供
+	sessionStorage := storage.NewLocalStorage(cfg.Upload.SessionDir, "")
+	uploadService := services.NewUploadService(db, fileStorage, sessionStorage, cfg.Upload.SessionTTL, cfg.Upload.MaxOpenSessions)
+	generationService := services.NewGenerationService(db, cacheService, tencentClient, modelService, fileStorage, fetcher, urlBuilder, moderationService, promptService, int64(cfg.Fetch.MaxImageSize), cfg.Tencent.ImageMode)
+	retentionService := services.NewRetentionService(db, fileStorage, modelService, services.RetentionPolicy{
+		TTL: map[string]time.Duration{
+			services.ArtifactUploads:     cfg.Retention.Uploads,
+			services.ArtifactResults:     cfg.Retention.Results,
+			services.ArtifactDerivatives: cfg.Retention.Derivatives,
+			services.ArtifactThumbnails:  cfg.Retention.Thumbnails,
+		},
+		OrphanGrace: cfg.Retention.OrphanGrace,
+	})
 	evaluationService := evaluation.NewEvaluationService(db)
-	authService := services.NewAuthService(db, cfg.Auth.JWTSecret)
+	totpKey := cfg.Auth.TOTPEncryptionKey
+	if totpKey == "" {
+		totpKey = cfg.Auth.JWTSecret
+	}
+	twoFactorService, err := services.NewTwoFactorService(db, totpKey, cfg.Auth.TOTPIssuer)
+	if err != nil {
+		log.Fatalf("Failed to initialize two-factor service: %v", err)
+	}
+	keyEncryptionKey := cfg.Auth.JWTKeyEncryptionKey
+	if keyEncryptionKey == "" {
+		keyEncryptionKey = cfg.Auth.JWTSecret
+	}
+	keyring, err := services.NewKeyring(db, services.KeyringOptions{
+		Algorithm:        cfg.Auth.JWTAlgorithm,
+		Secret:           cfg.Auth.JWTSecret,
+		EncryptionKey:    keyEncryptionKey,
+		RotationInterval: cfg.Auth.JWTKeyRotation,
+		PublishAhead:     cfg.Auth.JWTKeyPublishAhead,
+		TokenLifetime:    cfg.Auth.TokenExpiry,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	authService := services.NewAuthService(db, keyring, twoFactorService, services.AuthOptions{
+		TokenExpiry:        cfg.Auth.TokenExpiry,
+		RefreshTokenExpiry: cfg.Auth.RefreshTokenExpiry,
+		Login: services.LoginPolicy{
+			MaxAttempts:     cfg.Auth.LoginMaxAttempts,
+			IPMaxAttempts:   cfg.Auth.LoginIPMaxAttempts,
+			Window:          cfg.Auth.LoginAttemptWindow,
+			LockoutDuration: cfg.Auth.LoginLockoutDuration,
+			DelayBase:       cfg.Auth.LoginDelayBase,
+			MaxDelay:        cfg.Auth.LoginMaxDelay,
+			EventRetention:  cfg.Auth.EventRetention,
+		},
+	})
+	apiKeyService := services.NewAPIKeyService(db)
+	adminService := services.NewAdminService(db)
+
+	// 将配置中已注册的邮箱提升为管理员
+	if promoted, err := adminService.BootstrapAdmins(context.Background(), cfg.Admin.Emails); err != nil {
+		log.Printf("Warning: Failed to bootstrap admins: %v", err)
+	} else if promoted > 0 {
+		log.Printf("Promoted %d configured user(s) to admin", promoted)
+	}
+
+	// 初始化邮件发送和账号验证
+	mailer, err := initMailer(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	accountService := services.NewAccountService(db, mailer, services.AccountOptions{
+		VerifyEmailURL:     urlBuilder.Absolute(nil, cfg.Mail.VerifyEmailURL),
+		ResetPasswordURL:   urlBuilder.Absolute(nil, cfg.Mail.ResetPasswordURL),
+		DeleteAccountURL:   urlBuilder.Absolute(nil, cfg.Mail.DeleteAccountURL),
+		VerificationExpiry: cfg.Mail.VerificationExpiry,
+		ResetExpiry:        cfg.Mail.ResetExpiry,
+		AdminEmails:        cfg.Admin.Emails,
+	})
+
+	// 初始化个人数据导出和账号注销
+	privacyService := services.NewPrivacyService(db, fileStorage, uploadService, modelService, retentionService, accountService, cacheService, twoFactorService, mailer, services.PrivacyOptions{
+		DeletionGrace: cfg.Privacy.DeletionGrace,
+	})
+
+	// 初始化单点登录，回调地址基于PUBLIC_URL生成
+	oidcProviders := make([]services.OIDCProviderConfig, 0, len(cfg.OIDC.Providers))
+	for _, p := range cfg.OIDC.Providers {
+		oidcProviders = append(oidcProviders, services.OIDCProviderConfig{
+			Name:           p.Name,
+			DisplayName:    p.DisplayName,
+			Issuer:         p.Issuer,
+			ClientID:       p.ClientID,
+			ClientSecret:   p.ClientSecret,
+			Scopes:         p.Scopes,
+			AllowedDomains: p.AllowedDomains,
+			AllowSignup:    p.AllowSignup,
+			RedirectURL:    urlBuilder.Absolute(nil, "/api/v1/auth/oidc/"+p.Name+"/callback"),
+		})
+	}
+	ssoService, err := services.NewSSOService(db, oidcProviders, cfg.Admin.Emails)
+	if err != nil {
+		log.Fatalf("Failed to initialize SSO service: %v", err)
+	}
 
 	// 初始化处理器
-	generationHandler := handlers.NewGenerationHandler(generationService)
+	generationHandler := handlers.NewGenerationHandler(generationService, uploadService, promptService, urlBuilder, fileSigner)
+	uploadHandler := handlers.NewUploadHandler(uploadService, urlBuilder)
 	evaluationHandler := handlers.NewEvaluationHandler(evaluationService)
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService, accountService)
+	modelHandler := handlers.NewModelHandler(modelService, urlBuilder, fileSigner)
+	storageHandler := handlers.NewStorageHandler(retentionService, fileStorage, fileSigner)
+	moderationHandler := handlers.NewModerationHandler(moderationService)
+	promptHandler := handlers.NewPromptHandler(promptService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	adminHandler := handlers.NewAdminHandler(adminService, fileSigner)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+	ssoHandler := handlers.NewSSOHandler(ssoService, authService, urlBuilder, cfg.OIDC.PostLoginURL)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+
+	// 启动存储定时清理
+	retentionService.Start(context.Background(), cfg.Retention.SweepInterval)
+	uploadService.Start(context.Background(), cfg.Upload.SessionSweepInterval)
+	authService.Start(context.Background(), time.Hour)
+	keyring.Start(context.Background(), time.Minute)
+	privacyService.Start(context.Background(), cfg.Privacy.DeletionSweepInterval)
 
 	// 初始化Gin
-	router := setupRouter(generationHandler, evaluationHandler, authHandler, authService, redisClient, cfg)
+	router := setupRouter(generationHandler, evaluationHandler, authHandler, modelHandler, storageHandler, uploadHandler, moderationHandler, promptHandler, apiKeyHandler, adminHandler, twoFactorHandler, ssoHandler, privacyHandler, authService, apiKeyService, redisClient, cfg)
 
 	// 启动服务器
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
@@ -76,6 +245,20 @@ func initDatabase(dsn string) *gorm.DB {
 	err = db.AutoMigrate(
 		&models.GenerationJob{},
 		&models.User{},
+		&models.Session{},
+		&models.AccountToken{},
+		&models.SigningKey{},
+		&models.AuthEvent{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.SSOState{},
+		&models.APIKey{},
+		&models.Upload{},
+		&models.UploadSession{},
+		&models.UploadSetView{},
+		&models.ModerationViolation{},
+		&models.PromptTemplate{},
+		&models.StylePreset{},
 		&models.Evaluation{},
 		&models.CacheEntry{},
 		&models.APIUsage{},
@@ -110,6 +293,78 @@ func initRedis(cfg config.RedisConfig) *redis.Client {
 	return client
 }
 
+func initModeration(db *gorm.DB, cfg config.ModerationConfig) (*services.ModerationService, error) {
+	rules := &moderation.RuleSet{}
+	if cfg.RulesFile != "" {
+		var err error
+		if rules, err = moderation.LoadRules(cfg.RulesFile); err != nil {
+			return nil, err
+		}
+	}
+	rules.AddTerms(moderation.DefaultCategory, cfg.Blocklist...)
+
+	var classifiers []moderation.ImageClassifier
+	if cfg.ClassifierURL != "" {
+		classifiers = append(classifiers, moderation.NewHTTPClassifier("http", cfg.ClassifierURL, cfg.ClassifierToken, cfg.ClassifierTimeout))
+	}
+	log.Printf("Moderation loaded %d rules and %d image classifiers", rules.Len(), len(classifiers))
+
+	return services.NewModerationService(db, rules, classifiers, services.ModerationPolicy{
+		ImageThreshold:   cfg.ImageThreshold,
+		FailClosed:       cfg.FailClosed,
+		SuspendThreshold: cfg.SuspendThreshold,
+		SuspendWindow:    cfg.SuspendWindow,
+	}), nil
+}
+
+// initMailer 按配置选择邮件驱动
+func initMailer(cfg config.MailConfig) (mail.Mailer, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return mail.LogMailer{}, nil
+	case "file":
+		log.Printf("Mail will be written to %s", cfg.Dir)
+		return mail.NewFileMailer(cfg.Dir, cfg.From), nil
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+			Timeout:  cfg.SMTPTimeout,
+		})
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// initPromptPipeline 按配置组装提示词处理流水线：翻译、关键词扩展，最后按档位截断
+func initPromptPipeline(cfg config.PromptConfig) (*prompt.Pipeline, error) {
+	var stages []prompt.Stage
+	if cfg.DictionaryFile != "" {
+		dictionary, err := prompt.LoadDictionary(cfg.DictionaryFile)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, prompt.TranslateStage{Translator: dictionary})
+		log.Printf("Prompt dictionary loaded %d entries", dictionary.Len())
+	}
+
+	expander := prompt.NewExpander(cfg.MinLength)
+	if cfg.ExpansionsFile != "" {
+		var err error
+		if expander, err = prompt.LoadExpander(cfg.ExpansionsFile, cfg.MinLength); err != nil {
+			return nil, err
+		}
+	}
+	stages = append(stages, expander, prompt.LengthStage{})
+
+	pipeline := prompt.NewPipeline(stages...)
+	log.Printf("Prompt pipeline stages: %v (enabled by default: %v)", pipeline.Stages(), cfg.Enhance)
+	return pipeline, nil
+}
+
 func initTencentClient(cfg config.TencentConfig) (*tencentcloud.Client, error) {
 	tencentConfig := tencentcloud.TencentConfig{
 		SecretId:  cfg.SecretId,
@@ -120,11 +375,31 @@ func initTencentClient(cfg config.TencentConfig) (*tencentcloud.Client, error) {
 	return tencentcloud.NewClient(tencentConfig)
 }
 
+// defaultBaseURL 未配置PUBLIC_URL时，不在请求上下文中生成链接所用的地址
+func defaultBaseURL(cfg config.ServerConfig) string {
+	host := cfg.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, cfg.Port)
+}
+
 func setupRouter(
 	generationHandler *handlers.GenerationHandler,
 	evaluationHandler *handlers.EvaluationHandler,
 	authHandler *handlers.AuthHandler,
+	modelHandler *handlers.ModelHandler,
+	storageHandler *handlers.StorageHandler,
+	uploadHandler *handlers.UploadHandler,
+	moderationHandler *handlers.ModerationHandler,
+	promptHandler *handlers.PromptHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	adminHandler *handlers.AdminHandler,
+	twoFactorHandler *handlers.TwoFactorHandler,
+	ssoHandler *handlers.SSOHandler,
+	privacyHandler *handlers.PrivacyHandler,
 	authService *services.AuthService,
+	apiKeyService *services.APIKeyService,
 	redisClient *redis.Client,
 	cfg *config.Config,
 ) *gin.Engine {
@@ -135,6 +410,11 @@ func setupRouter(
 
 	router := gin.New()
 
+	// 只信任配置的反向代理，否则客户端可以伪造X-Forwarded-For绕过按IP限流
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
 	// 健康检查 - 放在中间件之前，避免Redis依赖
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -144,6 +424,9 @@ func setupRouter(
 		})
 	})
 
+	// 其他服务定期获取公钥，不经过限流
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// 中间件
 	router.Use(middleware.Logger())
 	router.Use(middleware.Recovery())
@@ -164,45 +447,119 @@ func setupRouter(
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
-			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/login/2fa", authHandler.LoginTwoFactor)
+			auth.POST("/refresh", authHandler.Refresh)
+			// 访问令牌过期后仍可凭刷新令牌登出
+			auth.POST("/logout", middleware.OptionalAuthMiddleware(authService), authHandler.Logout)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/forgot-password", authHandler.ForgotPassword)
+			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.GET("/oidc/providers", ssoHandler.ListProviders)
+			auth.GET("/oidc/:provider/login", ssoHandler.Login)
+			auth.GET("/oidc/:provider/callback", ssoHandler.Callback)
+			auth.POST("/oidc/exchange", ssoHandler.Exchange)
 		}
 
 		// 需要认证的路由组
 		authenticated := v1.Group("")
-		authenticated.Use(middleware.AuthMiddleware(authService))
+		authenticated.Use(middleware.AuthMiddleware(authService, apiKeyService))
 		{
 			// 用户资料管理
-			profile := authenticated.Group("/auth")
+			profile := authenticated.Group("/auth", middleware.DenyAPIKey())
 			{
 				profile.GET("/profile", authHandler.GetProfile)
 				profile.PUT("/profile", authHandler.UpdateProfile)
 				profile.POST("/change-password", authHandler.ChangePassword)
+				profile.POST("/resend-verification", authHandler.ResendVerification)
+				profile.GET("/sessions", authHandler.ListSessions)
+				profile.DELETE("/sessions", authHandler.RevokeOtherSessions)
+				profile.DELETE("/sessions/:session_id", authHandler.RevokeSession)
+				profile.GET("/2fa", twoFactorHandler.GetStatus)
+				profile.POST("/2fa/setup", twoFactorHandler.Setup)
+				profile.POST("/2fa/enable", twoFactorHandler.Enable)
+				profile.POST("/2fa/disable", twoFactorHandler.Disable)
+				profile.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+				profile.GET("/identities", ssoHandler.ListIdentities)
+				profile.DELETE("/identities/:identity_id", ssoHandler.UnlinkIdentity)
+				profile.DELETE("/account", privacyHandler.DeleteAccount)
+				profile.POST("/account/restore", privacyHandler.CancelDeletion)
+				profile.GET("/export", privacyHandler.ExportData)
+			}
+
+			// API密钥管理，只能用登录后的JWT操作
+			apiKeys := authenticated.Group("/api-keys", middleware.DenyAPIKey())
+			{
+				apiKeys.GET("", apiKeyHandler.ListAPIKeys)
+				apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+				apiKeys.DELETE("/:key_id", apiKeyHandler.RevokeAPIKey)
 			}
 
 			// 生成相关路由
-			generation := authenticated.Group("/generate")
+			generation := authenticated.Group("/generate", middleware.RequireScope(services.ScopeGenerate))
+			if cfg.Mail.RequireVerified {
+				generation.Use(middleware.RequireVerifiedEmail())
+			}
 			{
 				generation.POST("/text", generationHandler.GenerateFromText)
+				generation.POST("/text/preview", generationHandler.PreviewTextPrompt)
 				generation.POST("/image", generationHandler.GenerateFromImage)
 				generation.POST("/uploaded-image", generationHandler.GenerateFromUploadedImage)
 			}
 
 			// 文件上传路由
-			upload := authenticated.Group("/upload")
+			upload := authenticated.Group("/upload", middleware.RequireScope(services.ScopeUploads))
 			{
-				upload.POST("/image", generationHandler.UploadImage)
+				upload.POST("/image", uploadHandler.UploadImage)
+			}
+
+			// 图片库和断点续传路由
+			uploads := authenticated.Group("/uploads", middleware.RequireScope(services.ScopeUploads))
+			{
+				uploads.GET("", uploadHandler.ListUploads)
+				uploads.GET("/sets/:set_id", uploadHandler.GetUploadSet)
+				uploads.GET("/:upload_id", uploadHandler.GetUpload)
+				uploads.GET("/:upload_id/preview", uploadHandler.PreviewUpload)
+				uploads.DELETE("/:upload_id", uploadHandler.DeleteUpload)
+				uploads.POST("/sessions", uploadHandler.CreateSession)
+				uploads.GET("/sessions/:session_id", uploadHandler.GetSession)
+				uploads.PATCH("/sessions/:session_id", uploadHandler.UploadChunk)
+				uploads.POST("/sessions/:session_id/complete", uploadHandler.CompleteSession)
+				uploads.DELETE("/sessions/:session_id", uploadHandler.AbortSession)
 			}
 
 			// 任务相关路由
-			jobs := authenticated.Group("/jobs")
+			jobs := authenticated.Group("/jobs", middleware.RequireScope(services.ScopeJobs))
 			{
 				jobs.GET("/:job_id", generationHandler.GetJobStatus)
 				jobs.GET("/:job_id/download", generationHandler.DownloadModel)
+				jobs.GET("/:job_id/thumbnails", modelHandler.GetThumbnails)
+				jobs.GET("/:job_id/thumbnails/:name", modelHandler.GetThumbnail)
+				jobs.POST("/:job_id/transform", modelHandler.TransformModel)
+				jobs.GET("/:job_id/bundle", modelHandler.DownloadBundle)
 				jobs.GET("", generationHandler.GetUserJobs)
 			}
 
+			// 提示词模板和风格预设路由
+			templates := authenticated.Group("/templates", middleware.RequireScope(services.ScopePrompts))
+			{
+				templates.GET("", promptHandler.ListTemplates)
+				templates.POST("", promptHandler.CreateTemplate)
+				templates.GET("/:template_id", promptHandler.GetTemplate)
+				templates.PUT("/:template_id", promptHandler.UpdateTemplate)
+				templates.DELETE("/:template_id", promptHandler.DeleteTemplate)
+				templates.POST("/:template_id/render", promptHandler.RenderTemplate)
+			}
+			presets := authenticated.Group("/presets", middleware.RequireScope(services.ScopePrompts))
+			{
+				presets.GET("", promptHandler.ListPresets)
+				presets.POST("", promptHandler.CreatePreset)
+				presets.GET("/:preset_id", promptHandler.GetPreset)
+				presets.PUT("/:preset_id", promptHandler.UpdatePreset)
+				presets.DELETE("/:preset_id", promptHandler.DeletePreset)
+			}
+
 			// 评估相关路由
-			evaluations := authenticated.Group("/evaluations")
+			evaluations := authenticated.Group("/evaluations", middleware.RequireScope(services.ScopeEvaluations))
 			{
 				evaluations.POST("", evaluationHandler.SubmitEvaluation)
 				evaluations.GET("/stats", evaluationHandler.GetEvaluationStats)
@@ -213,20 +570,55 @@ func setupRouter(
 			jobs.GET("/:job_id/evaluation", evaluationHandler.GetJobEvaluation)
 
 			// A/B测试路由
-			abTests := authenticated.Group("/ab-tests")
+			abTests := authenticated.Group("/ab-tests", middleware.RequireScope(services.ScopeEvaluations))
 			{
-				abTests.POST("", evaluationHandler.CreateABTest)
+				abTests.POST("", middleware.RequireRole(models.RoleOperator), evaluationHandler.CreateABTest)
 				abTests.GET("/:test_name/result", evaluationHandler.GetABTestResult)
 			}
 
 			// 统计路由
-			authenticated.GET("/statistics", generationHandler.GetStatistics)
+			authenticated.GET("/statistics", middleware.RequireScope(services.ScopeJobs), generationHandler.GetStatistics)
+
+			// 存储用量
+			authenticated.GET("/storage/usage", middleware.RequireScope(services.ScopeJobs), storageHandler.GetUsage)
+
+			// 管理后台路由，运营可以查看和维护内容，账号和存储清理等操作需要管理员
+			admin := authenticated.Group("/admin", middleware.DenyAPIKey(), middleware.RequireRole(models.RoleOperator))
+			{
+				admin.GET("/users", adminHandler.ListUsers)
+				admin.GET("/users/:user_id", adminHandler.GetUser)
+				admin.GET("/auth-events", adminHandler.ListAuthEvents)
+				admin.GET("/jobs", adminHandler.ListJobs)
+				admin.GET("/jobs/:job_id", adminHandler.GetJob)
+				admin.GET("/storage/usage", storageHandler.GetUsageByUser)
+				admin.GET("/retention/report", storageHandler.GetRetentionReport)
+				admin.GET("/moderation/violations", moderationHandler.ListViolations)
+				admin.POST("/templates", promptHandler.CreateGlobalTemplate)
+				admin.PUT("/templates/:template_id", promptHandler.UpdateGlobalTemplate)
+				admin.DELETE("/templates/:template_id", promptHandler.DeleteGlobalTemplate)
+				admin.POST("/presets", promptHandler.CreateGlobalPreset)
+				admin.PUT("/presets/:preset_id", promptHandler.UpdateGlobalPreset)
+				admin.DELETE("/presets/:preset_id", promptHandler.DeleteGlobalPreset)
+			}
+
+			adminOnly := admin.Group("", middleware.RequireRole(models.RoleAdmin))
+			{
+				adminOnly.PUT("/users/:user_id/role", adminHandler.SetUserRole)
+				adminOnly.POST("/users/:user_id/activate", adminHandler.ActivateUser)
+				adminOnly.POST("/users/:user_id/deactivate", adminHandler.DeactivateUser)
+				adminOnly.POST("/users/:user_id/reinstate", moderationHandler.ReinstateUser)
+				adminOnly.POST("/users/:user_id/logout", adminHandler.ForceLogout)
+				adminOnly.POST("/users/:user_id/unlock", adminHandler.UnlockLogin)
+				adminOnly.POST("/users/:user_id/2fa/reset", adminHandler.ResetTwoFactor)
+				adminOnly.POST("/retention/sweep", storageHandler.RunRetentionSweep)
+			}
 		}
 	}
 
-	// 静态文件服务（用于文档和上传文件）
+	// 文档静态文件；存储中的文件由ServeFile按公开或签名规则提供，不直接暴露存储目录
 	router.Static("/docs", "./docs")
-	router.Static("/uploads", "./uploads")
+	router.GET("/uploads/*filepath", storageHandler.ServeFile)
+	router.HEAD("/uploads/*filepath", storageHandler.ServeFile)
 
 	return router
 }
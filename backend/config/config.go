@@ -1,33 +1,53 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// DefaultJWTSecret 未设置JWT_SECRET时使用的占位值，只能用于本地开发
+const DefaultJWTSecret = "your-super-secret-jwt-key-change-this-in-production"
+
 type Config struct {
-	Server   ServerConfig
-	Tencent  TencentConfig
-	Redis    RedisConfig
-	Database DatabaseConfig
-	Cache    CacheConfig
-	Auth     AuthConfig
+	Server     ServerConfig
+	Tencent    TencentConfig
+	Redis      RedisConfig
+	Database   DatabaseConfig
+	Cache      CacheConfig
+	Auth       AuthConfig
+	Storage    StorageConfig
+	Upload     UploadConfig
+	Fetch      FetchConfig
+	Moderation ModerationConfig
+	Prompt     PromptConfig
+	Model      ModelConfig
+	Retention  RetentionConfig
+	Privacy    PrivacyConfig
+	Admin      AdminConfig
+	Mail       MailConfig
+	OIDC       OIDCConfig
 }
 
 type ServerConfig struct {
-	Port         string
-	Host         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Port           string
+	Host           string
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	PublicURL      string   // 对外访问的根地址，例如 https://3d.example.com，为空时根据请求推断
+	TrustedProxies []string // 可信反向代理的IP或CIDR，只采用这些代理传来的X-Forwarded-*头
 }
 
 type TencentConfig struct {
 	SecretId  string
 	SecretKey string
 	Region    string
+	ImageMode string // 图片提交方式：auto、url或base64
 }
 
 type RedisConfig struct {
@@ -46,8 +66,132 @@ type CacheConfig struct {
 }
 
 type AuthConfig struct {
-	JWTSecret    string
-	TokenExpiry  time.Duration
+	JWTSecret          string
+	JWTAlgorithm       string        // HS256（使用JWTSecret）、RS256或EdDSA（密钥对保存在数据库中，定时轮换）
+	TokenExpiry        time.Duration // 访问令牌有效期
+	RefreshTokenExpiry time.Duration // 刷新令牌有效期，每次刷新后重新计算
+
+	LoginMaxAttempts     int           // 同一邮箱在统计窗口内允许的登录失败次数
+	LoginIPMaxAttempts   int           // 同一IP在统计窗口内允许的登录失败次数
+	LoginAttemptWindow   time.Duration // 登录失败次数的统计窗口
+	LoginLockoutDuration time.Duration // 达到上限后的锁定时长
+	LoginDelayBase       time.Duration // 连续失败时的起始响应延迟，每次翻倍
+	LoginMaxDelay        time.Duration // 响应延迟上限
+	EventRetention       time.Duration // 认证审计事件保留时长
+
+	TOTPEncryptionKey string // 加密TOTP密钥的服务端密钥，为空时使用JWTSecret；设置后不能更改，否则已开启的两步验证失效
+	TOTPIssuer        string // 验证器应用中显示的服务名称
+
+	JWTKeyRotation      time.Duration // RS256/EdDSA签名密钥的轮换周期
+	JWTKeyPublishAhead  time.Duration // 新密钥在启用之前通过JWKS公开的时长
+	JWTKeyEncryptionKey string        // 加密数据库中签名私钥的服务端密钥，为空时使用JWTSecret
+}
+
+type StorageConfig struct {
+	Root      string
+	URLSecret string        // 签名私有文件地址的服务端密钥，为空时使用JWTSecret；更改后已签发的地址失效
+	URLTTL    time.Duration // 私有文件签名地址的有效期
+}
+
+type UploadConfig struct {
+	SessionDir           string        // 断点续传临时文件目录，不能位于STORAGE_ROOT之内
+	SessionTTL           time.Duration // 断点续传会话无活动后的过期时间
+	SessionSweepInterval time.Duration // 过期会话清理间隔
+	MaxOpenSessions      int           // 每个用户同时未完成的会话数上限，0表示不限制
+}
+
+// FetchConfig 获取用户提供的远程地址（输入图片等）时的限制
+type FetchConfig struct {
+	Timeout      time.Duration
+	MaxRedirects int
+	MaxImageSize int  // 远程图片的最大字节数
+	AllowPrivate bool // 允许访问内网地址，仅用于本地开发
+}
+
+// ModerationConfig 内容审核
+type ModerationConfig struct {
+	RulesFile         string   // 屏蔽词和正则规则文件，格式见 moderation.ParseRules
+	Blocklist         []string // 额外的屏蔽词
+	ClassifierURL     string   // 图片审核服务地址，为空时不审核图片
+	ClassifierToken   string
+	ClassifierTimeout time.Duration
+	ImageThreshold    float64 // 图片分类置信度阈值
+	FailClosed        bool    // 图片审核服务不可用时拒绝请求
+	SuspendThreshold  int     // 窗口内违规次数达到该值时自动封禁账号，0表示不封禁
+	SuspendWindow     time.Duration
+}
+
+// PromptConfig 提交前的提示词处理流水线
+type PromptConfig struct {
+	Enhance        bool   // 请求未指定enhance时是否默认启用翻译和扩展
+	DictionaryFile string // 英译中词典，格式见 prompt.ParseDictionary，为空时不翻译
+	ExpansionsFile string // 关键词扩展规则，格式见 prompt.ParseExpander，为空时不扩展
+	MinLength      int    // 短于该字符数的提示词追加默认描述词
+}
+
+type ModelConfig struct {
+	LODLevels        []int // LOD面数百分比，例如 50,25,10
+	ThumbnailSizes   []int // 缩略图边长（像素）
+	ThumbnailAngles  []int // 缩略图水平视角（度）
+	ThumbnailPitch   int   // 缩略图俯仰角（度）
+	TurntableFrames  int   // 转台精灵图帧数，0表示不生成
+	TurntableColumns int
+	BundleLicense    string
+}
+
+// RetentionConfig 存储保留策略，保留时长为0表示永久保留
+type RetentionConfig struct {
+	Uploads       time.Duration // 上传的输入图片
+	Results       time.Duration // 镜像的生成结果
+	Derivatives   time.Duration // LOD、变换等派生模型
+	Thumbnails    time.Duration // 缩略图
+	OrphanGrace   time.Duration // 未被任何任务引用的文件在此时长后视为孤儿
+	SweepInterval time.Duration // 定时清理间隔，0表示不启动定时清理
+}
+
+// PrivacyConfig 账号注销
+type PrivacyConfig struct {
+	DeletionGrace         time.Duration // 申请注销到删除数据的宽限期，0表示立即删除
+	DeletionSweepInterval time.Duration // 检查到期注销申请的间隔
+}
+
+type AdminConfig struct {
+	Emails []string // 管理员邮箱
+}
+
+type MailConfig struct {
+	Driver       string // log（写入日志）、file（保存为.eml文件）或smtp
+	From         string // 发件人，例如 "3D模型生成 <noreply@example.com>"
+	Dir          string // file驱动的保存目录
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPTimeout  time.Duration
+
+	VerifyEmailURL     string        // 邮箱验证页面，站内路径或绝对地址
+	ResetPasswordURL   string        // 密码重置页面，站内路径或绝对地址
+	DeleteAccountURL   string        // 确认注销页面（未设置密码的账号使用），站内路径或绝对地址
+	VerificationExpiry time.Duration // 验证链接有效期
+	ResetExpiry        time.Duration // 重置链接有效期
+	RequireVerified    bool          // 未验证邮箱的用户不能创建生成任务
+}
+
+type OIDCConfig struct {
+	Providers    []OIDCProvider
+	PostLoginURL string // 前端的登录完成页面，回调后附带login_code重定向到此；为空时回调接口直接返回令牌
+}
+
+// OIDCProvider 通过OIDC_PROVIDERS列出标识，每个提供方的配置以OIDC_<标识>_为前缀
+type OIDCProvider struct {
+	Name           string
+	DisplayName    string
+	Issuer         string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	AllowedDomains []string // 允许的邮箱域名，为空时不限制
+	AllowSignup    bool     // 邮箱未注册时自动创建账号
 }
 
 func Load() (*Config, error) {
@@ -58,15 +202,18 @@ func Load() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port:         getEnv("SERVER_PORT", "8080"),
-			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
-			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
-			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Host:           getEnv("SERVER_HOST", "0.0.0.0"),
+			ReadTimeout:    getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:   getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			PublicURL:      getEnv("PUBLIC_URL", ""),
+			TrustedProxies: getListEnv("TRUSTED_PROXIES", nil),
 		},
 		Tencent: TencentConfig{
 			SecretId:  getEnv("TENCENT_SECRET_ID", ""),
 			SecretKey: getEnv("TENCENT_SECRET_KEY", ""),
 			Region:    getEnv("TENCENT_REGION", "ap-beijing"),
+			ImageMode: getEnv("TENCENT_IMAGE_MODE", "auto"),
 		},
 		Redis: RedisConfig{
 			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
@@ -81,14 +228,158 @@ func Load() (*Config, error) {
 			CleanupInterval:   getDurationEnv("CACHE_CLEANUP_INTERVAL", 1*time.Hour),
 		},
 		Auth: AuthConfig{
-			JWTSecret:   getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
-			TokenExpiry: getDurationEnv("TOKEN_EXPIRY", 24*time.Hour),
+			JWTSecret:          getEnv("JWT_SECRET", DefaultJWTSecret),
+			JWTAlgorithm:       getEnv("JWT_ALGORITHM", "HS256"),
+			TokenExpiry:        getDurationEnv("TOKEN_EXPIRY", 15*time.Minute),
+			RefreshTokenExpiry: getDurationEnv("REFRESH_TOKEN_EXPIRY", 30*24*time.Hour),
+
+			LoginMaxAttempts:     getIntEnv("LOGIN_MAX_ATTEMPTS", 5),
+			LoginIPMaxAttempts:   getIntEnv("LOGIN_IP_MAX_ATTEMPTS", 20),
+			LoginAttemptWindow:   getDurationEnv("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+			LoginLockoutDuration: getDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			LoginDelayBase:       getDurationEnv("LOGIN_DELAY_BASE", 500*time.Millisecond),
+			LoginMaxDelay:        getDurationEnv("LOGIN_MAX_DELAY", 4*time.Second),
+			EventRetention:       getDurationEnv("AUTH_EVENT_RETENTION", 90*24*time.Hour),
+
+			TOTPEncryptionKey: getEnv("TOTP_ENCRYPTION_KEY", ""),
+			TOTPIssuer:        getEnv("TOTP_ISSUER", "3D Model Generator"),
+
+			JWTKeyRotation:      getDurationEnv("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+			JWTKeyPublishAhead:  getDurationEnv("JWT_KEY_PUBLISH_AHEAD", time.Hour),
+			JWTKeyEncryptionKey: getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+		},
+		Storage: StorageConfig{
+			Root:      getEnv("STORAGE_ROOT", "uploads"),
+			URLSecret: getEnv("STORAGE_URL_SECRET", ""),
+			URLTTL:    getDurationEnv("STORAGE_URL_TTL", time.Hour),
+		},
+		Upload: UploadConfig{
+			SessionDir:           getEnv("UPLOAD_SESSION_DIR", "upload-sessions"),
+			SessionTTL:           getDurationEnv("UPLOAD_SESSION_TTL", 24*time.Hour),
+			SessionSweepInterval: getDurationEnv("UPLOAD_SESSION_SWEEP_INTERVAL", time.Hour),
+			MaxOpenSessions:      getIntEnv("UPLOAD_MAX_OPEN_SESSIONS", 10),
+		},
+		Fetch: FetchConfig{
+			Timeout:      getDurationEnv("FETCH_TIMEOUT", 60*time.Second),
+			MaxRedirects: getIntEnv("FETCH_MAX_REDIRECTS", 3),
+			MaxImageSize: getIntEnv("FETCH_MAX_IMAGE_SIZE", 20*1024*1024),
+			AllowPrivate: getBoolEnv("FETCH_ALLOW_PRIVATE", false),
+		},
+		Moderation: ModerationConfig{
+			RulesFile:         getEnv("MODERATION_RULES_FILE", ""),
+			Blocklist:         getListEnv("MODERATION_BLOCKLIST", nil),
+			ClassifierURL:     getEnv("MODERATION_CLASSIFIER_URL", ""),
+			ClassifierToken:   getEnv("MODERATION_CLASSIFIER_TOKEN", ""),
+			ClassifierTimeout: getDurationEnv("MODERATION_CLASSIFIER_TIMEOUT", 10*time.Second),
+			ImageThreshold:    getFloatEnv("MODERATION_IMAGE_THRESHOLD", 0.8),
+			FailClosed:        getBoolEnv("MODERATION_FAIL_CLOSED", false),
+			SuspendThreshold:  getIntEnv("MODERATION_SUSPEND_THRESHOLD", 5),
+			SuspendWindow:     getDurationEnv("MODERATION_SUSPEND_WINDOW", 24*time.Hour),
+		},
+		Prompt: PromptConfig{
+			Enhance:        getBoolEnv("PROMPT_ENHANCE", false),
+			DictionaryFile: getEnv("PROMPT_DICTIONARY_FILE", ""),
+			ExpansionsFile: getEnv("PROMPT_EXPANSIONS_FILE", ""),
+			MinLength:      getIntEnv("PROMPT_MIN_LENGTH", 10),
+		},
+		Model: ModelConfig{
+			LODLevels:        getIntListEnv("MODEL_LOD_LEVELS", []int{50, 25, 10}),
+			ThumbnailSizes:   getIntListEnv("THUMBNAIL_SIZES", []int{256, 512}),
+			ThumbnailAngles:  getIntListEnv("THUMBNAIL_ANGLES", []int{30}),
+			ThumbnailPitch:   getIntEnv("THUMBNAIL_PITCH", 20),
+			TurntableFrames:  getIntEnv("TURNTABLE_FRAMES", 0),
+			TurntableColumns: getIntEnv("TURNTABLE_COLUMNS", 6),
+			BundleLicense:    getEnv("BUNDLE_LICENSE", "Generated with Tencent Cloud Hunyuan 3D. Use is subject to the Tencent Cloud Hunyuan 3D service terms."),
+		},
+		Retention: RetentionConfig{
+			Uploads:       getDurationEnv("RETENTION_UPLOADS", 30*24*time.Hour),
+			Results:       getDurationEnv("RETENTION_RESULTS", 90*24*time.Hour),
+			Derivatives:   getDurationEnv("RETENTION_DERIVATIVES", 30*24*time.Hour),
+			Thumbnails:    getDurationEnv("RETENTION_THUMBNAILS", 90*24*time.Hour),
+			OrphanGrace:   getDurationEnv("RETENTION_ORPHAN_GRACE", 24*time.Hour),
+			SweepInterval: getDurationEnv("RETENTION_SWEEP_INTERVAL", 6*time.Hour),
+		},
+		Privacy: PrivacyConfig{
+			DeletionGrace:         getDurationEnv("ACCOUNT_DELETION_GRACE", 7*24*time.Hour),
+			DeletionSweepInterval: getDurationEnv("ACCOUNT_DELETION_SWEEP_INTERVAL", time.Hour),
+		},
+		Admin: AdminConfig{
+			Emails: getListEnv("ADMIN_EMAILS", nil),
+		},
+		Mail: MailConfig{
+			Driver:             getEnv("MAIL_DRIVER", "log"),
+			From:               getEnv("MAIL_FROM", "noreply@localhost"),
+			Dir:                getEnv("MAIL_DIR", "mail"),
+			SMTPHost:           getEnv("SMTP_HOST", ""),
+			SMTPPort:           getIntEnv("SMTP_PORT", 587),
+			SMTPUsername:       getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
+			SMTPTimeout:        getDurationEnv("SMTP_TIMEOUT", 10*time.Second),
+			VerifyEmailURL:     getEnv("VERIFY_EMAIL_URL", "/verify-email"),
+			ResetPasswordURL:   getEnv("RESET_PASSWORD_URL", "/reset-password"),
+			DeleteAccountURL:   getEnv("DELETE_ACCOUNT_URL", "/delete-account"),
+			VerificationExpiry: getDurationEnv("EMAIL_VERIFICATION_EXPIRY", 48*time.Hour),
+			ResetExpiry:        getDurationEnv("PASSWORD_RESET_EXPIRY", time.Hour),
+			RequireVerified:    getBoolEnv("REQUIRE_VERIFIED_EMAIL", false),
+		},
+		OIDC: OIDCConfig{
+			Providers:    loadOIDCProviders(),
+			PostLoginURL: getEnv("OIDC_POST_LOGIN_URL", ""),
 		},
 	}
 
+	// 断点续传的临时文件未经校验，不能放在对外提供的存储目录中
+	if within(config.Upload.SessionDir, config.Storage.Root) {
+		return nil, errors.New("UPLOAD_SESSION_DIR must not be inside STORAGE_ROOT")
+	}
+
 	return config, nil
 }
 
+// within 判断dir是否为root或位于root之下
+func within(dir, root string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absRoot, absDir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CheckSecrets 默认的JWT密钥被实际使用时返回错误：HS256签名，或作为TOTP密钥、签名私钥的加密密钥、文件地址的签名密钥
+func (c *Config) CheckSecrets() error {
+	if c.Auth.JWTSecret != DefaultJWTSecret {
+		return nil
+	}
+	if c.Auth.JWTAlgorithm == "HS256" || c.Auth.TOTPEncryptionKey == "" || c.Auth.JWTKeyEncryptionKey == "" || c.Storage.URLSecret == "" {
+		return errors.New("JWT_SECRET is not set and the built-in placeholder secret is in use")
+	}
+	return nil
+}
+
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range getListEnv("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:           name,
+			DisplayName:    getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:         getEnv(prefix+"ISSUER", ""),
+			ClientID:       getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:   getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:         getListEnv(prefix+"SCOPES", nil),
+			AllowedDomains: getListEnv(prefix+"ALLOWED_DOMAINS", nil),
+			AllowSignup:    getBoolEnv(prefix+"ALLOW_SIGNUP", false),
+		})
+	}
+	return providers
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -105,6 +396,60 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func getIntListEnv(key string, defaultValue []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		intValue, err := strconv.Atoi(part)
+		if err != nil {
+			return defaultValue
+		}
+		result = append(result, intValue)
+	}
+	return result
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,228 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidURL         = errors.New("invalid url")
+	ErrForbiddenAddress   = errors.New("destination address is not allowed")
+	ErrTooManyRedirects   = errors.New("too many redirects")
+	ErrTooLarge           = errors.New("response body too large")
+	ErrUnexpectedStatus   = errors.New("unexpected response status")
+	ErrUnsupportedContent = errors.New("unsupported content type")
+)
+
+// deniedPrefixes 除标准库已识别的私有/回环/链路本地地址之外，还需要拒绝的地址段
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"),   // 运营商级NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF协议分配
+	netip.MustParsePrefix("192.0.2.0/24"),    // 文档示例
+	netip.MustParsePrefix("198.18.0.0/15"),   // 基准测试
+	netip.MustParsePrefix("198.51.100.0/24"), // 文档示例
+	netip.MustParsePrefix("203.0.113.0/24"),  // 文档示例
+	netip.MustParsePrefix("240.0.0.0/4"),     // 保留地址及广播
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64，可能映射到内网IPv4
+	netip.MustParsePrefix("2001:db8::/32"),   // 文档示例
+}
+
+// Config 远程获取配置
+type Config struct {
+	Timeout      time.Duration // 整个请求（含重定向和读取响应体）的超时
+	MaxRedirects int
+	AllowPrivate bool // 仅用于本地开发，允许访问内网地址
+}
+
+// Response 获取结果
+type Response struct {
+	Data        []byte
+	ContentType string
+	URL         string // 重定向后的最终地址
+}
+
+// Fetcher 用于获取用户提供的远程地址的HTTP客户端
+// 在建立连接时（DNS解析之后）检查目标IP，防止通过域名解析或重定向访问内网服务
+type Fetcher struct {
+	client *http.Client
+	config Config
+}
+
+func New(config Config) *Fetcher {
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !config.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !IsPublicAddr(addr) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil, // 不走环境变量中的代理，否则无法检查真实目标地址
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxIdleConns:          20,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	maxRedirects := config.MaxRedirects
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return ErrTooManyRedirects
+			}
+			return checkURL(req.URL)
+		},
+	}
+
+	return &Fetcher{client: client, config: config}
+}
+
+// Fetch 获取远程内容，maxBytes限制响应体大小，contentTypes为允许的MIME类型前缀（如"image/"），为空时不限制
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, maxBytes int64, contentTypes ...string) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	// 连接和重定向检查返回的哨兵错误会被url.Error包装，调用方可以用errors.Is判断
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	if maxBytes > 0 && resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+
+	reader := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		reader = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrTooLarge, maxBytes)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	// 很多对象存储不设置准确的类型，此时根据内容判断
+	if contentType == "" || contentType == "application/octet-stream" || contentType == "binary/octet-stream" {
+		contentType = strings.SplitN(http.DetectContentType(data), ";", 2)[0]
+	}
+	if !allowedType(contentType, contentTypes) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContent, contentType)
+	}
+
+	return &Response{
+		Data:        data,
+		ContentType: contentType,
+		URL:         resp.Request.URL.String(),
+	}, nil
+}
+
+// IsPublicAddr 判断地址是否为可以访问的公网地址
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPublicURL 判断地址能否被外部服务直接访问：必须是http(s)地址，且主机名解析出的所有IP都是公网地址
+func IsPublicURL(ctx context.Context, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || checkURL(u) != nil {
+		return false
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		if !IsPublicAddr(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkURL 只允许http和https，且不允许在URL中携带用户名密码
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme must be http or https", ErrInvalidURL)
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in url are not allowed", ErrInvalidURL)
+	}
+	return nil
+}
+
+func allowedType(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, prefix := range allowed {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+	"3d-model-generator-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	adminService *services.AdminService
+	files        *storage.URLSigner
+}
+
+func NewAdminHandler(adminService *services.AdminService, signer *storage.URLSigner) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		files:        signer,
+	}
+}
+
+// ListUsers 获取用户列表（运营及以上）
+// @Summary 获取用户列表
+// @Description 分页获取用户，按注册时间倒序，可按邮箱/名称、角色和启用状态筛选
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "邮箱或名称关键字"
+// @Param role query string false "角色" Enums(user, operator, admin)
+// @Param active query bool false "是否启用"
+// @Param limit query int false "限制数量" default(50)
+// @Param offset query int false "偏移量" default(0)
+// @Success 200 {object} models.UserListResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/users [get]
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	limit, offset := pageParams(c)
+	filter := services.UserFilter{
+		Query: c.Query("q"),
+		Role:  c.Query("role"),
+	}
+	if raw := c.Query("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid user filter",
+				Message: "active must be true or false",
+			})
+			return
+		}
+		filter.Active = &active
+	}
+
+	users, total, err := h.adminService.ListUsers(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	if users == nil {
+		users = []models.User{}
+	}
+	c.JSON(http.StatusOK, models.UserListResponse{
+		Users:  users,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// GetUser 获取用户详情（运营及以上）
+// @Summary 获取用户详情
+// @Description 获取任意用户的资料，包括已停用的用户
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "用户ID"
+// @Success 200 {object} models.User
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id} [get]
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, err := h.adminService.GetUser(c.Request.Context(), c.Param("user_id"))
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// SetUserRole 修改用户角色（管理员）
+// @Summary 修改用户角色
+// @Description 不能修改自己的角色，也不能降级最后一个有效的管理员
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "用户ID"
+// @Param request body models.RoleRequest true "新角色"
+// @Success 200 {object} models.User
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id}/role [put]
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.adminService.SetRole(c.Request.Context(), c.GetString("user_id"), c.Param("user_id"), req.Role)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ActivateUser 启用账号（管理员）
+// @Summary 启用账号
+// @Description 重新启用被停用的账号，不处理审核违规记录，封禁的用户请使用reinstate
+// @Tags Admin
+// @Security BearerAuth
+// @Param user_id path string true "用户ID"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id}/activate [post]
+func (h *AdminHandler) ActivateUser(c *gin.Context) {
+	h.setUserActive(c, true)
+}
+
+// DeactivateUser 停用账号（管理员）
+// @Summary 停用账号
+// @Description 停用账号并立即使其已签发的令牌和所有会话失效
+// @Tags Admin
+// @Security BearerAuth
+// @Param user_id path string true "用户ID"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id}/deactivate [post]
+func (h *AdminHandler) DeactivateUser(c *gin.Context) {
+	h.setUserActive(c, false)
+}
+
+func (h *AdminHandler) setUserActive(c *gin.Context, active bool) {
+	if err := h.adminService.SetUserActive(c.Request.Context(), c.GetString("user_id"), c.Param("user_id"), active); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ForceLogout 强制用户下线（管理员）
+// @Summary 强制用户下线
+// @Description 使用户已签发的访问令牌立即失效，并撤销所有会话
+// @Tags Admin
+// @Security BearerAuth
+// @Param user_id path string true "用户ID"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id}/logout [post]
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	if err := h.adminService.ForceLogout(c.Request.Context(), c.Param("user_id")); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ResetTwoFactor 重置两步验证（管理员）
+// @Summary 重置两步验证
+// @Description 用户丢失验证器和恢复码时关闭其两步验证，已签发的令牌和所有会话同时失效；不能重置自己的两步验证
+// @Tags Admin
+// @Security BearerAuth
+// @Param user_id path string true "用户ID"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id}/2fa/reset [post]
+func (h *AdminHandler) ResetTwoFactor(c *gin.Context) {
+	if err := h.adminService.ResetTwoFactor(c.Request.Context(), c.GetString("user_id"), c.Param("user_id")); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockLogin 解除登录锁定（管理员）
+// @Summary 解除登录锁定
+// @Description 清除用户邮箱的登录失败计数；按IP的锁定不受影响，到期后自动解除
+// @Tags Admin
+// @Security BearerAuth
+// @Param user_id path string true "用户ID"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id}/unlock [post]
+func (h *AdminHandler) UnlockLogin(c *gin.Context) {
+	if err := h.adminService.UnlockLogin(c.Request.Context(), c.GetString("user_id"), c.Param("user_id")); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListAuthEvents 获取认证审计事件（运营及以上）
+// @Summary 获取认证审计事件
+// @Description 分页获取登录成功、失败、锁定等事件，按时间倒序
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "事件类型" Enums(login_succeeded, login_failed, login_blocked, account_locked, login_unlocked)
+// @Param user_id query string false "用户ID"
+// @Param email query string false "邮箱"
+// @Param ip query string false "IP"
+// @Param limit query int false "限制数量" default(50)
+// @Param offset query int false "偏移量" default(0)
+// @Success 200 {object} models.AuthEventListResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/auth-events [get]
+func (h *AdminHandler) ListAuthEvents(c *gin.Context) {
+	limit, offset := pageParams(c)
+	filter := services.AuthEventFilter{
+		Type:   c.Query("type"),
+		UserID: c.Query("user_id"),
+		Email:  c.Query("email"),
+		IP:     c.Query("ip"),
+	}
+
+	events, total, err := h.adminService.ListAuthEvents(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	if events == nil {
+		events = []models.AuthEvent{}
+	}
+	c.JSON(http.StatusOK, models.AuthEventListResponse{
+		Events: events,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// ListJobs 获取所有用户的任务（运营及以上）
+// @Summary 获取所有用户的任务
+// @Description 分页获取任务，按创建时间倒序，可按用户和状态筛选
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query string false "用户ID"
+// @Param status query string false "任务状态"
+// @Param limit query int false "限制数量" default(50)
+// @Param offset query int false "偏移量" default(0)
+// @Success 200 {object} models.JobListResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/jobs [get]
+func (h *AdminHandler) ListJobs(c *gin.Context) {
+	limit, offset := pageParams(c)
+
+	jobs, total, err := h.adminService.ListJobs(c.Request.Context(), c.Query("user_id"), c.Query("status"), limit, offset)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	if jobs == nil {
+		jobs = []models.GenerationJob{}
+	}
+	for i := range jobs {
+		signJobFiles(h.files, &jobs[i])
+	}
+	c.JSON(http.StatusOK, models.JobListResponse{
+		Jobs:   jobs,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// GetJob 获取任意用户的任务详情（运营及以上）
+// @Summary 获取任务详情
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param job_id path string true "任务ID"
+// @Success 200 {object} models.GenerationJob
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/jobs/{job_id} [get]
+func (h *AdminHandler) GetJob(c *gin.Context) {
+	job, err := h.adminService.GetJob(c.Request.Context(), c.Param("job_id"))
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	signJobFiles(h.files, job)
+	c.JSON(http.StatusOK, job)
+}
+
+// pageParams 解析分页参数，非法值使用默认值
+func pageParams(c *gin.Context) (limit, offset int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "User not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Job not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Last admin",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrSelfModification), errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvalidUserFilter):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+	}
+}
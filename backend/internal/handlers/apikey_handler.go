@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKey 创建API密钥
+// @Summary 创建API密钥
+// @Description 创建用于程序化访问的密钥，完整密钥只在响应中返回一次。可用的权限范围：generate、jobs、uploads、evaluations、prompts
+// @Tags APIKey
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.APIKeyRequest true "密钥名称、权限范围和过期时间"
+// @Success 201 {object} models.APIKeyCreateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	key, err := h.apiKeyService.Create(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+// ListAPIKeys 获取API密钥
+// @Summary 获取API密钥
+// @Description 获取当前用户未撤销的密钥，只包含前缀，不包含完整密钥
+// @Tags APIKey
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIKeyListResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.List(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	c.JSON(http.StatusOK, models.APIKeyListResponse{APIKeys: keys})
+}
+
+// RevokeAPIKey 撤销API密钥
+// @Summary 撤销API密钥
+// @Description 撤销后使用该密钥的请求立即被拒绝
+// @Tags APIKey
+// @Security BearerAuth
+// @Param key_id path string true "密钥ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/api-keys/{key_id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	if err := h.apiKeyService.Revoke(c.Request.Context(), c.GetString("user_id"), c.Param("key_id")); err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "API key not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidScope), errors.Is(err, services.ErrInvalidExpiry), errors.Is(err, services.ErrTooManyAPIKeys):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid API key request",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 
 	"3d-model-generator-backend/internal/models"
 	"3d-model-generator-backend/internal/services"
@@ -10,18 +13,20 @@ import (
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService    *services.AuthService
+	accountService *services.AccountService
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, accountService *services.AccountService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:    authService,
+		accountService: accountService,
 	}
 }
 
 // Register 用户注册
 // @Summary 用户注册
-// @Description 使用邮箱和密码注册新用户
+// @Description 使用邮箱和密码注册新用户，注册后向邮箱发送验证链接
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -49,7 +54,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Register(&req)
+	response, err := h.authService.Register(&req, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "registration_failed",
@@ -58,12 +63,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// 验证邮件发送失败不影响注册，用户可以稍后重新发送
+	if err := h.accountService.SendVerification(c.Request.Context(), response.User.ID); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", response.User.ID, err)
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
 // Login 用户登录
 // @Summary 用户登录
-// @Description 使用邮箱和密码登录
+// @Description 使用邮箱和密码登录。同一邮箱或IP连续失败次数过多时暂时锁定，返回429和Retry-After。
+// @Description 开启两步验证的用户返回two_factor_required和challenge_token（models.TwoFactorChallenge），需调用/auth/login/2fa完成登录
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -71,6 +82,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} models.AuthResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Router /api/v1/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.AuthRequest
@@ -82,7 +94,16 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	response, challenge, err := h.authService.Login(&req, clientInfo(c))
+	var locked *services.LoginLockedError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "login_locked",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "login_failed",
@@ -90,10 +111,65 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		})
 		return
 	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// LoginTwoFactor 两步验证登录
+// @Summary 两步验证登录
+// @Description 使用登录接口返回的challenge_token和验证器应用中的6位验证码（或恢复码）完成登录。验证码错误计入登录失败次数
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorLoginRequest true "两步验证登录请求"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /api/v1/auth/login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	response, err := h.authService.CompleteTwoFactorLogin(&req, clientInfo(c))
+	var locked *services.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "login_locked",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidChallenge):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_challenge",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_code",
+			Message: err.Error(),
+		})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "login_failed",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusOK, response)
+	}
+}
+
 // GetProfile 获取用户资料
 // @Summary 获取用户资料
 // @Description 获取当前登录用户的资料信息
@@ -166,7 +242,7 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 
 // ChangePassword 修改密码
 // @Summary 修改密码
-// @Description 修改当前登录用户的密码
+// @Description 修改当前登录用户的密码，之前签发的访问令牌全部失效，其他设备上的会话被撤销；响应中返回当前设备的新访问令牌
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -198,7 +274,8 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.ChangePassword(userID.(string), req.OldPassword, req.NewPassword); err != nil {
+	token, expiresAt, err := h.authService.ChangePassword(userID.(string), c.GetString("session_id"), req.OldPassword, req.NewPassword)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "change_password_failed",
 			Message: err.Error(),
@@ -207,23 +284,310 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "密码修改成功",
+		"message":    "密码修改成功",
+		"token":      token,
+		"expires_at": expiresAt,
 	})
 }
 
+// Refresh 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效；已使用过的刷新令牌再次出现时整个会话被撤销
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "刷新请求"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	response, err := h.authService.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		code := "invalid_refresh_token"
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			code = "refresh_token_reused"
+		}
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // Logout 用户登出
 // @Summary 用户登出
-// @Description 登出当前用户（客户端需要删除token）
+// @Description 撤销当前会话，刷新令牌随即失效；请求体中的refresh_token优先，否则撤销访问令牌所属的会话
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param request body models.RefreshRequest false "刷新令牌"
 // @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
 // @Router /api/v1/auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// JWT是无状态的，登出只需要客户端删除token
-	// 这里可以添加token黑名单逻辑（可选）
+	var req models.RefreshRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var err error
+	switch {
+	case req.RefreshToken != "":
+		err = h.authService.Logout(req.RefreshToken)
+	case c.GetString("session_id") != "":
+		err = h.authService.RevokeSession(c.GetString("user_id"), c.GetString("session_id"), services.RevokeReasonLogout)
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "缺少refresh_token或访问令牌",
+		})
+		return
+	}
+	if err != nil && !errors.Is(err, services.ErrAuthSessionNotFound) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "logout_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "登出成功",
 	})
 }
+
+// ListSessions 获取登录会话
+// @Summary 获取登录会话
+// @Description 获取当前用户在各设备上未过期的会话，current标记发起请求的会话
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SessionListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/auth/sessions [get]
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.GetString("user_id"), c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "list_sessions_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	c.JSON(http.StatusOK, models.SessionListResponse{Sessions: sessions})
+}
+
+// RevokeSession 撤销登录会话
+// @Summary 撤销登录会话
+// @Description 撤销指定设备上的会话，该设备需要重新登录
+// @Tags Auth
+// @Security BearerAuth
+// @Param session_id path string true "会话ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/auth/sessions/{session_id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	err := h.authService.RevokeSession(c.GetString("user_id"), c.Param("session_id"), services.RevokeReasonRevoked)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrAuthSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "revoke_session_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions 撤销其他登录会话
+// @Summary 撤销其他登录会话
+// @Description 撤销除当前会话以外的所有会话
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/auth/sessions [delete]
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	revoked, err := h.authService.RevokeOtherSessions(c.GetString("user_id"), c.GetString("session_id"), services.RevokeReasonRevoked)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "revoke_session_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "其他会话已撤销",
+		"revoked": revoked,
+	})
+}
+
+// JWKS 访问令牌的公钥
+// @Summary 访问令牌的公钥
+// @Description 验证访问令牌签名的公钥（JWK Set），按令牌头部的kid选择。包括即将启用的新密钥和尚未过期的旧密钥；使用HS256时为空
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} models.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
+// clientInfo 记录在会话中的设备信息
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// ResendVerification 重新发送验证邮件
+// @Summary 重新发送验证邮件
+// @Description 向当前用户的邮箱重新发送验证链接，之前的验证链接随即失效
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} map[string]string
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /api/v1/auth/resend-verification [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	if err := h.accountService.SendVerification(c.Request.Context(), c.GetString("user_id")); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "验证邮件已发送"})
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的令牌确认邮箱，令牌只能使用一次
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "验证请求"
+// @Success 200 {object} models.User
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.accountService.VerifyEmail(c.Request.Context(), req.Token)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ForgotPassword 找回密码
+// @Summary 找回密码
+// @Description 向邮箱发送密码重置链接。无论邮箱是否已注册都返回相同的响应
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "找回密码请求"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "如果该邮箱已注册，重置链接将发送到该邮箱"})
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用重置邮件中的令牌设置新密码，令牌只能使用一次；之前签发的访问令牌全部失效，所有会话被撤销
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "重置密码请求"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请重新登录"})
+}
+
+func respondAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAccountToken):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already_verified",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrMailThrottled):
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "too_many_requests",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "用户不存在",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: err.Error(),
+		})
+	}
+}
@@ -1,64 +1,48 @@
 package handlers
 
 import (
-	"fmt"
-	"io"
-	"mime/multipart"
+	"errors"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
-	"time"
 
 	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/prompt"
 	"3d-model-generator-backend/internal/services"
+	"3d-model-generator-backend/internal/storage"
+	"3d-model-generator-backend/internal/urls"
 
 	"github.com/gin-gonic/gin"
 )
 
 type GenerationHandler struct {
 	generationService *services.GenerationService
+	uploadService     *services.UploadService
+	promptService     *services.PromptService
+	urls              *urls.Builder
+	files             *storage.URLSigner
 }
 
-// partFileWrapper 包装multipart.Part以实现multipart.File接口
-type partFileWrapper struct {
-	part *multipart.Part
-}
-
-func (p *partFileWrapper) Read(b []byte) (n int, err error) {
-	return p.part.Read(b)
-}
-
-func (p *partFileWrapper) Close() error {
-	return p.part.Close()
-}
-
-func (p *partFileWrapper) ReadAt(b []byte, off int64) (n int, err error) {
-	// 对于我们的用例，ReadAt不是必需的，返回错误
-	return 0, fmt.Errorf("ReadAt not supported")
-}
-
-func (p *partFileWrapper) Seek(offset int64, whence int) (int64, error) {
-	// 对于我们的用例，Seek不是必需的，返回错误
-	return 0, fmt.Errorf("Seek not supported")
-}
-
-func NewGenerationHandler(generationService *services.GenerationService) *GenerationHandler {
+func NewGenerationHandler(generationService *services.GenerationService, uploadService *services.UploadService, promptService *services.PromptService, urlBuilder *urls.Builder, signer *storage.URLSigner) *GenerationHandler {
 	return &GenerationHandler{
 		generationService: generationService,
+		uploadService:     uploadService,
+		promptService:     promptService,
+		urls:              urlBuilder,
+		files:             signer,
 	}
 }
 
 // GenerateFromText 从文本生成3D模型
 // @Summary 从文本生成3D模型
-// @Description 根据文本描述生成3D模型
+// @Description 根据文本描述生成3D模型，也可以通过template_id和variables使用提示词模板，通过preset_id应用风格预设
 // @Tags Generation
 // @Accept json
 // @Produce json
 // @Param request body models.GenerationRequest true "生成请求"
 // @Success 200 {object} models.GenerationResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ModerationRejectionResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/generate/text [post]
 func (h *GenerationHandler) GenerateFromText(c *gin.Context) {
@@ -71,15 +55,6 @@ func (h *GenerationHandler) GenerateFromText(c *gin.Context) {
 		return
 	}
 
-	// 验证请求
-	if req.Prompt == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Missing required field",
-			Message: "prompt is required",
-		})
-		return
-	}
-
 	// 获取用户ID（从认证中间件获取）
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -90,36 +65,101 @@ func (h *GenerationHandler) GenerateFromText(c *gin.Context) {
 		return
 	}
 
+	// 展开提示词模板和风格预设
+	if req.TemplateID != "" || req.PresetID != "" {
+		if err := h.promptService.Resolve(c.Request.Context(), userID.(string), &req); err != nil {
+			respondPromptError(c, err)
+			return
+		}
+	}
+
+	// 验证请求
+	if req.Prompt == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required field",
+			Message: "prompt is required",
+		})
+		return
+	}
+
 	// 转换选项
 	options := &services.GenerationOptions{
 		ResultFormat: req.ResultFormat,
 		EnablePBR:    req.EnablePBR,
 		FaceCount:    req.FaceCount,
 		GenerateType: req.GenerateType,
+		Tier:         req.Tier,
+		Enhance:      req.Enhance,
 	}
 
 	// 调用服务
 	response, err := h.generationService.GenerateFromText(c.Request.Context(), userID.(string), req.Prompt, options)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Generation failed",
+		respondGenerationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PreviewTextPrompt 预览提示词处理结果
+// @Summary 预览提示词处理结果
+// @Description 展开模板和风格预设，执行翻译、关键词扩展和长度限制，返回每个步骤的结果，不创建生成任务
+// @Tags Generation
+// @Accept json
+// @Produce json
+// @Param request body models.GenerationRequest true "生成请求"
+// @Success 200 {object} models.PromptPreviewResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/generate/text/preview [post]
+func (h *GenerationHandler) PreviewTextPrompt(c *gin.Context) {
+	var req models.GenerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
 			Message: err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	if req.TemplateID != "" || req.PresetID != "" {
+		if err := h.promptService.Resolve(c.Request.Context(), c.GetString("user_id"), &req); err != nil {
+			respondPromptError(c, err)
+			return
+		}
+	}
+
+	if req.Prompt == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Missing required field",
+			Message: "prompt is required",
+		})
+		return
+	}
+
+	preview, err := h.promptService.Enhance(c.Request.Context(), req.Prompt, &services.GenerationOptions{
+		Tier:    req.Tier,
+		Enhance: req.Enhance,
+	})
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preview)
 }
 
 // GenerateFromImage 从图片生成3D模型
 // @Summary 从图片生成3D模型
-// @Description 根据图片生成3D模型
+// @Description 根据图片生成3D模型，通过set_id使用多视角图片组时正面图作为主图，其他视角作为multi_view_images提交
 // @Tags Generation
 // @Accept json
 // @Produce json
 // @Param request body models.GenerationRequest true "生成请求"
 // @Success 200 {object} models.GenerationResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ModerationRejectionResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/generate/image [post]
 func (h *GenerationHandler) GenerateFromImage(c *gin.Context) {
@@ -133,10 +173,10 @@ func (h *GenerationHandler) GenerateFromImage(c *gin.Context) {
 	}
 
 	// 验证请求
-	if req.ImageURL == "" && req.ImageBase64 == "" {
+	if req.ImageURL == "" && req.ImageBase64 == "" && req.UploadID == "" && req.SetID == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Missing required field",
-			Message: "image_url or image_base64 is required",
+			Message: "image_url, image_base64, upload_id or set_id is required",
 		})
 		return
 	}
@@ -151,19 +191,34 @@ func (h *GenerationHandler) GenerateFromImage(c *gin.Context) {
 		return
 	}
 
+	if !h.resolveUpload(c, userID.(string), &req) {
+		return
+	}
+
+	// 只能使用自己上传的图片
+	if req.ImageURL != "" {
+		if err := h.uploadService.CheckOwnership(c.Request.Context(), userID.(string), req.ImageURL); err != nil {
+			respondUploadError(c, err)
+			return
+		}
+	}
+
 	// 转换选项
 	options := &services.GenerationOptions{
 		ResultFormat: req.ResultFormat,
 		EnablePBR:    req.EnablePBR,
 		FaceCount:    req.FaceCount,
 		GenerateType: req.GenerateType,
+		Tier:         req.Tier,
 	}
 
 	// 调用服务
 	var response *models.GenerationResponse
 	var err error
 
-	if req.ImageURL != "" {
+	if len(req.MultiViewImages) > 0 {
+		response, err = h.generationService.GenerateFromMultiView(c.Request.Context(), userID.(string), req.ImageURL, req.MultiViewImages, options)
+	} else if req.ImageURL != "" {
 		response, err = h.generationService.GenerateFromImage(c.Request.Context(), userID.(string), req.ImageURL, options)
 	} else {
 		// 处理base64图片
@@ -171,10 +226,7 @@ func (h *GenerationHandler) GenerateFromImage(c *gin.Context) {
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Generation failed",
-			Message: err.Error(),
-		})
+		respondGenerationError(c, err)
 		return
 	}
 
@@ -202,9 +254,10 @@ func (h *GenerationHandler) GetJobStatus(c *gin.Context) {
 		return
 	}
 
-	response, err := h.generationService.GetJobStatus(c.Request.Context(), jobID)
+	// 只能查看自己的任务，结果文件的签名地址不会发给其他用户
+	response, err := h.generationService.GetJobStatus(c.Request.Context(), c.GetString("user_id"), jobID)
 	if err != nil {
-		if err.Error() == "job not found" {
+		if errors.Is(err, services.ErrJobNotFound) {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "Job not found",
 				Message: err.Error(),
@@ -218,6 +271,11 @@ func (h *GenerationHandler) GetJobStatus(c *gin.Context) {
 		return
 	}
 
+	signFiles(h.files, response.ResultFiles)
+	for i := range response.ResultFiles {
+		response.ResultFiles[i].URL = h.urls.Absolute(c.Request, response.ResultFiles[i].URL)
+		response.ResultFiles[i].PreviewImageURL = h.urls.Absolute(c.Request, response.ResultFiles[i].PreviewImageURL)
+	}
 	c.JSON(http.StatusOK, response)
 }
 
@@ -265,6 +323,9 @@ func (h *GenerationHandler) GetUserJobs(c *gin.Context) {
 		return
 	}
 
+	for i := range jobs {
+		signJobFiles(h.files, &jobs[i])
+	}
 	c.JSON(http.StatusOK, jobs)
 }
 
@@ -306,6 +367,8 @@ func (h *GenerationHandler) GetStatistics(c *gin.Context) {
 // @Produce application/octet-stream
 // @Param job_id path string true "任务ID"
 // @Param file_type query string false "文件类型" default("obj")
+// @Param lod query int false "LOD面数百分比，例如50、25、10，不传为原始模型"
+// @Param version query int false "变换派生版本号，不传为原始模型"
 // @Success 200 {file} binary
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
@@ -315,6 +378,35 @@ func (h *GenerationHandler) DownloadModel(c *gin.Context) {
 	jobID := c.Param("job_id")
 	fileType := c.DefaultQuery("file_type", "obj")
 
+	lod := 0
+	if lodStr := c.Query("lod"); lodStr != "" {
+		var err error
+		lod, err = strconv.Atoi(lodStr)
+		if err != nil || lod < 0 || lod > 100 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid LOD",
+				Message: "lod must be an integer between 0 and 100",
+			})
+			return
+		}
+		if lod == 100 {
+			lod = 0
+		}
+	}
+
+	version := 0
+	if versionStr := c.Query("version"); versionStr != "" {
+		var err error
+		version, err = strconv.Atoi(versionStr)
+		if err != nil || version < 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid version",
+				Message: "version must be a non-negative integer",
+			})
+			return
+		}
+	}
+
 	if jobID == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Missing job ID",
@@ -323,10 +415,10 @@ func (h *GenerationHandler) DownloadModel(c *gin.Context) {
 		return
 	}
 
-	// 获取任务状态
-	status, err := h.generationService.GetJobStatus(c.Request.Context(), jobID)
+	// 获取任务状态，只能下载自己的任务，派生版本和LOD文件的签名地址不会发给其他用户
+	status, err := h.generationService.GetJobStatus(c.Request.Context(), c.GetString("user_id"), jobID)
 	if err != nil {
-		if err.Error() == "job not found" {
+		if errors.Is(err, services.ErrJobNotFound) {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "Job not found",
 				Message: err.Error(),
@@ -351,7 +443,7 @@ func (h *GenerationHandler) DownloadModel(c *gin.Context) {
 	// 查找指定类型的文件
 	var downloadURL string
 	for _, file := range status.ResultFiles {
-		if file.Type == fileType {
+		if file.Type == fileType && file.LOD == lod && file.Version == version {
 			downloadURL = file.URL
 			break
 		}
@@ -366,169 +458,7 @@ func (h *GenerationHandler) DownloadModel(c *gin.Context) {
 	}
 
 	// 重定向到下载URL
-	c.Redirect(http.StatusFound, downloadURL)
-}
-
-// UploadImage 上传图片文件
-// @Summary 上传图片文件
-// @Description 上传图片文件用于3D模型生成
-// @Tags Generation
-// @Accept multipart/form-data
-// @Produce json
-// @Param file formData file true "图片文件"
-// @Success 200 {object} UploadResponse
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
-// @Router /api/v1/upload/image [post]
-func (h *GenerationHandler) UploadImage(c *gin.Context) {
-	// 检查Content-Type
-	contentType := c.GetHeader("Content-Type")
-	if !strings.Contains(contentType, "multipart/form-data") {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid content type",
-			Message: "Content-Type must be multipart/form-data",
-		})
-		return
-	}
-
-	// 使用原生multipart解析，避免Gin的ParseMultipartForm问题
-	reader, err := c.Request.MultipartReader()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "File upload failed",
-			Message: "Failed to create multipart reader: " + err.Error(),
-		})
-		return
-	}
-
-	var file io.Reader
-	var filename string
-	var found bool
-
-	// 遍历multipart部分
-	for {
-		part, err := reader.NextPart()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			// 忽略解析错误，继续尝试下一个part
-			fmt.Printf("Debug: Error reading part: %v\n", err)
-			continue
-		}
-
-		// 安全地获取FormName和FileName
-		formName := part.FormName()
-		fileName := part.FileName()
-
-		fmt.Printf("Debug: Found part - FormName: '%s', FileName: '%s'\n", formName, fileName)
-
-		// 检查是否是文件字段
-		if fileName != "" || formName == "file" || formName == "image" || formName == "upload" {
-			file = part
-			if fileName != "" {
-				filename = fileName
-			} else {
-				filename = "uploaded_file"
-			}
-			found = true
-			break
-		}
-		part.Close()
-	}
-
-	if !found {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "File upload failed",
-			Message: "No file found in request. Please use field name 'file', 'image', or 'upload'",
-		})
-		return
-	}
-
-	// 验证文件类型
-	allowedTypes := []string{".jpg", ".jpeg", ".png", ".webp"}
-	ext := strings.ToLower(filepath.Ext(filename))
-	isValidType := false
-	for _, allowedType := range allowedTypes {
-		if ext == allowedType {
-			isValidType = true
-			break
-		}
-	}
-
-	if !isValidType {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Invalid file type",
-			Message: "Only JPG, JPEG, PNG, and WEBP files are allowed",
-		})
-		return
-	}
-
-	// 创建唯一文件名
-	timestamp := time.Now().Unix()
-	uniqueFilename := fmt.Sprintf("%d_%s", timestamp, filename)
-	filePath := filepath.Join("uploads", "images", uniqueFilename)
-
-	// 确保目录存在
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Directory creation failed",
-			Message: "Failed to create upload directory: " + err.Error(),
-		})
-		return
-	}
-
-	// 读取文件内容到内存
-	fileData, err := io.ReadAll(file)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "File upload failed",
-			Message: "Failed to read file: " + err.Error(),
-		})
-		return
-	}
-
-	// 验证文件大小
-	if len(fileData) > 8*1024*1024 {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "File too large",
-			Message: "File size must be less than 8MB",
-		})
-		return
-	}
-
-	// 保存文件
-	dst, err := os.Create(filePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "File save failed",
-			Message: "Failed to save file: " + err.Error(),
-		})
-		return
-	}
-	defer dst.Close()
-
-	written, err := dst.Write(fileData)
-	if err != nil {
-		os.Remove(filePath) // 删除部分写入的文件
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "File write failed",
-			Message: "Failed to write file: " + err.Error(),
-		})
-		return
-	}
-
-	fmt.Printf("Debug: File saved successfully, size: %d bytes\n", written)
-
-	// 生成访问URL
-	imageURL := fmt.Sprintf("http://localhost:8080/uploads/images/%s", uniqueFilename)
-
-	c.JSON(http.StatusOK, UploadResponse{
-		ImageURL: imageURL,
-		Filename: uniqueFilename,
-		Size:     int64(written),
-		Message:  "Image uploaded successfully",
-	})
+	c.Redirect(http.StatusFound, h.urls.Absolute(c.Request, h.files.Sign(downloadURL)))
 }
 
 // GenerateFromUploadedImage 从上传的图片生成3D模型
@@ -540,6 +470,8 @@ func (h *GenerationHandler) UploadImage(c *gin.Context) {
 // @Param request body models.GenerationRequest true "生成请求"
 // @Success 200 {object} models.GenerationResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ModerationRejectionResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/generate/uploaded-image [post]
 func (h *GenerationHandler) GenerateFromUploadedImage(c *gin.Context) {
@@ -553,10 +485,10 @@ func (h *GenerationHandler) GenerateFromUploadedImage(c *gin.Context) {
 	}
 
 	// 验证请求
-	if req.ImageURL == "" {
+	if req.ImageURL == "" && req.UploadID == "" && req.SetID == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "Missing required field",
-			Message: "image_url is required",
+			Message: "image_url, upload_id or set_id is required",
 		})
 		return
 	}
@@ -571,31 +503,123 @@ func (h *GenerationHandler) GenerateFromUploadedImage(c *gin.Context) {
 		return
 	}
 
+	if !h.resolveUpload(c, userID.(string), &req) {
+		return
+	}
+
+	// 只能使用自己上传的图片
+	if err := h.uploadService.CheckOwnership(c.Request.Context(), userID.(string), req.ImageURL); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
 	// 转换选项
 	options := &services.GenerationOptions{
 		ResultFormat: req.ResultFormat,
 		EnablePBR:    req.EnablePBR,
 		FaceCount:    req.FaceCount,
 		GenerateType: req.GenerateType,
+		Tier:         req.Tier,
 	}
 
 	// 调用服务
-	response, err := h.generationService.GenerateFromImage(c.Request.Context(), userID.(string), req.ImageURL, options)
+	var response *models.GenerationResponse
+	var err error
+	if len(req.MultiViewImages) > 0 {
+		response, err = h.generationService.GenerateFromMultiView(c.Request.Context(), userID.(string), req.ImageURL, req.MultiViewImages, options)
+	} else {
+		response, err = h.generationService.GenerateFromImage(c.Request.Context(), userID.(string), req.ImageURL, options)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Generation failed",
-			Message: err.Error(),
-		})
+		respondGenerationError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// UploadResponse 上传响应结构
-type UploadResponse struct {
-	ImageURL string `json:"image_url"`
-	Filename string `json:"filename"`
-	Size     int64  `json:"size"`
-	Message  string `json:"message"`
+// resolveUpload 请求中带有upload_id时，将其解析为用户图片库中对应图片的URL；
+// 带有set_id时，将图片组的正面图解析为主图，其他视角解析为multi_view_images
+func (h *GenerationHandler) resolveUpload(c *gin.Context, userID string, req *models.GenerationRequest) bool {
+	// 其他视角只接受来自用户自己图片组的图片
+	req.MultiViewImages = nil
+	if req.SetID != "" {
+		return h.resolveUploadSet(c, userID, req)
+	}
+	if req.UploadID == "" {
+		return true
+	}
+
+	upload, err := h.uploadService.GetUpload(c.Request.Context(), userID, req.UploadID)
+	if err != nil {
+		respondUploadError(c, err)
+		return false
+	}
+
+	// 提交给腾讯云的地址需要能从外部访问，因此使用配置的公开地址；不采用请求推断的地址，
+	// 生成时只有与配置的地址同源的图片才直接读取本地存储
+	req.ImageURL = h.urls.Absolute(nil, upload.URL)
+	req.ImageBase64 = ""
+	return true
+}
+
+// resolveUploadSet 按用户查找图片组，组中必须有正面图
+func (h *GenerationHandler) resolveUploadSet(c *gin.Context, userID string, req *models.GenerationRequest) bool {
+	views, err := h.uploadService.GetUploadSet(c.Request.Context(), userID, req.SetID)
+	if err != nil {
+		respondUploadError(c, err)
+		return false
+	}
+	if views[0].ViewType != "front" {
+		respondUploadError(c, services.ErrMissingFrontView)
+		return false
+	}
+
+	// 与upload_id一样使用配置的公开地址，其他视角始终由腾讯云直接下载
+	req.ImageURL = h.urls.Absolute(nil, views[0].ViewImageURL)
+	req.ImageBase64 = ""
+	for _, view := range views[1:] {
+		view.ViewImageURL = h.urls.Absolute(nil, view.ViewImageURL)
+		req.MultiViewImages = append(req.MultiViewImages, view)
+	}
+	return true
+}
+
+// respondGenerationError 提示词过长或输入图片无法获取时返回400，审核未通过时返回422，其他错误返回500
+func respondGenerationError(c *gin.Context, err error) {
+	var rejection *services.ModerationRejection
+	switch {
+	case errors.As(err, &rejection):
+		message := "The content violates the usage policy"
+		if rejection.Suspended {
+			message = "The content violates the usage policy and the account has been suspended"
+		}
+		c.JSON(http.StatusUnprocessableEntity, models.ModerationRejectionResponse{
+			Error:     "Content rejected",
+			Message:   message,
+			Kind:      rejection.Kind,
+			Category:  rejection.Category,
+			Suspended: rejection.Suspended,
+		})
+	case errors.Is(err, services.ErrModerationUnavailable):
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "Moderation unavailable",
+			Message: "Content moderation is temporarily unavailable, please try again later",
+		})
+	case errors.Is(err, prompt.ErrPromptTooLong):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid prompt",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrImageFetch):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Image fetch failed",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Generation failed",
+			Message: err.Error(),
+		})
+	}
 }
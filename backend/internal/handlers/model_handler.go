@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+	"3d-model-generator-backend/internal/storage"
+	"3d-model-generator-backend/internal/urls"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModelHandler struct {
+	modelService *services.ModelService
+	urls         *urls.Builder
+	files        *storage.URLSigner
+}
+
+func NewModelHandler(modelService *services.ModelService, urlBuilder *urls.Builder, signer *storage.URLSigner) *ModelHandler {
+	return &ModelHandler{
+		modelService: modelService,
+		urls:         urlBuilder,
+		files:        signer,
+	}
+}
+
+// TransformModel 变换模型
+// @Summary 变换模型
+// @Description 对生成的模型进行缩放（毫米）、居中、落地、坐标轴转换和镜像，结果保存为任务的新版本。只能变换自己的任务
+// @Tags Model
+// @Accept json
+// @Produce json
+// @Param job_id path string true "任务ID"
+// @Param request body models.TransformRequest true "变换参数"
+// @Success 200 {object} models.TransformResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{job_id}/transform [post]
+func (h *ModelHandler) TransformModel(c *gin.Context) {
+	var req models.TransformRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	response, err := h.modelService.Transform(c.Request.Context(), c.GetString("user_id"), c.Param("job_id"), &req)
+	if err != nil {
+		respondModelError(c, err)
+		return
+	}
+
+	response.File.URL = h.urls.Absolute(c.Request, h.files.Sign(response.File.URL))
+	c.JSON(http.StatusOK, response)
+}
+
+// DownloadBundle 打包下载任务
+// @Summary 打包下载任务
+// @Description 将模型文件、材质、贴图、预览图、缩略图和manifest.json打包为ZIP流式返回。只能下载自己的任务
+// @Tags Model
+// @Produce application/zip
+// @Param job_id path string true "任务ID"
+// @Success 200 {file} binary
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{job_id}/bundle [get]
+func (h *ModelHandler) DownloadBundle(c *gin.Context) {
+	bundle, err := h.modelService.NewBundle(c.Request.Context(), c.GetString("user_id"), c.Param("job_id"))
+	if err != nil {
+		respondModelError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", bundle.Filename()))
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，出错时只能中断连接并记录日志
+	if err := bundle.Write(c.Request.Context(), c.Writer); err != nil {
+		log.Printf("Failed to write bundle for job %s: %v", c.Param("job_id"), err)
+		c.Abort()
+	}
+}
+
+// GetThumbnails 获取任务缩略图列表
+// @Summary 获取任务缩略图列表
+// @Description 获取服务端渲染的模型缩略图和转台精灵图
+// @Tags Model
+// @Produce json
+// @Param job_id path string true "任务ID"
+// @Success 200 {object} []models.Thumbnail
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{job_id}/thumbnails [get]
+func (h *ModelHandler) GetThumbnails(c *gin.Context) {
+	thumbnails, err := h.modelService.GetThumbnails(c.Request.Context(), c.GetString("user_id"), c.Param("job_id"))
+	if err != nil {
+		respondModelError(c, err)
+		return
+	}
+
+	if thumbnails == nil {
+		thumbnails = []models.Thumbnail{}
+	}
+	for i := range thumbnails {
+		thumbnails[i].URL = h.urls.Absolute(c.Request, h.files.Sign(thumbnails[i].URL))
+	}
+	c.JSON(http.StatusOK, thumbnails)
+}
+
+// GetThumbnail 获取单个缩略图
+// @Summary 获取单个缩略图
+// @Description 返回指定名称的缩略图PNG
+// @Tags Model
+// @Produce image/png
+// @Param job_id path string true "任务ID"
+// @Param name path string true "缩略图名称"
+// @Success 200 {file} binary
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/jobs/{job_id}/thumbnails/{name} [get]
+func (h *ModelHandler) GetThumbnail(c *gin.Context) {
+	filePath, err := h.modelService.ThumbnailPath(c.Request.Context(), c.GetString("user_id"), c.Param("job_id"), c.Param("name"))
+	if err != nil {
+		respondModelError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "image/png")
+	c.File(filePath)
+}
+
+// respondModelError 将服务层错误映射为HTTP响应
+func respondModelError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Job not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrJobNotCompleted):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Job not completed",
+			Message: "The generation job is not completed yet",
+		})
+	case errors.Is(err, services.ErrInvalidTransform):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid transform",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrNoMeshResult):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "No mesh result",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrThumbnailNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Thumbnail not found",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Model operation failed",
+			Message: err.Error(),
+		})
+	}
+}
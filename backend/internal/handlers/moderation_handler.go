@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationHandler struct {
+	moderationService *services.ModerationService
+}
+
+func NewModerationHandler(moderationService *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: moderationService,
+	}
+}
+
+// ListViolations 获取内容审核违规记录（管理员）
+// @Summary 获取内容审核违规记录
+// @Description 分页获取违规记录，按时间倒序，可按用户筛选
+// @Tags Admin
+// @Produce json
+// @Param user_id query string false "用户ID"
+// @Param limit query int false "限制数量" default(50)
+// @Param offset query int false "偏移量" default(0)
+// @Success 200 {object} models.ModerationViolationListResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/moderation/violations [get]
+func (h *ModerationHandler) ListViolations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	violations, total, err := h.moderationService.ListViolations(c.Request.Context(), c.Query("user_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to list violations",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if violations == nil {
+		violations = []models.ModerationViolation{}
+	}
+	c.JSON(http.StatusOK, models.ModerationViolationListResponse{
+		Violations: violations,
+		Total:      total,
+		Limit:      limit,
+		Offset:     offset,
+	})
+}
+
+// ReinstateUser 恢复被封禁的用户（管理员）
+// @Summary 恢复被封禁的用户
+// @Description 重新启用账号，已有的违规记录标记为已处理，不再计入自动封禁
+// @Tags Admin
+// @Produce json
+// @Param user_id path string true "用户ID"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/users/{user_id}/reinstate [post]
+func (h *ModerationHandler) ReinstateUser(c *gin.Context) {
+	if err := h.moderationService.Reinstate(c.Request.Context(), c.Param("user_id")); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "User not found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to reinstate user",
+			Message: err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrivacyHandler struct {
+	privacyService *services.PrivacyService
+}
+
+func NewPrivacyHandler(privacyService *services.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+	}
+}
+
+// DeleteAccount 注销账号
+// @Summary 注销账号
+// @Description 确认密码后申请注销，开启两步验证时还需验证码或恢复码。申请后所有会话被撤销，
+// @Description 账号及其任务、上传、评估和模型文件在宽限期满后删除，宽限期内重新登录可以撤销；未配置宽限期时立即删除。
+// @Description 没有密码的账号（只通过单点登录使用）首次请求时向邮箱发送确认链接并返回confirmation_sent，之后以链接中的token代替密码再次请求
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DeleteAccountRequest true "注销请求"
+// @Success 200 {object} models.AccountDeletionResponse
+// @Success 202 {object} models.AccountDeletionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/auth/account [delete]
+func (h *PrivacyHandler) DeleteAccount(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	scheduledAt, err := h.privacyService.RequestDeletion(c.Request.Context(), c.GetString("user_id"), req.Password, req.Token, req.Code)
+	if errors.Is(err, services.ErrDeletionConfirmationSent) {
+		c.JSON(http.StatusAccepted, models.AccountDeletionResponse{
+			ConfirmationSent: true,
+			Message:          "确认链接已发送到邮箱，请使用其中的链接完成注销",
+		})
+		return
+	}
+	if err != nil {
+		respondPrivacyError(c, err)
+		return
+	}
+	if scheduledAt == nil {
+		c.JSON(http.StatusOK, models.AccountDeletionResponse{Message: "账号及数据已删除"})
+		return
+	}
+	c.JSON(http.StatusAccepted, models.AccountDeletionResponse{
+		DeletionScheduledAt: scheduledAt,
+		Message:             "账号将在宽限期满后删除，此前重新登录可以撤销",
+	})
+}
+
+// CancelDeletion 撤销注销申请
+// @Summary 撤销注销申请
+// @Description 在宽限期内撤销注销申请，账号和数据保留
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/auth/account/restore [post]
+func (h *PrivacyHandler) CancelDeletion(c *gin.Context) {
+	if err := h.privacyService.CancelDeletion(c.Request.Context(), c.GetString("user_id")); err != nil {
+		respondPrivacyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已撤销注销申请"})
+}
+
+// ExportData 导出个人数据
+// @Summary 导出个人数据
+// @Description 将个人资料、登录方式、任务历史、提示词、模板、评估、上传的图片、登录记录和已完成任务的模型文件打包为ZIP流式返回
+// @Tags Auth
+// @Produce application/zip
+// @Security BearerAuth
+// @Success 200 {file} binary
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/auth/export [get]
+func (h *PrivacyHandler) ExportData(c *gin.Context) {
+	userID := c.GetString("user_id")
+	filename := fmt.Sprintf("export_%s_%s.zip", userID, time.Now().Format("20060102"))
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，出错时只能中断连接并记录日志
+	if err := h.privacyService.Export(c.Request.Context(), userID, c.Writer); err != nil {
+		log.Printf("Failed to export data for user %s: %v", userID, err)
+		c.Abort()
+	}
+}
+
+func respondPrivacyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPassword):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_password",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "invalid_code",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidAccountToken):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrDeletionScheduled):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "deletion_scheduled",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrDeletionNotScheduled):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "deletion_not_scheduled",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "last_admin",
+			Message: "系统中唯一的管理员不能注销账号",
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "用户不存在",
+		})
+	default:
+		log.Printf("Account request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "internal_error",
+			Message: "操作失败",
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/prompt"
+	"3d-model-generator-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromptHandler struct {
+	promptService *services.PromptService
+}
+
+func NewPromptHandler(promptService *services.PromptService) *PromptHandler {
+	return &PromptHandler{
+		promptService: promptService,
+	}
+}
+
+// TemplateListResponse 提示词模板列表
+type TemplateListResponse struct {
+	Templates []models.PromptTemplate `json:"templates"`
+}
+
+// PresetListResponse 风格预设列表
+type PresetListResponse struct {
+	Presets []models.StylePreset `json:"presets"`
+}
+
+// ListTemplates 获取提示词模板
+// @Summary 获取提示词模板
+// @Description 获取全局模板和当前用户的模板
+// @Tags Prompt
+// @Produce json
+// @Success 200 {object} TemplateListResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/templates [get]
+func (h *PromptHandler) ListTemplates(c *gin.Context) {
+	templates, err := h.promptService.ListTemplates(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	if templates == nil {
+		templates = []models.PromptTemplate{}
+	}
+	c.JSON(http.StatusOK, TemplateListResponse{Templates: templates})
+}
+
+// GetTemplate 获取提示词模板详情
+// @Summary 获取提示词模板详情
+// @Tags Prompt
+// @Produce json
+// @Param template_id path string true "模板ID"
+// @Success 200 {object} models.PromptTemplate
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/templates/{template_id} [get]
+func (h *PromptHandler) GetTemplate(c *gin.Context) {
+	template, err := h.promptService.GetTemplate(c.Request.Context(), c.GetString("user_id"), c.Param("template_id"))
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// CreateTemplate 创建提示词模板
+// @Summary 创建提示词模板
+// @Description 模板中的占位符形如 {object}，"{{"和"}}"表示字面的花括号
+// @Tags Prompt
+// @Accept json
+// @Produce json
+// @Param request body models.PromptTemplateRequest true "模板"
+// @Success 201 {object} models.PromptTemplate
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/templates [post]
+func (h *PromptHandler) CreateTemplate(c *gin.Context) {
+	h.createTemplate(c, c.GetString("user_id"))
+}
+
+// UpdateTemplate 更新提示词模板
+// @Summary 更新提示词模板
+// @Description 只能修改自己的模板，全局模板由管理员维护
+// @Tags Prompt
+// @Accept json
+// @Produce json
+// @Param template_id path string true "模板ID"
+// @Param request body models.PromptTemplateRequest true "模板"
+// @Success 200 {object} models.PromptTemplate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/templates/{template_id} [put]
+func (h *PromptHandler) UpdateTemplate(c *gin.Context) {
+	h.updateTemplate(c, c.GetString("user_id"))
+}
+
+// DeleteTemplate 删除提示词模板
+// @Summary 删除提示词模板
+// @Tags Prompt
+// @Param template_id path string true "模板ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/templates/{template_id} [delete]
+func (h *PromptHandler) DeleteTemplate(c *gin.Context) {
+	h.deleteTemplate(c, c.GetString("user_id"))
+}
+
+// RenderTemplate 预览模板展开结果
+// @Summary 预览模板展开结果
+// @Description 用给定变量展开模板，可选地应用风格预设，不创建生成任务
+// @Tags Prompt
+// @Accept json
+// @Produce json
+// @Param template_id path string true "模板ID"
+// @Param request body models.RenderTemplateRequest true "模板变量"
+// @Success 200 {object} models.RenderTemplateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/templates/{template_id}/render [post]
+func (h *PromptHandler) RenderTemplate(c *gin.Context) {
+	var req models.RenderTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	generation := models.GenerationRequest{
+		TemplateID: c.Param("template_id"),
+		Variables:  req.Variables,
+		PresetID:   req.PresetID,
+	}
+	if err := h.promptService.Resolve(c.Request.Context(), c.GetString("user_id"), &generation); err != nil {
+		respondPromptError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RenderTemplateResponse{
+		Prompt: generation.Prompt,
+		Options: &models.GenerationOptions{
+			ResultFormat: generation.ResultFormat,
+			EnablePBR:    generation.EnablePBR,
+			FaceCount:    generation.FaceCount,
+			GenerateType: generation.GenerateType,
+			Tier:         generation.Tier,
+			Enhance:      generation.Enhance,
+		},
+	})
+}
+
+// ListPresets 获取风格预设
+// @Summary 获取风格预设
+// @Description 获取全局预设和当前用户的预设
+// @Tags Prompt
+// @Produce json
+// @Success 200 {object} PresetListResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/presets [get]
+func (h *PromptHandler) ListPresets(c *gin.Context) {
+	presets, err := h.promptService.ListPresets(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	if presets == nil {
+		presets = []models.StylePreset{}
+	}
+	c.JSON(http.StatusOK, PresetListResponse{Presets: presets})
+}
+
+// GetPreset 获取风格预设详情
+// @Summary 获取风格预设详情
+// @Tags Prompt
+// @Produce json
+// @Param preset_id path string true "预设ID"
+// @Success 200 {object} models.StylePreset
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/presets/{preset_id} [get]
+func (h *PromptHandler) GetPreset(c *gin.Context) {
+	preset, err := h.promptService.GetPreset(c.Request.Context(), c.GetString("user_id"), c.Param("preset_id"))
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preset)
+}
+
+// CreatePreset 创建风格预设
+// @Summary 创建风格预设
+// @Description 预设的提示词后缀追加在提示词之后，选项只填充请求中未指定的字段
+// @Tags Prompt
+// @Accept json
+// @Produce json
+// @Param request body models.StylePresetRequest true "预设"
+// @Success 201 {object} models.StylePreset
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/v1/presets [post]
+func (h *PromptHandler) CreatePreset(c *gin.Context) {
+	h.createPreset(c, c.GetString("user_id"))
+}
+
+// UpdatePreset 更新风格预设
+// @Summary 更新风格预设
+// @Description 只能修改自己的预设，全局预设由管理员维护
+// @Tags Prompt
+// @Accept json
+// @Produce json
+// @Param preset_id path string true "预设ID"
+// @Param request body models.StylePresetRequest true "预设"
+// @Success 200 {object} models.StylePreset
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/presets/{preset_id} [put]
+func (h *PromptHandler) UpdatePreset(c *gin.Context) {
+	h.updatePreset(c, c.GetString("user_id"))
+}
+
+// DeletePreset 删除风格预设
+// @Summary 删除风格预设
+// @Tags Prompt
+// @Param preset_id path string true "预设ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/presets/{preset_id} [delete]
+func (h *PromptHandler) DeletePreset(c *gin.Context) {
+	h.deletePreset(c, c.GetString("user_id"))
+}
+
+// CreateGlobalTemplate 创建全局提示词模板（管理员）
+// @Summary 创建全局提示词模板
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body models.PromptTemplateRequest true "模板"
+// @Success 201 {object} models.PromptTemplate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/templates [post]
+func (h *PromptHandler) CreateGlobalTemplate(c *gin.Context) {
+	h.createTemplate(c, "")
+}
+
+// UpdateGlobalTemplate 更新全局提示词模板（管理员）
+// @Summary 更新全局提示词模板
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param template_id path string true "模板ID"
+// @Param request body models.PromptTemplateRequest true "模板"
+// @Success 200 {object} models.PromptTemplate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/templates/{template_id} [put]
+func (h *PromptHandler) UpdateGlobalTemplate(c *gin.Context) {
+	h.updateTemplate(c, "")
+}
+
+// DeleteGlobalTemplate 删除全局提示词模板（管理员）
+// @Summary 删除全局提示词模板
+// @Tags Admin
+// @Param template_id path string true "模板ID"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/templates/{template_id} [delete]
+func (h *PromptHandler) DeleteGlobalTemplate(c *gin.Context) {
+	h.deleteTemplate(c, "")
+}
+
+// CreateGlobalPreset 创建全局风格预设（管理员）
+// @Summary 创建全局风格预设
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body models.StylePresetRequest true "预设"
+// @Success 201 {object} models.StylePreset
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/admin/presets [post]
+func (h *PromptHandler) CreateGlobalPreset(c *gin.Context) {
+	h.createPreset(c, "")
+}
+
+// UpdateGlobalPreset 更新全局风格预设（管理员）
+// @Summary 更新全局风格预设
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param preset_id path string true "预设ID"
+// @Param request body models.StylePresetRequest true "预设"
+// @Success 200 {object} models.StylePreset
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/presets/{preset_id} [put]
+func (h *PromptHandler) UpdateGlobalPreset(c *gin.Context) {
+	h.updatePreset(c, "")
+}
+
+// DeleteGlobalPreset 删除全局风格预设（管理员）
+// @Summary 删除全局风格预设
+// @Tags Admin
+// @Param preset_id path string true "预设ID"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/admin/presets/{preset_id} [delete]
+func (h *PromptHandler) DeleteGlobalPreset(c *gin.Context) {
+	h.deletePreset(c, "")
+}
+
+func (h *PromptHandler) createTemplate(c *gin.Context, ownerID string) {
+	var req models.PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	template, err := h.promptService.CreateTemplate(c.Request.Context(), ownerID, &req)
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, template)
+}
+
+func (h *PromptHandler) updateTemplate(c *gin.Context, ownerID string) {
+	var req models.PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	template, err := h.promptService.UpdateTemplate(c.Request.Context(), ownerID, c.Param("template_id"), &req)
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *PromptHandler) deleteTemplate(c *gin.Context, ownerID string) {
+	if err := h.promptService.DeleteTemplate(c.Request.Context(), ownerID, c.Param("template_id")); err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PromptHandler) createPreset(c *gin.Context, ownerID string) {
+	var req models.StylePresetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	preset, err := h.promptService.CreatePreset(c.Request.Context(), ownerID, &req)
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, preset)
+}
+
+func (h *PromptHandler) updatePreset(c *gin.Context, ownerID string) {
+	var req models.StylePresetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	preset, err := h.promptService.UpdatePreset(c.Request.Context(), ownerID, c.Param("preset_id"), &req)
+	if err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preset)
+}
+
+func (h *PromptHandler) deletePreset(c *gin.Context, ownerID string) {
+	if err := h.promptService.DeletePreset(c.Request.Context(), ownerID, c.Param("preset_id")); err != nil {
+		respondPromptError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondPromptError 模板或预设不存在时返回404，模板或变量无效时返回400
+func respondPromptError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Template not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrPresetNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Preset not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, prompt.ErrInvalidTemplate), errors.Is(err, prompt.ErrMissingVariable),
+		errors.Is(err, prompt.ErrPromptTooLong), errors.Is(err, services.ErrInvalidPreset),
+		errors.Is(err, services.ErrInvalidPrompt):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid prompt",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+	}
+}
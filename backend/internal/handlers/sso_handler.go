@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+	"3d-model-generator-backend/internal/urls"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ssoStateCookie 保存授权请求state的哈希，将回调绑定到发起登录的浏览器
+const ssoStateCookie = "sso_state"
+
+type SSOHandler struct {
+	ssoService   *services.SSOService
+	authService  *services.AuthService
+	urls         *urls.Builder
+	postLoginURL string
+}
+
+// NewSSOHandler postLoginURL为前端的登录完成页面；为空时回调接口直接返回令牌（JSON）
+func NewSSOHandler(ssoService *services.SSOService, authService *services.AuthService, urlBuilder *urls.Builder, postLoginURL string) *SSOHandler {
+	return &SSOHandler{
+		ssoService:   ssoService,
+		authService:  authService,
+		urls:         urlBuilder,
+		postLoginURL: postLoginURL,
+	}
+}
+
+// ListProviders 获取单点登录提供方
+// @Summary 获取单点登录提供方
+// @Description 已配置的OIDC身份提供方，登录页面据此显示登录按钮
+// @Tags SSO
+// @Produce json
+// @Success 200 {object} models.SSOProviderListResponse
+// @Router /api/v1/auth/oidc/providers [get]
+func (h *SSOHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SSOProviderListResponse{Providers: h.ssoService.Providers()})
+}
+
+// Login 发起单点登录
+// @Summary 发起单点登录
+// @Description 重定向到身份提供方的授权页面（授权码+PKCE），并设置将回调绑定到当前浏览器的cookie。
+// @Description format=json时返回授权地址而不重定向，此时需在同一浏览器中携带cookie发起请求
+// @Tags SSO
+// @Produce json
+// @Param provider path string true "提供方标识"
+// @Param format query string false "json：返回授权地址"
+// @Success 200 {object} models.SSOLoginURLResponse
+// @Success 302
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /api/v1/auth/oidc/{provider}/login [get]
+func (h *SSOHandler) Login(c *gin.Context) {
+	provider := c.Param("provider")
+	authURL, binding, err := h.ssoService.Begin(c.Request.Context(), provider)
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+
+	h.setStateCookie(c, provider, binding, int(services.SSOStateExpiry/time.Second))
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, models.SSOLoginURLResponse{AuthorizationURL: authURL})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 单点登录回调
+// @Summary 单点登录回调
+// @Description 身份提供方授权后重定向到此处，需携带发起登录时设置的cookie。配置了OIDC_POST_LOGIN_URL时重定向到前端页面并附带login_code（或error），
+// @Description 前端调用/auth/oidc/exchange换取令牌；未配置时直接返回令牌。开启两步验证的用户返回models.TwoFactorChallenge
+// @Tags SSO
+// @Produce json
+// @Param provider path string true "提供方标识"
+// @Param state query string true "授权请求的state"
+// @Param code query string true "授权码"
+// @Success 200 {object} models.AuthResponse
+// @Success 302
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/auth/oidc/{provider}/callback [get]
+func (h *SSOHandler) Callback(c *gin.Context) {
+	ctx := c.Request.Context()
+	provider := c.Param("provider")
+	binding, _ := c.Cookie(ssoStateCookie)
+	h.setStateCookie(c, provider, "", -1)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		log.Printf("OIDC provider %s returned error %s: %s", provider, providerErr, c.Query("error_description"))
+		h.callbackError(c, services.ErrSSOAuthFailed)
+		return
+	}
+
+	user, err := h.ssoService.Callback(ctx, provider, c.Query("state"), binding, c.Query("code"))
+	if err != nil {
+		h.callbackError(c, err)
+		return
+	}
+
+	if h.postLoginURL != "" {
+		code, err := h.ssoService.IssueLoginCode(ctx, user)
+		if err != nil {
+			h.callbackError(c, err)
+			return
+		}
+		c.Redirect(http.StatusFound, withQuery(h.postLoginURL, "login_code", code))
+		return
+	}
+
+	h.login(c, user, provider)
+}
+
+// Exchange 使用一次性登录码换取令牌
+// @Summary 使用一次性登录码换取令牌
+// @Description 单点登录回调重定向到前端时附带的login_code只能使用一次，有效期2分钟。开启两步验证的用户返回models.TwoFactorChallenge
+// @Tags SSO
+// @Accept json
+// @Produce json
+// @Param request body models.SSOExchangeRequest true "登录码"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/auth/oidc/exchange [post]
+func (h *SSOHandler) Exchange(c *gin.Context) {
+	var req models.SSOExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "请求参数无效: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.ssoService.RedeemLoginCode(c.Request.Context(), req.LoginCode)
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+	h.login(c, user, "")
+}
+
+// ListIdentities 获取关联的外部身份
+// @Summary 获取关联的外部身份
+// @Description 当前用户通过单点登录关联的外部身份
+// @Tags SSO
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.IdentityListResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/auth/identities [get]
+func (h *SSOHandler) ListIdentities(c *gin.Context) {
+	identities, err := h.ssoService.ListIdentities(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.IdentityListResponse{Identities: identities})
+}
+
+// UnlinkIdentity 解除外部身份的关联
+// @Summary 解除外部身份的关联
+// @Description 解除后不能再通过该身份登录；没有设置密码的账号不能解除最后一个外部身份
+// @Tags SSO
+// @Produce json
+// @Security BearerAuth
+// @Param identity_id path string true "外部身份ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/auth/identities/{identity_id} [delete]
+func (h *SSOHandler) UnlinkIdentity(c *gin.Context) {
+	if err := h.ssoService.Unlink(c.Request.Context(), c.GetString("user_id"), c.Param("identity_id")); err != nil {
+		respondSSOError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "外部身份已解除关联"})
+}
+
+// login 为单点登录的用户签发令牌，开启两步验证时返回challenge
+func (h *SSOHandler) login(c *gin.Context, user *models.User, provider string) {
+	method := "oidc"
+	if provider != "" {
+		method += ":" + provider
+	}
+	response, challenge, err := h.authService.LoginExternal(user, clientInfo(c), method)
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// setStateCookie 设置或清除（maxAge<0）state绑定cookie，只在该提供方的登录和回调路径下发送
+// SameSite=Lax允许身份提供方重定向回来时（顶层GET导航）携带cookie
+func (h *SSOHandler) setStateCookie(c *gin.Context, provider, value string, maxAge int) {
+	secure := strings.HasPrefix(h.urls.Base(c.Request), "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, value, maxAge, "/api/v1/auth/oidc/"+url.PathEscape(provider), "", secure, true)
+}
+
+// callbackError 配置了前端页面时以error参数重定向，否则返回JSON
+func (h *SSOHandler) callbackError(c *gin.Context, err error) {
+	if h.postLoginURL == "" {
+		respondSSOError(c, err)
+		return
+	}
+	code, _ := ssoErrorCode(err)
+	c.Redirect(http.StatusFound, withQuery(h.postLoginURL, "error", code))
+}
+
+func respondSSOError(c *gin.Context, err error) {
+	code, status := ssoErrorCode(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("SSO request failed: %v", err)
+		message = "单点登录失败"
+	}
+	c.JSON(status, models.ErrorResponse{
+		Error:   code,
+		Message: message,
+	})
+}
+
+func ssoErrorCode(err error) (string, int) {
+	switch {
+	case errors.Is(err, services.ErrSSOProviderNotFound):
+		return "provider_not_found", http.StatusNotFound
+	case errors.Is(err, services.ErrSSOProviderUnavailable):
+		return "provider_unavailable", http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrSSOInvalidState):
+		return "invalid_state", http.StatusBadRequest
+	case errors.Is(err, services.ErrSSOAuthFailed), errors.Is(err, services.ErrUserNotFound):
+		return "sso_failed", http.StatusUnauthorized
+	case errors.Is(err, services.ErrSSOEmailNotVerified):
+		return "email_not_verified", http.StatusForbidden
+	case errors.Is(err, services.ErrSSODomainNotAllowed):
+		return "domain_not_allowed", http.StatusForbidden
+	case errors.Is(err, services.ErrSSOSignupDisabled):
+		return "signup_disabled", http.StatusForbidden
+	case errors.Is(err, services.ErrSSOAccountUnverified):
+		return "account_not_verified", http.StatusForbidden
+	case errors.Is(err, services.ErrIdentityNotFound):
+		return "identity_not_found", http.StatusNotFound
+	case errors.Is(err, services.ErrLastLoginMethod):
+		return "last_login_method", http.StatusConflict
+	default:
+		return "sso_failed", http.StatusInternalServerError
+	}
+}
+
+func withQuery(link, key, value string) string {
+	sep := "?"
+	if strings.Contains(link, "?") {
+		sep = "&"
+	}
+	return link + sep + key + "=" + url.QueryEscape(value)
+}
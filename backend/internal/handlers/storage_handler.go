@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+	"3d-model-generator-backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StorageHandler struct {
+	retentionService *services.RetentionService
+	storage          *storage.LocalStorage
+	signer           *storage.URLSigner
+}
+
+func NewStorageHandler(retentionService *services.RetentionService, store *storage.LocalStorage, signer *storage.URLSigner) *StorageHandler {
+	return &StorageHandler{
+		retentionService: retentionService,
+		storage:          store,
+		signer:           signer,
+	}
+}
+
+// ServeFile 提供存储中的文件
+// @Summary 获取存储文件
+// @Description 按内容哈希命名的上传图片公开访问；生成结果、派生模型和缩略图需使用接口返回的签名地址（expires和signature参数），
+// @Description 签名过期后重新获取任务即可得到新地址。其他文件不对外提供
+// @Tags Storage
+// @Param filepath path string true "对象路径"
+// @Param expires query int false "签名过期时间（Unix秒）"
+// @Param signature query string false "签名"
+// @Success 200 {file} binary
+// @Failure 404 {object} models.ErrorResponse
+// @Router /uploads/{filepath} [get]
+func (h *StorageHandler) ServeFile(c *gin.Context) {
+	key := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+	public := h.signer.Public(key)
+	allowed := public || h.signer.Verify(key, c.Query("expires"), c.Query("signature"))
+
+	// 没有权限与文件不存在的响应相同，不暴露私有文件是否存在
+	info, err := h.storage.Stat(key)
+	if !allowed || err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "文件不存在或链接已过期",
+		})
+		return
+	}
+	filePath, _ := h.storage.Path(key)
+
+	if !public {
+		c.Header("Cache-Control", "private, no-store")
+	}
+	c.File(filePath)
+}
+
+// GetUsage 获取当前用户的存储用量
+// @Summary 获取存储用量
+// @Description 获取当前用户上传图片、生成结果、派生模型和缩略图占用的存储空间
+// @Tags Storage
+// @Produce json
+// @Success 200 {object} models.StorageUsage
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/storage/usage [get]
+func (h *StorageHandler) GetUsage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "用户未认证",
+		})
+		return
+	}
+
+	usage, err := h.retentionService.GetUserUsage(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get storage usage",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// GetUsageByUser 获取所有用户的存储用量（管理员）
+// @Summary 获取所有用户的存储用量
+// @Description 按占用空间降序返回每个用户的存储用量
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} []models.StorageUsage
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/storage/usage [get]
+func (h *StorageHandler) GetUsageByUser(c *gin.Context) {
+	usage, err := h.retentionService.GetUsageByUser(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to get storage usage",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// GetRetentionReport 预览存储清理结果（管理员）
+// @Summary 预览存储清理
+// @Description 以dry-run方式运行清理，返回将被删除的过期和孤立文件，不做任何删除
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.RetentionReport
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/retention/report [get]
+func (h *StorageHandler) GetRetentionReport(c *gin.Context) {
+	report, err := h.retentionService.Sweep(c.Request.Context(), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to build retention report",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// RunRetentionSweep 立即执行存储清理（管理员）
+// @Summary 执行存储清理
+// @Description 立即删除过期和孤立文件，dry_run=true时等同于预览
+// @Tags Admin
+// @Produce json
+// @Param dry_run query bool false "只预览不删除" default(false)
+// @Success 200 {object} models.RetentionReport
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/retention/sweep [post]
+func (h *StorageHandler) RunRetentionSweep(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	report, err := h.retentionService.Sweep(c.Request.Context(), dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Retention sweep failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// signFiles 为结果文件中的站内私有对象生成签名地址
+func signFiles(signer *storage.URLSigner, files []models.File3D) {
+	for i := range files {
+		files[i].URL = signer.Sign(files[i].URL)
+		files[i].PreviewImageURL = signer.Sign(files[i].PreviewImageURL)
+	}
+}
+
+// signJobFiles 为任务的结果文件和缩略图生成签名地址
+func signJobFiles(signer *storage.URLSigner, job *models.GenerationJob) {
+	signFiles(signer, job.ResultFiles)
+	for i := range job.Thumbnails {
+		job.Thumbnails[i].URL = signer.Sign(job.Thumbnails[i].URL)
+	}
+}
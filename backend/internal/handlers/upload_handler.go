@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/services"
+	"3d-model-generator-backend/internal/urls"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// multipartOverhead 为multipart边界和其他表单字段预留的请求体大小
+	multipartOverhead = 1024 * 1024
+
+	defaultPreviewSize = 256
+	maxPreviewSize     = 1024
+)
+
+type UploadHandler struct {
+	uploadService *services.UploadService
+	urls          *urls.Builder
+}
+
+func NewUploadHandler(uploadService *services.UploadService, urlBuilder *urls.Builder) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploadService,
+		urls:          urlBuilder,
+	}
+}
+
+// UploadResponse 上传响应结构
+type UploadResponse struct {
+	UploadID     string    `json:"upload_id"`
+	ImageURL     string    `json:"image_url"`
+	PreviewURL   string    `json:"preview_url"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	Deduplicated bool      `json:"deduplicated,omitempty"` // 已上传过相同内容的图片，返回原有记录
+	CreatedAt    time.Time `json:"created_at"`
+	Message      string    `json:"message,omitempty"`
+}
+
+// UploadSetResponse 多视角图片组
+type UploadSetResponse struct {
+	SetID string             `json:"set_id"`
+	Views []models.ViewImage `json:"views"`
+}
+
+// UploadListResponse 图片库列表
+type UploadListResponse struct {
+	Uploads []UploadResponse `json:"uploads"`
+	Total   int64            `json:"total"`
+	Limit   int              `json:"limit"`
+	Offset  int              `json:"offset"`
+}
+
+// UploadImage 上传图片文件
+// @Summary 上传图片文件
+// @Description 上传图片文件用于3D模型生成，根据文件内容识别格式（JPG/PNG/WEBP），校验大小（8MB以内）和分辨率（128-5000像素），并去除EXIF/GPS等元数据
+// @Tags Upload
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "图片文件"
+// @Success 200 {object} UploadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/upload/image [post]
+func (h *UploadHandler) UploadImage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "用户未认证",
+		})
+		return
+	}
+
+	// 检查Content-Type
+	contentType := c.GetHeader("Content-Type")
+	if !strings.Contains(contentType, "multipart/form-data") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid content type",
+			Message: "Content-Type must be multipart/form-data",
+		})
+		return
+	}
+
+	// 限制请求体大小，超出后读取会直接报错而不是继续接收
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxUploadSize+multipartOverhead)
+
+	// 使用原生multipart解析，以流的方式读取文件
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "File upload failed",
+			Message: "Failed to create multipart reader: " + err.Error(),
+		})
+		return
+	}
+
+	part, err := nextFilePart(reader)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondUploadError(c, err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "File upload failed",
+			Message: "Failed to read multipart body: " + err.Error(),
+		})
+		return
+	}
+	if part == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "File upload failed",
+			Message: "No file found in request. Please use field name 'file', 'image', or 'upload'",
+		})
+		return
+	}
+	defer part.Close()
+
+	upload, existing, err := h.uploadService.SaveImage(c.Request.Context(), userID.(string), part.FileName(), part)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.newUploadResponse(c, upload, existing))
+}
+
+// CreateSession 创建断点续传会话
+// @Summary 创建断点续传会话
+// @Description 声明文件大小（及可选的SHA-256），之后通过PATCH分片上传，最后调用complete生成上传记录。
+// @Description 同时指定set_id和view_type（front、back、left、right）时，完成后图片加入该多视角图片组的对应视角
+// @Tags Upload
+// @Accept json
+// @Produce json
+// @Param request body models.CreateUploadSessionRequest true "会话参数"
+// @Success 201 {object} models.UploadSessionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /api/v1/uploads/sessions [post]
+func (h *UploadHandler) CreateSession(c *gin.Context) {
+	var req models.CreateUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	session, err := h.uploadService.CreateSession(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.JSON(http.StatusCreated, newSessionResponse(session))
+}
+
+// GetSession 获取断点续传会话状态
+// @Summary 获取断点续传会话状态
+// @Description 返回已接收的字节数，客户端断线后从该偏移继续上传
+// @Tags Upload
+// @Produce json
+// @Param session_id path string true "会话ID"
+// @Success 200 {object} models.UploadSessionResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/uploads/sessions/{session_id} [get]
+func (h *UploadHandler) GetSession(c *gin.Context) {
+	session, err := h.uploadService.GetSession(c.Request.Context(), c.GetString("user_id"), c.Param("session_id"))
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.JSON(http.StatusOK, newSessionResponse(session))
+}
+
+// UploadChunk 上传分片
+// @Summary 上传分片
+// @Description 请求体为分片的原始字节。Upload-Offset头必须等于服务端已接收的字节数，Upload-Checksum头格式为"sha256 <base64摘要>"
+// @Tags Upload
+// @Accept application/offset+octet-stream
+// @Produce json
+// @Param session_id path string true "会话ID"
+// @Param Upload-Offset header int true "分片起始偏移"
+// @Param Upload-Checksum header string true "分片校验和"
+// @Success 200 {object} models.UploadSessionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 460 {object} models.ErrorResponse
+// @Router /api/v1/uploads/sessions/{session_id} [patch]
+func (h *UploadHandler) UploadChunk(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid offset",
+			Message: "Upload-Offset header must be a non-negative integer",
+		})
+		return
+	}
+
+	checksum, err := parseChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxChunkSize+1)
+	session, err := h.uploadService.AppendChunk(c.Request.Context(), c.GetString("user_id"), c.Param("session_id"), offset, checksum, c.Request.Body)
+	if session != nil {
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	}
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newSessionResponse(session))
+}
+
+// CompleteSession 完成断点续传
+// @Summary 完成断点续传
+// @Description 所有分片上传完成后校验完整文件，生成与普通上传相同的上传记录
+// @Tags Upload
+// @Produce json
+// @Param session_id path string true "会话ID"
+// @Success 200 {object} UploadResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 460 {object} models.ErrorResponse
+// @Router /api/v1/uploads/sessions/{session_id}/complete [post]
+func (h *UploadHandler) CompleteSession(c *gin.Context) {
+	upload, existing, err := h.uploadService.CompleteSession(c.Request.Context(), c.GetString("user_id"), c.Param("session_id"))
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.newUploadResponse(c, upload, existing))
+}
+
+// AbortSession 取消断点续传
+// @Summary 取消断点续传
+// @Description 删除会话及已接收的数据
+// @Tags Upload
+// @Param session_id path string true "会话ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/uploads/sessions/{session_id} [delete]
+func (h *UploadHandler) AbortSession(c *gin.Context) {
+	if err := h.uploadService.AbortSession(c.Request.Context(), c.GetString("user_id"), c.Param("session_id")); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListUploads 获取图片库
+// @Summary 获取图片库
+// @Description 分页获取当前用户上传的图片，按上传时间倒序
+// @Tags Upload
+// @Produce json
+// @Param limit query int false "限制数量" default(20)
+// @Param offset query int false "偏移量" default(0)
+// @Success 200 {object} UploadListResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/uploads [get]
+func (h *UploadHandler) ListUploads(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	uploads, total, err := h.uploadService.ListUploads(c.Request.Context(), c.GetString("user_id"), limit, offset)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	response := UploadListResponse{
+		Uploads: make([]UploadResponse, 0, len(uploads)),
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}
+	for i := range uploads {
+		item := h.newUploadResponse(c, &uploads[i], false)
+		item.Message = ""
+		response.Uploads = append(response.Uploads, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUpload 获取单张上传图片
+// @Summary 获取单张上传图片
+// @Description 获取图片库中单张图片的信息
+// @Tags Upload
+// @Produce json
+// @Param upload_id path string true "上传ID"
+// @Success 200 {object} UploadResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/uploads/{upload_id} [get]
+func (h *UploadHandler) GetUpload(c *gin.Context) {
+	upload, err := h.uploadService.GetUpload(c.Request.Context(), c.GetString("user_id"), c.Param("upload_id"))
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	response := h.newUploadResponse(c, upload, false)
+	response.Message = ""
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUploadSet 获取多视角图片组
+// @Summary 获取多视角图片组
+// @Description 按front、back、left、right的顺序返回图片组中各视角的图片，生成请求中通过set_id使用图片组，正面图作为主图，其他视角作为multi_view_images提交
+// @Tags Upload
+// @Produce json
+// @Param set_id path string true "图片组ID"
+// @Success 200 {object} UploadSetResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/uploads/sets/{set_id} [get]
+func (h *UploadHandler) GetUploadSet(c *gin.Context) {
+	views, err := h.uploadService.GetUploadSet(c.Request.Context(), c.GetString("user_id"), c.Param("set_id"))
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	for i := range views {
+		views[i].ViewImageURL = h.urls.Absolute(c.Request, views[i].ViewImageURL)
+	}
+	c.JSON(http.StatusOK, UploadSetResponse{SetID: c.Param("set_id"), Views: views})
+}
+
+// PreviewUpload 获取上传图片的预览图
+// @Summary 获取上传图片的预览图
+// @Description 返回缩小后的预览图，size为最长边像素（最大1024）
+// @Tags Upload
+// @Produce image/jpeg
+// @Produce image/png
+// @Param upload_id path string true "上传ID"
+// @Param size query int false "最长边像素" default(256)
+// @Success 200 {file} binary
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/uploads/{upload_id}/preview [get]
+func (h *UploadHandler) PreviewUpload(c *gin.Context) {
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(defaultPreviewSize)))
+	if err != nil || size <= 0 {
+		size = defaultPreviewSize
+	}
+	size = min(size, maxPreviewSize)
+
+	data, format, err := h.uploadService.Preview(c.Request.Context(), c.GetString("user_id"), c.Param("upload_id"), size)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, format.MIMEType(), data)
+}
+
+// DeleteUpload 删除上传图片
+// @Summary 删除上传图片
+// @Description 从图片库中删除图片，没有其他引用时同时删除文件
+// @Tags Upload
+// @Param upload_id path string true "上传ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/uploads/{upload_id} [delete]
+func (h *UploadHandler) DeleteUpload(c *gin.Context) {
+	if err := h.uploadService.DeleteUpload(c.Request.Context(), c.GetString("user_id"), c.Param("upload_id")); err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UploadHandler) newUploadResponse(c *gin.Context, upload *models.Upload, existing bool) UploadResponse {
+	message := "Image uploaded successfully"
+	if existing {
+		message = "Image already uploaded"
+	}
+	return UploadResponse{
+		UploadID:     upload.ID,
+		ImageURL:     h.urls.Absolute(c.Request, upload.URL),
+		PreviewURL:   h.urls.Absolute(c.Request, fmt.Sprintf("/api/v1/uploads/%s/preview", upload.ID)),
+		Filename:     upload.OriginalName,
+		ContentType:  upload.ContentType,
+		Width:        upload.Width,
+		Height:       upload.Height,
+		SHA256:       upload.SHA256,
+		Size:         upload.Size,
+		Deduplicated: existing,
+		CreatedAt:    upload.CreatedAt,
+		Message:      message,
+	}
+}
+
+func newSessionResponse(session *models.UploadSession) models.UploadSessionResponse {
+	return models.UploadSessionResponse{
+		UploadSession: *session,
+		ChunkSize:     services.DefaultChunkSize,
+		MaxChunk:      services.MaxChunkSize,
+	}
+}
+
+// parseChecksum 解析"sha256 <base64摘要>"格式的校验和
+func parseChecksum(header string) ([]byte, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(algorithm, "sha256") {
+		return nil, fmt.Errorf("%w: Upload-Checksum header must be \"sha256 <base64 digest>\"", services.ErrInvalidChecksum)
+	}
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("%w: malformed sha256 digest", services.ErrInvalidChecksum)
+	}
+	return digest, nil
+}
+
+// nextFilePart 查找第一个文件字段，没有找到时返回nil
+func nextFilePart(reader *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		formName := part.FormName()
+		if part.FileName() != "" || formName == "file" || formName == "image" || formName == "upload" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// respondUploadError 将上传相关的错误映射为HTTP响应
+func respondUploadError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, services.ErrUploadTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error:   "File too large",
+			Message: fmt.Sprintf("File size must be less than %dMB", services.MaxUploadSize/1024/1024),
+		})
+	case errors.Is(err, services.ErrChunkTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error:   "Chunk too large",
+			Message: fmt.Sprintf("Chunk must not exceed the remaining size or %dMB", services.MaxChunkSize/1024/1024),
+		})
+	case errors.Is(err, services.ErrUnsupportedImage):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid file type",
+			Message: "Only JPG, JPEG, PNG, and WEBP images are allowed",
+		})
+	case errors.Is(err, services.ErrCorruptImage):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid image",
+			Message: "The image data is truncated or corrupt",
+		})
+	case errors.Is(err, services.ErrImageDimensions):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid image dimensions",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Upload not found",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Upload session not found",
+			Message: "The upload session does not exist or has expired",
+		})
+	case errors.Is(err, services.ErrTooManySessions):
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "Too many upload sessions",
+			Message: "Complete or abort existing upload sessions before starting new ones",
+		})
+	case errors.Is(err, services.ErrInvalidViewSet), errors.Is(err, services.ErrMissingFrontView):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid view set",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrViewInProgress):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "View upload in progress",
+			Message: "Complete or abort the existing upload session for this view first",
+		})
+	case errors.Is(err, services.ErrOffsetMismatch):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Offset mismatch",
+			Message: "Upload-Offset does not match the received size, resume from the Upload-Offset response header",
+		})
+	case errors.Is(err, services.ErrUploadIncomplete):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Upload incomplete",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidChecksum):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid checksum",
+			Message: err.Error(),
+		})
+	case errors.Is(err, services.ErrChecksumMismatch):
+		// 460 Checksum Mismatch，与tus协议一致
+		c.JSON(460, models.ErrorResponse{
+			Error:   "Checksum mismatch",
+			Message: "The received data does not match the provided checksum",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "File upload failed",
+			Message: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // 注册JPEG解码器
+	_ "image/png"  // 注册PNG解码器
+
+	_ "golang.org/x/image/webp" // 注册WebP解码器
+)
+
+// Format 支持的图片格式
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+)
+
+// MaxPixels 允许解码的最大像素数。图片头中的尺寸由文件声明，几KB的PNG或GIF就能声明50000×50000，
+// 解码和转换为RGBA时会分配数GB内存，因此在完整解码之前按图片头拒绝
+const MaxPixels = 40_000_000
+
+// ErrTooManyPixels 图片声明的像素数超过MaxPixels
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// DetectFormat 根据文件头的魔数判断图片格式，不信任扩展名和客户端提供的Content-Type
+func DetectFormat(data []byte) (Format, bool) {
+	switch {
+	case len(data) >= 3 && data[0] == 0xff && data[1] == 0xd8 && data[2] == 0xff:
+		return FormatJPEG, true
+	case bytes.HasPrefix(data, pngSignature):
+		return FormatPNG, true
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP, true
+	}
+	return "", false
+}
+
+// MIMEType 返回格式对应的MIME类型
+func (f Format) MIMEType() string {
+	return "image/" + string(f)
+}
+
+// Ext 返回格式对应的文件扩展名
+func (f Format) Ext() string {
+	if f == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// DecodeConfig 只解码图片头，获取宽高并校验格式与魔数一致
+func DecodeConfig(data []byte) (image.Config, Format, error) {
+	format, ok := DetectFormat(data)
+	if !ok {
+		return image.Config{}, "", fmt.Errorf("unsupported image format")
+	}
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return image.Config{}, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if name != string(format) {
+		return image.Config{}, "", fmt.Errorf("image format mismatch: %s", name)
+	}
+	if err := checkPixels(cfg); err != nil {
+		return image.Config{}, "", err
+	}
+	return cfg, format, nil
+}
+
+// checkPixels 在完整解码之前检查图片头声明的尺寸
+func checkPixels(cfg image.Config) error {
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return fmt.Errorf("invalid image dimensions: %dx%d", cfg.Width, cfg.Height)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return fmt.Errorf("%w: %dx%d, at most %d pixels", ErrTooManyPixels, cfg.Width, cfg.Height, MaxPixels)
+	}
+	return nil
+}
+
+// Verify 完整解码图片，拒绝图片头有效但数据截断或损坏的文件
+// 解码会按图片头声明的尺寸分配内存，调用前须先通过DecodeConfig检查像素数
+func Verify(data []byte) error {
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	return nil
+}
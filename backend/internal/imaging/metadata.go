@@ -0,0 +1,324 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// ErrMalformed 图片结构损坏，无法安全地解析元数据
+var ErrMalformed = errors.New("malformed image data")
+
+var exifHeader = []byte("Exif\x00\x00")
+
+const (
+	tagOrientation  = 0x0112
+	webpFlagXMP     = 0x04
+	webpFlagEXIF    = 0x08
+	jpegMarkerSOS   = 0xda
+	jpegMarkerEOI   = 0xd9
+	jpegMarkerAPP0  = 0xe0
+	jpegMarkerAPP1  = 0xe1
+	jpegMarkerAPP2  = 0xe2
+	jpegMarkerAPP14 = 0xee
+)
+
+// Orientation 读取EXIF中的方向标记（1-8），没有EXIF或解析失败时返回1
+func Orientation(data []byte, format Format) int {
+	orientation := 1
+	switch format {
+	case FormatJPEG:
+		walkJPEG(data, func(marker byte, segment, payload []byte) {
+			if marker == jpegMarkerAPP1 && bytes.HasPrefix(payload, exifHeader) && orientation == 1 {
+				orientation = tiffOrientation(payload[len(exifHeader):])
+			}
+		})
+	case FormatPNG:
+		walkPNG(data, func(typ string, chunk, payload []byte) {
+			if typ == "eXIf" && orientation == 1 {
+				orientation = tiffOrientation(payload)
+			}
+		})
+	case FormatWebP:
+		walkWebP(data, func(fourcc string, chunk, payload []byte) {
+			if fourcc == "EXIF" && orientation == 1 {
+				orientation = tiffOrientation(bytes.TrimPrefix(payload, exifHeader))
+			}
+		})
+	}
+	return orientation
+}
+
+// StripMetadata 在字节层面移除EXIF（含GPS）、XMP、IPTC、注释和文本块，不重新编码像素数据
+// 为了不让图片显示方向出错，EXIF方向不为1时会写回一个只包含方向标记的最小EXIF
+func StripMetadata(data []byte, format Format) ([]byte, error) {
+	orientation := Orientation(data, format)
+	switch format {
+	case FormatJPEG:
+		return stripJPEG(data, orientation)
+	case FormatPNG:
+		return stripPNG(data, orientation)
+	case FormatWebP:
+		return stripWebP(data, orientation)
+	}
+	return nil, ErrMalformed
+}
+
+// stripJPEG 只保留JFIF(APP0)、ICC配置(APP2)和Adobe(APP14)段，其他APPn段和注释全部移除
+func stripJPEG(data []byte, orientation int) ([]byte, error) {
+	var segments [][]byte
+	sos, err := walkJPEG(data, func(marker byte, segment, payload []byte) {
+		switch {
+		case marker == jpegMarkerAPP0, marker == jpegMarkerAPP14:
+		case marker == jpegMarkerAPP2 && bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00")):
+		case marker >= jpegMarkerAPP0 && marker <= 0xef, marker == 0xfe:
+			return
+		}
+		segments = append(segments, segment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	// JFIF要求APP0紧跟在SOI之后，EXIF段放在它后面
+	insertAt := 0
+	if len(segments) > 0 && segments[0][1] == jpegMarkerAPP0 {
+		insertAt = 1
+	}
+	for i, segment := range segments {
+		if i == insertAt && orientation != 1 {
+			out = appendJPEGExif(out, orientation)
+		}
+		out = append(out, segment...)
+	}
+	if insertAt >= len(segments) && orientation != 1 {
+		out = appendJPEGExif(out, orientation)
+	}
+	return append(out, data[sos:]...), nil
+}
+
+// walkJPEG 遍历SOS之前的所有标记段，返回SOS（或EOI）的位置
+func walkJPEG(data []byte, fn func(marker byte, segment, payload []byte)) (int, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return 0, ErrMalformed
+	}
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xff {
+			return 0, ErrMalformed
+		}
+		// 标记前允许有多个0xFF填充字节
+		for pos+1 < len(data) && data[pos+1] == 0xff {
+			pos++
+		}
+		if pos+1 >= len(data) {
+			return 0, ErrMalformed
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == jpegMarkerSOS, marker == jpegMarkerEOI:
+			return pos, nil
+		case marker == 0x01, marker >= 0xd0 && marker <= 0xd7:
+			// 没有长度字段的独立标记
+			fn(marker, data[pos:pos+2], nil)
+			pos += 2
+			continue
+		}
+		if pos+4 > len(data) {
+			return 0, ErrMalformed
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 0, ErrMalformed
+		}
+		fn(marker, data[pos:pos+2+length], data[pos+4:pos+2+length])
+		pos += 2 + length
+	}
+	return 0, ErrMalformed
+}
+
+func appendJPEGExif(out []byte, orientation int) []byte {
+	payload := append(append([]byte{}, exifHeader...), minimalTIFF(orientation)...)
+	out = append(out, 0xff, jpegMarkerAPP1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+// stripPNG 移除eXIf和文本块（tEXt/zTXt/iTXt）以及修改时间（tIME）
+func stripPNG(data []byte, orientation int) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	exifWritten := orientation == 1
+
+	err := walkPNG(data, func(typ string, chunk, payload []byte) {
+		switch typ {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+			return
+		case "IDAT":
+			// eXIf必须位于图像数据之前
+			if !exifWritten {
+				out = appendPNGChunk(out, "eXIf", minimalTIFF(orientation))
+				exifWritten = true
+			}
+		}
+		out = append(out, chunk...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// walkPNG 遍历PNG的所有数据块直到IEND
+func walkPNG(data []byte, fn func(typ string, chunk, payload []byte)) error {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return ErrMalformed
+	}
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		if length < 0 || length > len(data)-pos-12 {
+			return ErrMalformed
+		}
+		end := pos + 12 + length
+		typ := string(data[pos+4 : pos+8])
+		fn(typ, data[pos:end], data[pos+8:pos+8+length])
+		pos = end
+		if typ == "IEND" {
+			return nil
+		}
+	}
+	return ErrMalformed
+}
+
+func appendPNGChunk(out []byte, typ string, payload []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(payload)))
+	start := len(out)
+	out = append(out, typ...)
+	out = append(out, payload...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
+
+// stripWebP 移除EXIF和XMP块，并同步更新VP8X头部的标志位和RIFF长度
+func stripWebP(data []byte, orientation int) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	vp8x := -1
+
+	err := walkWebP(data, func(fourcc string, chunk, payload []byte) {
+		switch fourcc {
+		case "EXIF", "XMP ":
+			return
+		case "VP8X":
+			if len(payload) >= 10 {
+				vp8x = len(out)
+			}
+		}
+		out = append(out, chunk...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 只有扩展格式（VP8X）才能携带元数据
+	if vp8x >= 0 {
+		flags := out[vp8x+8] &^ (webpFlagXMP | webpFlagEXIF)
+		if orientation != 1 {
+			out = appendWebPChunk(out, "EXIF", minimalTIFF(orientation))
+			flags |= webpFlagEXIF
+		}
+		out[vp8x+8] = flags
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// walkWebP 遍历RIFF容器中的所有块
+func walkWebP(data []byte, fn func(fourcc string, chunk, payload []byte)) error {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return ErrMalformed
+	}
+	pos := 12
+	for pos+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size < 0 || size > len(data)-pos-8 {
+			return ErrMalformed
+		}
+		end := pos + 8 + size
+		// 奇数长度的块有一个填充字节，最后一个块允许缺少填充
+		if size%2 == 1 && end < len(data) {
+			end++
+		}
+		fn(string(data[pos:pos+4]), data[pos:end], data[pos+8:pos+8+size])
+		pos = end
+	}
+	if pos != len(data) {
+		return ErrMalformed
+	}
+	return nil
+}
+
+func appendWebPChunk(out []byte, fourcc string, payload []byte) []byte {
+	out = append(out, fourcc...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(payload)))
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// tiffOrientation 从TIFF结构的第0个IFD中读取方向标记
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		// 方向标记的类型为SHORT(3)，值直接存放在条目中
+		if order.Uint16(tiff[entry:]) == tagOrientation && order.Uint16(tiff[entry+2:]) == 3 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// minimalTIFF 生成只包含方向标记的TIFF结构
+func minimalTIFF(orientation int) []byte {
+	b := make([]byte, 0, 26)
+	b = append(b, 'M', 'M', 0, 42)
+	b = binary.BigEndian.AppendUint32(b, 8) // IFD0偏移
+	b = binary.BigEndian.AppendUint16(b, 1) // 条目数
+	b = binary.BigEndian.AppendUint16(b, tagOrientation)
+	b = binary.BigEndian.AppendUint16(b, 3) // SHORT
+	b = binary.BigEndian.AppendUint32(b, 1) // 数量
+	b = binary.BigEndian.AppendUint16(b, uint16(orientation))
+	b = append(b, 0, 0)
+	return binary.BigEndian.AppendUint32(b, 0) // 没有下一个IFD
+}
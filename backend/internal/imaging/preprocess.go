@@ -0,0 +1,246 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册GIF解码器，只取第一帧
+	"image/jpeg"
+	"image/png"
+
+	_ "golang.org/x/image/bmp" // 注册BMP解码器
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff" // 注册TIFF解码器
+)
+
+// PreprocessOptions 预处理参数
+type PreprocessOptions struct {
+	MinDimension int // 短边不足时放大或补边
+	MaxDimension int // 长边超出时缩小
+	MaxBytes     int // 编码后的最大字节数
+}
+
+// PreprocessResult 预处理结果
+type PreprocessResult struct {
+	Data           []byte
+	Format         Format
+	Width          int
+	Height         int
+	OriginalFormat string
+	OriginalWidth  int
+	OriginalHeight int
+	OriginalSize   int
+	Operations     []string // 实际执行的处理步骤，没有修改时为空
+}
+
+// JPEG重新压缩时依次尝试的质量
+var jpegQualities = []int{92, 85, 75, 65, 55}
+
+// Preprocess 将任意可解码的图片转换为符合要求的JPEG/PNG：
+// 应用EXIF方向、缩小过大的图片、放大或补边过小的图片，并在超出大小限制时重新压缩
+// 已经符合要求的JPEG/PNG原样返回
+func Preprocess(data []byte, opts PreprocessOptions) (*PreprocessResult, error) {
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkPixels(cfg); err != nil {
+		return nil, err
+	}
+
+	result := &PreprocessResult{
+		OriginalFormat: name,
+		OriginalWidth:  cfg.Width,
+		OriginalHeight: cfg.Height,
+		OriginalSize:   len(data),
+	}
+
+	format, known := DetectFormat(data)
+	orientation := 1
+	if known {
+		orientation = Orientation(data, format)
+	}
+
+	if (format == FormatJPEG || format == FormatPNG) && orientation == 1 &&
+		withinBounds(cfg.Width, cfg.Height, opts) && (opts.MaxBytes <= 0 || len(data) <= opts.MaxBytes) {
+		result.Data = data
+		result.Format = format
+		result.Width = cfg.Width
+		result.Height = cfg.Height
+		return result, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	img := toRGBA(src)
+
+	if orientation != 1 {
+		img = applyOrientation(img, orientation)
+		result.Operations = append(result.Operations, fmt.Sprintf("orient:%d", orientation))
+	}
+
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); opts.MaxDimension > 0 && max(w, h) > opts.MaxDimension {
+		scale := float64(opts.MaxDimension) / float64(max(w, h))
+		img = resize(img, scaled(w, scale), scaled(h, scale))
+		result.Operations = append(result.Operations, fmt.Sprintf("downscale:%dx%d->%dx%d", w, h, img.Bounds().Dx(), img.Bounds().Dy()))
+	}
+
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); opts.MinDimension > 0 && min(w, h) < opts.MinDimension {
+		scale := float64(opts.MinDimension) / float64(min(w, h))
+		if opts.MaxDimension <= 0 || scaled(max(w, h), scale) <= opts.MaxDimension {
+			img = resize(img, scaled(w, scale), scaled(h, scale))
+			result.Operations = append(result.Operations, fmt.Sprintf("upscale:%dx%d->%dx%d", w, h, img.Bounds().Dx(), img.Bounds().Dy()))
+		} else {
+			// 长宽比过于悬殊，放大会超出上限，只能补边
+			img = pad(img, max(w, opts.MinDimension), max(h, opts.MinDimension))
+			result.Operations = append(result.Operations, fmt.Sprintf("pad:%dx%d->%dx%d", w, h, img.Bounds().Dx(), img.Bounds().Dy()))
+		}
+	}
+
+	if err := encode(img, opts, result); err != nil {
+		return nil, err
+	}
+	if result.OriginalFormat != string(result.Format) {
+		result.Operations = append(result.Operations, fmt.Sprintf("convert:%s->%s", result.OriginalFormat, result.Format))
+	}
+	return result, nil
+}
+
+// encode 有透明通道时优先输出PNG，否则输出JPEG；超出大小限制时逐步降低质量，最后缩小尺寸
+func encode(img *image.RGBA, opts PreprocessOptions, result *PreprocessResult) error {
+	fits := func(data []byte) bool {
+		return opts.MaxBytes <= 0 || len(data) <= opts.MaxBytes
+	}
+
+	if !img.Opaque() {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return fmt.Errorf("failed to encode png: %w", err)
+		}
+		if fits(buf.Bytes()) {
+			setResult(result, buf.Bytes(), FormatPNG, img)
+			return nil
+		}
+		// PNG放不下时转为白底JPEG
+		img = flatten(img)
+		result.Operations = append(result.Operations, "flatten")
+	}
+
+	for {
+		for i, quality := range jpegQualities {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+				return fmt.Errorf("failed to encode jpeg: %w", err)
+			}
+			if fits(buf.Bytes()) {
+				if i > 0 {
+					result.Operations = append(result.Operations, fmt.Sprintf("recompress:q%d", quality))
+				}
+				setResult(result, buf.Bytes(), FormatJPEG, img)
+				return nil
+			}
+		}
+
+		w, h := img.Bounds().Dx(), img.Bounds().Dy()
+		nw, nh := scaled(w, 0.8), scaled(h, 0.8)
+		if min(nw, nh) < opts.MinDimension {
+			return fmt.Errorf("image cannot be compressed below %d bytes", opts.MaxBytes)
+		}
+		img = resize(img, nw, nh)
+		result.Operations = append(result.Operations, fmt.Sprintf("downscale:%dx%d->%dx%d", w, h, nw, nh))
+	}
+}
+
+func setResult(result *PreprocessResult, data []byte, format Format, img *image.RGBA) {
+	result.Data = data
+	result.Format = format
+	result.Width = img.Bounds().Dx()
+	result.Height = img.Bounds().Dy()
+}
+
+func withinBounds(w, h int, opts PreprocessOptions) bool {
+	if opts.MinDimension > 0 && min(w, h) < opts.MinDimension {
+		return false
+	}
+	if opts.MaxDimension > 0 && max(w, h) > opts.MaxDimension {
+		return false
+	}
+	return true
+}
+
+func scaled(v int, scale float64) int {
+	return max(1, int(float64(v)*scale+0.5))
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// pad 将图片居中放到指定大小的画布上，不透明图片用白色补边
+func pad(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if src.Opaque() {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	offset := image.Pt((w-src.Bounds().Dx())/2, (h-src.Bounds().Dy())/2)
+	draw.Draw(dst, src.Bounds().Add(offset), src, image.Point{}, draw.Over)
+	return dst
+}
+
+// flatten 将透明图片合成到白色背景上
+func flatten(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
+
+// applyOrientation 按EXIF方向标记旋转/翻转图片，使其以正常方向显示
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+)
+
+// Thumbnail 生成最长边不超过size的预览图，小图不放大；不透明图片输出JPEG，否则输出PNG
+func Thumbnail(data []byte, size int) ([]byte, Format, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkPixels(cfg); err != nil {
+		return nil, "", err
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	img := toRGBA(src)
+
+	if format, ok := DetectFormat(data); ok {
+		if orientation := Orientation(data, format); orientation != 1 {
+			img = applyOrientation(img, orientation)
+		}
+	}
+
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); size > 0 && max(w, h) > size {
+		scale := float64(size) / float64(max(w, h))
+		img = resize(img, scaled(w, scale), scaled(h, scale))
+	}
+
+	var buf bytes.Buffer
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode jpeg: %w", err)
+		}
+		return buf.Bytes(), FormatJPEG, nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", fmt.Errorf("failed to encode png: %w", err)
+	}
+	return buf.Bytes(), FormatPNG, nil
+}
@@ -0,0 +1,387 @@
+package mesh
+
+import "math"
+
+// Decimate 使用二次误差度量（QEM）的边折叠算法简化网格
+// ratio 为目标面数与原面数之比（0-1）。折叠后的顶点保留原有纹理坐标索引，法线会被丢弃。
+func Decimate(m *Mesh, ratio float64) *Mesh {
+	if ratio >= 1 {
+		return m.Clone()
+	}
+	target := int(float64(len(m.Faces)) * ratio)
+	if target < 4 {
+		target = 4
+	}
+
+	d := newDecimator(m)
+	d.simplify(target, 7)
+	return d.result(m)
+}
+
+// quadric 对称4x4矩阵，只存储上三角的10个元素
+type quadric [10]float64
+
+func planeQuadric(a, b, c, d float64) quadric {
+	return quadric{a * a, a * b, a * c, a * d, b * b, b * c, b * d, c * c, c * d, d * d}
+}
+
+func (q quadric) add(o quadric) quadric {
+	for i := range q {
+		q[i] += o[i]
+	}
+	return q
+}
+
+func (q quadric) det(a11, a12, a13, a21, a22, a23, a31, a32, a33 int) float64 {
+	return q[a11]*q[a22]*q[a33] + q[a13]*q[a21]*q[a32] + q[a12]*q[a23]*q[a31] -
+		q[a13]*q[a22]*q[a31] - q[a11]*q[a23]*q[a32] - q[a12]*q[a21]*q[a33]
+}
+
+func (q quadric) vertexError(p Vec3) float64 {
+	x, y, z := p.X, p.Y, p.Z
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x + q[4]*y*y +
+		2*q[5]*y*z + 2*q[6]*y + q[7]*z*z + 2*q[8]*z + q[9]
+}
+
+type dTriangle struct {
+	v        [3]int
+	vt       [3]int
+	material string
+	err      [4]float64
+	deleted  bool
+	dirty    bool
+	n        Vec3
+}
+
+type dVertex struct {
+	p      Vec3
+	tstart int
+	tcount int
+	q      quadric
+	border bool
+}
+
+type dRef struct {
+	tid     int
+	tvertex int
+}
+
+type decimator struct {
+	triangles []dTriangle
+	vertices  []dVertex
+	refs      []dRef
+}
+
+func newDecimator(m *Mesh) *decimator {
+	d := &decimator{
+		triangles: make([]dTriangle, len(m.Faces)),
+		vertices:  make([]dVertex, len(m.Vertices)),
+	}
+	for i, v := range m.Vertices {
+		d.vertices[i].p = v
+	}
+	for i, f := range m.Faces {
+		d.triangles[i] = dTriangle{v: f.V, vt: f.VT, material: f.Material}
+	}
+	return d
+}
+
+func (d *decimator) simplify(target int, aggressiveness float64) {
+	deleted := 0
+	count := len(d.triangles)
+	var deleted0, deleted1 []bool
+
+	for iteration := 0; iteration < 100; iteration++ {
+		if count-deleted <= target {
+			break
+		}
+
+		// 定期清理已删除的三角形并重建引用表
+		if iteration%5 == 0 {
+			d.updateMesh(iteration)
+		}
+		for i := range d.triangles {
+			d.triangles[i].dirty = false
+		}
+
+		// 阈值随迭代次数增长，优先折叠误差最小的边
+		threshold := 1e-9 * math.Pow(float64(iteration+3), aggressiveness)
+
+		for i := range d.triangles {
+			t := &d.triangles[i]
+			if t.err[3] > threshold || t.deleted || t.dirty {
+				continue
+			}
+
+			for j := 0; j < 3; j++ {
+				if t.err[j] >= threshold {
+					continue
+				}
+				i0 := t.v[j]
+				i1 := t.v[(j+1)%3]
+				v0 := &d.vertices[i0]
+				v1 := &d.vertices[i1]
+				if v0.border != v1.border {
+					continue
+				}
+
+				_, p := d.edgeError(i0, i1)
+
+				deleted0 = resizeBools(deleted0, v0.tcount)
+				deleted1 = resizeBools(deleted1, v1.tcount)
+				if d.flipped(p, i1, v0, deleted0) || d.flipped(p, i0, v1, deleted1) {
+					continue
+				}
+
+				v0.p = p
+				v0.q = v0.q.add(v1.q)
+				tstart := len(d.refs)
+				deleted += d.updateTriangles(i0, v0, deleted0)
+				deleted += d.updateTriangles(i0, v1, deleted1)
+				tcount := len(d.refs) - tstart
+
+				if tcount <= v0.tcount {
+					copy(d.refs[v0.tstart:], d.refs[tstart:tstart+tcount])
+				} else {
+					v0.tstart = tstart
+				}
+				v0.tcount = tcount
+				break
+			}
+
+			if count-deleted <= target {
+				break
+			}
+		}
+	}
+
+	d.compact()
+}
+
+// flipped 检查将顶点移动到p后相邻三角形是否翻转或退化
+func (d *decimator) flipped(p Vec3, i1 int, v0 *dVertex, deleted []bool) bool {
+	for k := 0; k < v0.tcount; k++ {
+		r := d.refs[v0.tstart+k]
+		t := &d.triangles[r.tid]
+		if t.deleted {
+			continue
+		}
+
+		id1 := t.v[(r.tvertex+1)%3]
+		id2 := t.v[(r.tvertex+2)%3]
+		if id1 == i1 || id2 == i1 {
+			deleted[k] = true
+			continue
+		}
+
+		d1 := d.vertices[id1].p.Sub(p).Normalize()
+		d2 := d.vertices[id2].p.Sub(p).Normalize()
+		if math.Abs(d1.Dot(d2)) > 0.999 {
+			return true
+		}
+		n := d1.Cross(d2).Normalize()
+		deleted[k] = false
+		if n.Dot(t.n) < 0.2 {
+			return true
+		}
+	}
+	return false
+}
+
+// updateTriangles 将顶点v的相邻三角形重定向到i0，返回新删除的三角形数
+func (d *decimator) updateTriangles(i0 int, v *dVertex, deleted []bool) int {
+	removed := 0
+	for k := 0; k < v.tcount; k++ {
+		r := d.refs[v.tstart+k]
+		t := &d.triangles[r.tid]
+		if t.deleted {
+			continue
+		}
+		if deleted[k] {
+			t.deleted = true
+			removed++
+			continue
+		}
+		t.v[r.tvertex] = i0
+		t.dirty = true
+		t.err[0], _ = d.edgeError(t.v[0], t.v[1])
+		t.err[1], _ = d.edgeError(t.v[1], t.v[2])
+		t.err[2], _ = d.edgeError(t.v[2], t.v[0])
+		t.err[3] = math.Min(t.err[0], math.Min(t.err[1], t.err[2]))
+		d.refs = append(d.refs, r)
+	}
+	return removed
+}
+
+func (d *decimator) updateMesh(iteration int) {
+	if iteration > 0 {
+		dst := 0
+		for _, t := range d.triangles {
+			if !t.deleted {
+				d.triangles[dst] = t
+				dst++
+			}
+		}
+		d.triangles = d.triangles[:dst]
+	}
+
+	// 首次迭代时计算每个顶点的二次误差矩阵和初始边误差
+	if iteration == 0 {
+		for i := range d.vertices {
+			d.vertices[i].q = quadric{}
+		}
+		for i := range d.triangles {
+			t := &d.triangles[i]
+			p0 := d.vertices[t.v[0]].p
+			n := d.vertices[t.v[1]].p.Sub(p0).Cross(d.vertices[t.v[2]].p.Sub(p0)).Normalize()
+			t.n = n
+			pq := planeQuadric(n.X, n.Y, n.Z, -n.Dot(p0))
+			for j := 0; j < 3; j++ {
+				d.vertices[t.v[j]].q = d.vertices[t.v[j]].q.add(pq)
+			}
+		}
+		for i := range d.triangles {
+			t := &d.triangles[i]
+			for j := 0; j < 3; j++ {
+				t.err[j], _ = d.edgeError(t.v[j], t.v[(j+1)%3])
+			}
+			t.err[3] = math.Min(t.err[0], math.Min(t.err[1], t.err[2]))
+		}
+	}
+
+	// 重建顶点到三角形的引用表
+	for i := range d.vertices {
+		d.vertices[i].tstart = 0
+		d.vertices[i].tcount = 0
+	}
+	for _, t := range d.triangles {
+		for j := 0; j < 3; j++ {
+			d.vertices[t.v[j]].tcount++
+		}
+	}
+	tstart := 0
+	for i := range d.vertices {
+		v := &d.vertices[i]
+		v.tstart = tstart
+		tstart += v.tcount
+		v.tcount = 0
+	}
+	d.refs = make([]dRef, len(d.triangles)*3, len(d.triangles)*4)
+	for i, t := range d.triangles {
+		for j := 0; j < 3; j++ {
+			v := &d.vertices[t.v[j]]
+			d.refs[v.tstart+v.tcount] = dRef{tid: i, tvertex: j}
+			v.tcount++
+		}
+	}
+
+	// 只被一个三角形使用的邻接顶点位于边界上，边界顶点不与内部顶点折叠
+	if iteration == 0 {
+		for i := range d.vertices {
+			d.vertices[i].border = false
+		}
+		for i := range d.vertices {
+			v := &d.vertices[i]
+			neighbours := make(map[int]int)
+			for k := 0; k < v.tcount; k++ {
+				t := d.triangles[d.refs[v.tstart+k].tid]
+				for j := 0; j < 3; j++ {
+					neighbours[t.v[j]]++
+				}
+			}
+			for id, n := range neighbours {
+				if n == 1 {
+					d.vertices[id].border = true
+				}
+			}
+		}
+	}
+}
+
+// compact 删除无用的三角形和顶点并重新编号
+func (d *decimator) compact() {
+	dst := 0
+	for i := range d.vertices {
+		d.vertices[i].tcount = 0
+	}
+	for _, t := range d.triangles {
+		if t.deleted {
+			continue
+		}
+		d.triangles[dst] = t
+		dst++
+		for j := 0; j < 3; j++ {
+			d.vertices[t.v[j]].tcount = 1
+		}
+	}
+	d.triangles = d.triangles[:dst]
+
+	dst = 0
+	for i := range d.vertices {
+		if d.vertices[i].tcount > 0 {
+			d.vertices[i].tstart = dst
+			d.vertices[dst].p = d.vertices[i].p
+			dst++
+		}
+	}
+	for i := range d.triangles {
+		for j := 0; j < 3; j++ {
+			d.triangles[i].v[j] = d.vertices[d.triangles[i].v[j]].tstart
+		}
+	}
+	d.vertices = d.vertices[:dst]
+}
+
+// edgeError 计算折叠边(i0,i1)的误差及最优顶点位置
+func (d *decimator) edgeError(i0, i1 int) (float64, Vec3) {
+	v0, v1 := &d.vertices[i0], &d.vertices[i1]
+	q := v0.q.add(v1.q)
+	border := v0.border && v1.border
+
+	det := q.det(0, 1, 2, 1, 4, 5, 2, 5, 7)
+	if det != 0 && !border {
+		p := Vec3{
+			X: -1 / det * q.det(1, 2, 3, 4, 5, 6, 5, 7, 8),
+			Y: 1 / det * q.det(0, 2, 3, 1, 5, 6, 2, 7, 8),
+			Z: -1 / det * q.det(0, 1, 3, 1, 4, 6, 2, 5, 8),
+		}
+		return q.vertexError(p), p
+	}
+
+	// 矩阵不可逆时在端点和中点中选择误差最小的位置
+	mid := v0.p.Add(v1.p).Scale(0.5)
+	best, bestErr := v0.p, q.vertexError(v0.p)
+	for _, p := range []Vec3{v1.p, mid} {
+		if e := q.vertexError(p); e < bestErr {
+			best, bestErr = p, e
+		}
+	}
+	return bestErr, best
+}
+
+func (d *decimator) result(src *Mesh) *Mesh {
+	out := &Mesh{
+		Vertices:  make([]Vec3, len(d.vertices)),
+		TexCoords: append([]Vec2(nil), src.TexCoords...),
+		Faces:     make([]Face, len(d.triangles)),
+		MtlLibs:   append([]string(nil), src.MtlLibs...),
+	}
+	for i, v := range d.vertices {
+		out.Vertices[i] = v.p
+	}
+	for i, t := range d.triangles {
+		out.Faces[i] = Face{V: t.v, VT: t.vt, VN: [3]int{-1, -1, -1}, Material: t.material}
+	}
+	return out
+}
+
+func resizeBools(b []bool, n int) []bool {
+	if cap(b) < n {
+		return make([]bool, n)
+	}
+	b = b[:n]
+	for i := range b {
+		b[i] = false
+	}
+	return b
+}
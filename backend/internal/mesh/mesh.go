@@ -0,0 +1,251 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Vec3 三维向量
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+func (a Vec3) Add(b Vec3) Vec3      { return Vec3{a.X + b.X, a.Y + b.Y, a.Z + b.Z} }
+func (a Vec3) Sub(b Vec3) Vec3      { return Vec3{a.X - b.X, a.Y - b.Y, a.Z - b.Z} }
+func (a Vec3) Scale(s float64) Vec3 { return Vec3{a.X * s, a.Y * s, a.Z * s} }
+func (a Vec3) Dot(b Vec3) float64   { return a.X*b.X + a.Y*b.Y + a.Z*b.Z }
+func (a Vec3) Length() float64      { return math.Sqrt(a.Dot(a)) }
+
+func (a Vec3) Cross(b Vec3) Vec3 {
+	return Vec3{a.Y*b.Z - a.Z*b.Y, a.Z*b.X - a.X*b.Z, a.X*b.Y - a.Y*b.X}
+}
+
+func (a Vec3) Normalize() Vec3 {
+	l := a.Length()
+	if l == 0 {
+		return a
+	}
+	return a.Scale(1 / l)
+}
+
+// Vec2 纹理坐标
+type Vec2 struct {
+	U, V float64
+}
+
+// Face 三角面，索引从0开始，-1表示缺失
+type Face struct {
+	V        [3]int
+	VT       [3]int
+	VN       [3]int
+	Material string
+}
+
+// Mesh 三角网格
+type Mesh struct {
+	Vertices  []Vec3
+	TexCoords []Vec2
+	Normals   []Vec3
+	Faces     []Face
+	MtlLibs   []string
+}
+
+// Clone 深拷贝网格
+func (m *Mesh) Clone() *Mesh {
+	return &Mesh{
+		Vertices:  append([]Vec3(nil), m.Vertices...),
+		TexCoords: append([]Vec2(nil), m.TexCoords...),
+		Normals:   append([]Vec3(nil), m.Normals...),
+		Faces:     append([]Face(nil), m.Faces...),
+		MtlLibs:   append([]string(nil), m.MtlLibs...),
+	}
+}
+
+// StripMaterials 移除材质库引用和面材质，输出只含几何信息的网格
+func (m *Mesh) StripMaterials() {
+	m.MtlLibs = nil
+	for i := range m.Faces {
+		m.Faces[i].Material = ""
+	}
+}
+
+// Bounds 计算包围盒
+func (m *Mesh) Bounds() (min, max Vec3) {
+	if len(m.Vertices) == 0 {
+		return Vec3{}, Vec3{}
+	}
+	min, max = m.Vertices[0], m.Vertices[0]
+	for _, v := range m.Vertices[1:] {
+		min = Vec3{math.Min(min.X, v.X), math.Min(min.Y, v.Y), math.Min(min.Z, v.Z)}
+		max = Vec3{math.Max(max.X, v.X), math.Max(max.Y, v.Y), math.Max(max.Z, v.Z)}
+	}
+	return min, max
+}
+
+// ParseOBJ 解析Wavefront OBJ，多边形按扇形三角化
+func ParseOBJ(r io.Reader) (*Mesh, error) {
+	m := &Mesh{}
+	material := ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		switch fields[0] {
+		case "v":
+			v, err := parseFloats(fields[1:], 3)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			m.Vertices = append(m.Vertices, Vec3{v[0], v[1], v[2]})
+		case "vt":
+			v, err := parseFloats(fields[1:], 2)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			m.TexCoords = append(m.TexCoords, Vec2{v[0], v[1]})
+		case "vn":
+			v, err := parseFloats(fields[1:], 3)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			m.Normals = append(m.Normals, Vec3{v[0], v[1], v[2]})
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: face needs at least 3 vertices", lineNo)
+			}
+			corners := make([][3]int, 0, len(fields)-1)
+			for _, f := range fields[1:] {
+				c, err := m.parseCorner(f)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				corners = append(corners, c)
+			}
+			for i := 1; i+1 < len(corners); i++ {
+				a, b, c := corners[0], corners[i], corners[i+1]
+				m.Faces = append(m.Faces, Face{
+					V:        [3]int{a[0], b[0], c[0]},
+					VT:       [3]int{a[1], b[1], c[1]},
+					VN:       [3]int{a[2], b[2], c[2]},
+					Material: material,
+				})
+			}
+		case "mtllib":
+			m.MtlLibs = append(m.MtlLibs, strings.Join(fields[1:], " "))
+		case "usemtl":
+			material = strings.Join(fields[1:], " ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(m.Faces) == 0 {
+		return nil, fmt.Errorf("mesh has no faces")
+	}
+	return m, nil
+}
+
+// parseCorner 解析 "v/vt/vn" 形式的面顶点，支持负索引
+func (m *Mesh) parseCorner(s string) ([3]int, error) {
+	c := [3]int{-1, -1, -1}
+	counts := [3]int{len(m.Vertices), len(m.TexCoords), len(m.Normals)}
+	for i, part := range strings.SplitN(s, "/", 3) {
+		if part == "" {
+			continue
+		}
+		idx, err := strconv.Atoi(part)
+		if err != nil {
+			return c, fmt.Errorf("invalid face index %q", s)
+		}
+		if idx < 0 {
+			idx = counts[i] + idx
+		} else {
+			idx--
+		}
+		if idx < 0 || idx >= counts[i] {
+			return c, fmt.Errorf("face index out of range %q", s)
+		}
+		c[i] = idx
+	}
+	if c[0] < 0 {
+		return c, fmt.Errorf("face corner without vertex %q", s)
+	}
+	return c, nil
+}
+
+// WriteOBJ 输出Wavefront OBJ
+func (m *Mesh) WriteOBJ(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, lib := range m.MtlLibs {
+		fmt.Fprintf(bw, "mtllib %s\n", lib)
+	}
+	for _, v := range m.Vertices {
+		fmt.Fprintf(bw, "v %s %s %s\n", formatFloat(v.X), formatFloat(v.Y), formatFloat(v.Z))
+	}
+	for _, vt := range m.TexCoords {
+		fmt.Fprintf(bw, "vt %s %s\n", formatFloat(vt.U), formatFloat(vt.V))
+	}
+	for _, vn := range m.Normals {
+		fmt.Fprintf(bw, "vn %s %s %s\n", formatFloat(vn.X), formatFloat(vn.Y), formatFloat(vn.Z))
+	}
+
+	material := ""
+	for _, f := range m.Faces {
+		if f.Material != material {
+			fmt.Fprintf(bw, "usemtl %s\n", f.Material)
+			material = f.Material
+		}
+		bw.WriteString("f")
+		for i := 0; i < 3; i++ {
+			bw.WriteString(" " + strconv.Itoa(f.V[i]+1))
+			switch {
+			case f.VT[i] >= 0 && f.VN[i] >= 0:
+				fmt.Fprintf(bw, "/%d/%d", f.VT[i]+1, f.VN[i]+1)
+			case f.VT[i] >= 0:
+				fmt.Fprintf(bw, "/%d", f.VT[i]+1)
+			case f.VN[i] >= 0:
+				fmt.Fprintf(bw, "//%d", f.VN[i]+1)
+			}
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+func parseFloats(fields []string, n int) ([]float64, error) {
+	if len(fields) < n {
+		return nil, fmt.Errorf("expected %d components, got %d", n, len(fields))
+	}
+	out := make([]float64, n)
+	for i := 0; i < n; i++ {
+		f, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", fields[i])
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+// formatFloat 保留6位小数并去掉末尾的0
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 6, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
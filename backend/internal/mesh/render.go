@@ -0,0 +1,202 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// RenderOptions 软件光栅化渲染参数
+type RenderOptions struct {
+	Width      int
+	Height     int
+	Yaw        float64 // 绕Y轴旋转角度（度）
+	Pitch      float64 // 绕X轴俯仰角度（度）
+	Background color.RGBA
+	Color      color.RGBA // 模型基础色
+}
+
+// DefaultRenderOptions 返回默认渲染参数
+func DefaultRenderOptions(size int) RenderOptions {
+	return RenderOptions{
+		Width:      size,
+		Height:     size,
+		Yaw:        30,
+		Pitch:      20,
+		Background: color.RGBA{0, 0, 0, 0},
+		Color:      color.RGBA{200, 200, 205, 255},
+	}
+}
+
+// supersample 抗锯齿的超采样倍数
+const supersample = 2
+
+// Render 使用正交投影和Z缓冲渲染网格，不依赖GPU
+func Render(m *Mesh, opts RenderOptions) *image.RGBA {
+	w, h := opts.Width*supersample, opts.Height*supersample
+	pixels := make([]color.RGBA, w*h)
+	depth := make([]float64, w*h)
+	for i := range depth {
+		depth[i] = math.Inf(-1)
+		pixels[i] = opts.Background
+	}
+
+	min, max := m.Bounds()
+	center := min.Add(max).Scale(0.5)
+	radius := 0.0
+	for _, v := range m.Vertices {
+		radius = math.Max(radius, v.Sub(center).Length())
+	}
+	if radius == 0 {
+		radius = 1
+	}
+	scale := 0.9 * float64(minInt(w, h)) / (2 * radius)
+
+	// 顶点变换到屏幕空间
+	yaw := opts.Yaw * math.Pi / 180
+	pitch := opts.Pitch * math.Pi / 180
+	screen := make([]Vec3, len(m.Vertices))
+	for i, v := range m.Vertices {
+		p := rotate(v.Sub(center), yaw, pitch)
+		screen[i] = Vec3{
+			X: float64(w)/2 + p.X*scale,
+			Y: float64(h)/2 - p.Y*scale,
+			Z: p.Z,
+		}
+	}
+
+	light := Vec3{0.4, 0.6, 1}.Normalize()
+	for _, f := range m.Faces {
+		a, b, c := m.Vertices[f.V[0]], m.Vertices[f.V[1]], m.Vertices[f.V[2]]
+		n := rotate(b.Sub(a).Cross(c.Sub(a)), yaw, pitch).Normalize()
+
+		// 双面光照，模型法线方向不一定一致
+		intensity := 0.3 + 0.7*math.Abs(n.Dot(light))
+		shade := shadeColor(opts.Color, intensity)
+
+		rasterize(screen[f.V[0]], screen[f.V[1]], screen[f.V[2]], w, h, func(idx int, z float64) {
+			if z > depth[idx] {
+				depth[idx] = z
+				pixels[idx] = shade
+			}
+		})
+	}
+
+	return downsample(pixels, w, opts.Width, opts.Height)
+}
+
+// RenderSpriteSheet 渲染360°转台精灵图，frames帧按columns列排列
+func RenderSpriteSheet(m *Mesh, opts RenderOptions, frames, columns int) *image.RGBA {
+	if frames <= 0 {
+		frames = 1
+	}
+	if columns <= 0 || columns > frames {
+		columns = frames
+	}
+	rows := (frames + columns - 1) / columns
+
+	sheet := image.NewRGBA(image.Rect(0, 0, opts.Width*columns, opts.Height*rows))
+	for i := 0; i < frames; i++ {
+		frameOpts := opts
+		frameOpts.Yaw = opts.Yaw + 360*float64(i)/float64(frames)
+		frame := Render(m, frameOpts)
+
+		ox, oy := (i%columns)*opts.Width, (i/columns)*opts.Height
+		for y := 0; y < opts.Height; y++ {
+			copy(sheet.Pix[(oy+y)*sheet.Stride+ox*4:], frame.Pix[y*frame.Stride:y*frame.Stride+opts.Width*4])
+		}
+	}
+	return sheet
+}
+
+func rotate(v Vec3, yaw, pitch float64) Vec3 {
+	cy, sy := math.Cos(yaw), math.Sin(yaw)
+	v = Vec3{v.X*cy + v.Z*sy, v.Y, -v.X*sy + v.Z*cy}
+	cp, sp := math.Cos(pitch), math.Sin(pitch)
+	return Vec3{v.X, v.Y*cp - v.Z*sp, v.Y*sp + v.Z*cp}
+}
+
+// rasterize 使用边函数光栅化三角形，按重心坐标插值深度
+func rasterize(a, b, c Vec3, w, h int, plot func(idx int, z float64)) {
+	area := edge(a, b, c.X, c.Y)
+	if area == 0 {
+		return
+	}
+
+	minX := maxInt(0, int(math.Floor(math.Min(a.X, math.Min(b.X, c.X)))))
+	maxX := minInt(w-1, int(math.Ceil(math.Max(a.X, math.Max(b.X, c.X)))))
+	minY := maxInt(0, int(math.Floor(math.Min(a.Y, math.Min(b.Y, c.Y)))))
+	maxY := minInt(h-1, int(math.Ceil(math.Max(a.Y, math.Max(b.Y, c.Y)))))
+
+	for y := minY; y <= maxY; y++ {
+		py := float64(y) + 0.5
+		for x := minX; x <= maxX; x++ {
+			px := float64(x) + 0.5
+			w0 := edge(b, c, px, py) / area
+			w1 := edge(c, a, px, py) / area
+			w2 := edge(a, b, px, py) / area
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			plot(y*w+x, w0*a.Z+w1*b.Z+w2*c.Z)
+		}
+	}
+}
+
+func edge(a, b Vec3, x, y float64) float64 {
+	return (b.X-a.X)*(y-a.Y) - (b.Y-a.Y)*(x-a.X)
+}
+
+func shadeColor(c color.RGBA, intensity float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(math.Min(255, float64(c.R)*intensity)),
+		G: uint8(math.Min(255, float64(c.G)*intensity)),
+		B: uint8(math.Min(255, float64(c.B)*intensity)),
+		A: c.A,
+	}
+}
+
+// downsample 对超采样缓冲做盒式滤波得到最终图像
+func downsample(buf []color.RGBA, w, outW, outH int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, outW, outH))
+	n := uint32(supersample * supersample)
+	for y := 0; y < outH; y++ {
+		for x := 0; x < outW; x++ {
+			var r, g, b, a uint32
+			for dy := 0; dy < supersample; dy++ {
+				for dx := 0; dx < supersample; dx++ {
+					p := buf[(y*supersample+dy)*w+x*supersample+dx]
+					// 按alpha加权，避免透明背景使边缘变暗
+					r += uint32(p.R) * uint32(p.A)
+					g += uint32(p.G) * uint32(p.A)
+					b += uint32(p.B) * uint32(p.A)
+					a += uint32(p.A)
+				}
+			}
+			var out color.RGBA
+			if a > 0 {
+				out = color.RGBA{uint8(r / a), uint8(g / a), uint8(b / a), uint8(a / n)}
+			}
+			// image.RGBA 使用预乘alpha
+			out.R = uint8(uint32(out.R) * uint32(out.A) / 255)
+			out.G = uint8(uint32(out.G) * uint32(out.A) / 255)
+			out.B = uint8(uint32(out.B) * uint32(out.A) / 255)
+			img.SetRGBA(x, y, out)
+		}
+	}
+	return img
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
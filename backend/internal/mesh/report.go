@@ -0,0 +1,71 @@
+package mesh
+
+// Report 网格统计报告
+type Report struct {
+	Vertices         int        `json:"vertices"`
+	Faces            int        `json:"faces"`
+	TexCoords        int        `json:"tex_coords"`
+	Normals          int        `json:"normals"`
+	Materials        []string   `json:"materials,omitempty"`
+	BoundsMin        [3]float64 `json:"bounds_min"`
+	BoundsMax        [3]float64 `json:"bounds_max"`
+	Dimensions       [3]float64 `json:"dimensions"`
+	SurfaceArea      float64    `json:"surface_area"`
+	DegenerateFaces  int        `json:"degenerate_faces"`
+	BoundaryEdges    int        `json:"boundary_edges"`
+	NonManifoldEdges int        `json:"non_manifold_edges"`
+	Watertight       bool       `json:"watertight"`
+}
+
+// Analyze 统计网格的基本信息和拓扑质量
+func Analyze(m *Mesh) *Report {
+	min, max := m.Bounds()
+	size := max.Sub(min)
+	r := &Report{
+		Vertices:   len(m.Vertices),
+		Faces:      len(m.Faces),
+		TexCoords:  len(m.TexCoords),
+		Normals:    len(m.Normals),
+		BoundsMin:  [3]float64{min.X, min.Y, min.Z},
+		BoundsMax:  [3]float64{max.X, max.Y, max.Z},
+		Dimensions: [3]float64{size.X, size.Y, size.Z},
+	}
+
+	type edgeKey struct{ a, b int }
+	edges := make(map[edgeKey]int, len(m.Faces)*3/2)
+	materials := make(map[string]bool)
+
+	for _, f := range m.Faces {
+		a, b, c := m.Vertices[f.V[0]], m.Vertices[f.V[1]], m.Vertices[f.V[2]]
+		area := b.Sub(a).Cross(c.Sub(a)).Length() / 2
+		if area == 0 {
+			r.DegenerateFaces++
+		}
+		r.SurfaceArea += area
+
+		if f.Material != "" && !materials[f.Material] {
+			materials[f.Material] = true
+			r.Materials = append(r.Materials, f.Material)
+		}
+
+		for i := 0; i < 3; i++ {
+			v0, v1 := f.V[i], f.V[(i+1)%3]
+			if v0 > v1 {
+				v0, v1 = v1, v0
+			}
+			edges[edgeKey{v0, v1}]++
+		}
+	}
+
+	for _, count := range edges {
+		switch {
+		case count == 1:
+			r.BoundaryEdges++
+		case count > 2:
+			r.NonManifoldEdges++
+		}
+	}
+	r.Watertight = r.BoundaryEdges == 0 && r.NonManifoldEdges == 0 && len(m.Faces) > 0
+
+	return r
+}
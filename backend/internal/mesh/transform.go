@@ -0,0 +1,128 @@
+package mesh
+
+import "fmt"
+
+// Axis 坐标轴
+type Axis int
+
+const (
+	AxisX Axis = iota
+	AxisY
+	AxisZ
+)
+
+// ParseAxis 解析 "x"/"y"/"z"
+func ParseAxis(s string) (Axis, error) {
+	switch s {
+	case "x", "X":
+		return AxisX, nil
+	case "y", "Y":
+		return AxisY, nil
+	case "z", "Z":
+		return AxisZ, nil
+	}
+	return 0, fmt.Errorf("invalid axis %q", s)
+}
+
+func (v Vec3) component(a Axis) float64 {
+	switch a {
+	case AxisX:
+		return v.X
+	case AxisY:
+		return v.Y
+	default:
+		return v.Z
+	}
+}
+
+// apply 对顶点和法线应用线性变换
+func (m *Mesh) apply(fv func(Vec3) Vec3, fn func(Vec3) Vec3) {
+	for i, v := range m.Vertices {
+		m.Vertices[i] = fv(v)
+	}
+	for i, n := range m.Normals {
+		m.Normals[i] = fn(n).Normalize()
+	}
+}
+
+// YUpToZUp 将Y轴朝上的模型转换为Z轴朝上（绕X轴旋转+90°）
+func (m *Mesh) YUpToZUp() {
+	rot := func(v Vec3) Vec3 { return Vec3{v.X, -v.Z, v.Y} }
+	m.apply(rot, rot)
+}
+
+// ZUpToYUp 将Z轴朝上的模型转换为Y轴朝上（绕X轴旋转-90°）
+func (m *Mesh) ZUpToYUp() {
+	rot := func(v Vec3) Vec3 { return Vec3{v.X, v.Z, -v.Y} }
+	m.apply(rot, rot)
+}
+
+// Mirror 沿指定轴镜像，同时翻转三角形绕序以保持法线朝外
+func (m *Mesh) Mirror(axis Axis) {
+	flip := func(v Vec3) Vec3 {
+		switch axis {
+		case AxisX:
+			v.X = -v.X
+		case AxisY:
+			v.Y = -v.Y
+		default:
+			v.Z = -v.Z
+		}
+		return v
+	}
+	m.apply(flip, flip)
+
+	for i := range m.Faces {
+		f := &m.Faces[i]
+		f.V[1], f.V[2] = f.V[2], f.V[1]
+		f.VT[1], f.VT[2] = f.VT[2], f.VT[1]
+		f.VN[1], f.VN[2] = f.VN[2], f.VN[1]
+	}
+}
+
+// Scale 等比缩放
+func (m *Mesh) Scale(s float64) {
+	m.apply(func(v Vec3) Vec3 { return v.Scale(s) }, func(n Vec3) Vec3 { return n })
+}
+
+// Translate 平移
+func (m *Mesh) Translate(d Vec3) {
+	m.apply(func(v Vec3) Vec3 { return v.Add(d) }, func(n Vec3) Vec3 { return n })
+}
+
+// Size 返回包围盒尺寸
+func (m *Mesh) Size() Vec3 {
+	min, max := m.Bounds()
+	return max.Sub(min)
+}
+
+// ScaleToHeight 等比缩放使模型沿up轴的高度等于height
+func (m *Mesh) ScaleToHeight(height float64, up Axis) error {
+	current := m.Size().component(up)
+	if current == 0 {
+		return fmt.Errorf("mesh has zero height along axis")
+	}
+	m.Scale(height / current)
+	return nil
+}
+
+// Center 将包围盒中心移动到原点
+func (m *Mesh) Center() {
+	min, max := m.Bounds()
+	m.Translate(min.Add(max).Scale(-0.5))
+}
+
+// DropToGround 平移模型使其最低点位于up轴的0平面上
+func (m *Mesh) DropToGround(up Axis) {
+	min, _ := m.Bounds()
+	var d Vec3
+	switch up {
+	case AxisX:
+		d.X = -min.X
+	case AxisY:
+		d.Y = -min.Y
+	default:
+		d.Z = -min.Z
+	}
+	m.Translate(d)
+}
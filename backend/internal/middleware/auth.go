@@ -1,68 +1,149 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
+	"3d-model-generator-backend/internal/models"
 	"3d-model-generator-backend/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware JWT认证中间件
-func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
+// AuthMiddleware 认证中间件，接受JWT访问令牌或API密钥
+// API密钥可以放在 Authorization: Bearer sk_... 或 X-API-Key 请求头中，
+// 使用API密钥的请求还需要通过路由组上的RequireScope检查
+func AuthMiddleware(authService *services.AuthService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 从请求头获取token
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "unauthorized",
-				"message": "缺少认证token",
-			})
-			c.Abort()
+		token, ok := credential(c)
+		if !ok {
 			return
 		}
 
-		// 检查Bearer前缀
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		if services.IsAPIKey(token) {
+			authenticateAPIKey(c, authService, apiKeyService, token)
+			return
+		}
+
+		// 验证token并获取用户信息
+		user, claims, err := authService.Authenticate(token)
+		if err != nil {
+			message := "无效的token"
+			switch {
+			case errors.Is(err, services.ErrUserNotFound):
+				message = "用户不存在或已被禁用"
+			case errors.Is(err, services.ErrTokenRevoked):
+				message = err.Error()
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error":   "unauthorized",
-				"message": "无效的认证格式",
+				"message": message,
 			})
 			c.Abort()
 			return
 		}
 
-		token := tokenParts[1]
+		// 将用户信息存储到上下文中
+		c.Set("user_id", user.ID)
+		c.Set("user_email", user.Email)
+		c.Set("user", user)
+		c.Set("session_id", claims.SessionID)
 
-		// 验证token
-		claims, err := authService.ValidateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "unauthorized",
-				"message": "无效的token",
+		c.Next()
+	}
+}
+
+// credential 从请求头获取访问令牌或API密钥，缺失或格式错误时直接返回401
+func credential(c *gin.Context) (string, bool) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key, true
+	}
+
+	// 从请求头获取token
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "缺少认证token",
+		})
+		c.Abort()
+		return "", false
+	}
+
+	// 检查Bearer前缀
+	tokenParts := strings.Split(authHeader, " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "无效的认证格式",
+		})
+		c.Abort()
+		return "", false
+	}
+
+	return tokenParts[1], true
+}
+
+func authenticateAPIKey(c *gin.Context, authService *services.AuthService, apiKeyService *services.APIKeyService, key string) {
+	apiKey, err := apiKeyService.Authenticate(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "无效的API密钥",
+		})
+		c.Abort()
+		return
+	}
+
+	user, err := authService.GetUserByID(apiKey.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "用户不存在或已被禁用",
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("user_email", user.Email)
+	c.Set("user", user)
+	c.Set("api_key_id", apiKey.ID)
+	c.Set("api_key_scopes", apiKey.Scopes)
+
+	c.Next()
+}
+
+// RequireScope 使用API密钥的请求必须具有指定的权限范围，JWT认证的请求不受限制
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("api_key_id") != "" && !slices.Contains(c.GetStringSlice("api_key_scopes"), scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "insufficient_scope",
+				"message": "API密钥缺少权限: " + scope,
 			})
 			c.Abort()
 			return
 		}
 
-		// 获取用户信息
-		user, err := authService.GetUserByID(claims.UserID)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "unauthorized",
-				"message": "用户不存在或已被禁用",
+		c.Next()
+	}
+}
+
+// DenyAPIKey 账号、密钥和管理相关的路由只允许登录后的JWT访问
+func DenyAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("api_key_id") != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "forbidden",
+				"message": "该接口不支持API密钥访问",
 			})
 			c.Abort()
 			return
 		}
 
-		// 将用户信息存储到上下文中
-		c.Set("user_id", user.ID)
-		c.Set("user_email", user.Email)
-		c.Set("user", user)
-
 		c.Next()
 	}
 }
@@ -86,15 +167,8 @@ func OptionalAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 
 		token := tokenParts[1]
 
-		// 验证token
-		claims, err := authService.ValidateToken(token)
-		if err != nil {
-			c.Next()
-			return
-		}
-
-		// 获取用户信息
-		user, err := authService.GetUserByID(claims.UserID)
+		// 验证token并获取用户信息
+		user, claims, err := authService.Authenticate(token)
 		if err != nil {
 			c.Next()
 			return
@@ -104,6 +178,41 @@ func OptionalAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 		c.Set("user_id", user.ID)
 		c.Set("user_email", user.Email)
 		c.Set("user", user)
+		c.Set("session_id", claims.SessionID)
+
+		c.Next()
+	}
+}
+
+// RequireRole 角色权限中间件，用户角色不低于role时放行，需在AuthMiddleware之后使用
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.MustGet("user").(*models.User)
+		if !ok || !user.HasRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "forbidden",
+				"message": "需要" + role + "权限",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail 要求用户已验证邮箱，需在AuthMiddleware之后使用
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.MustGet("user").(*models.User)
+		if !ok || !user.EmailVerified {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "email_not_verified",
+				"message": "请先验证邮箱",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
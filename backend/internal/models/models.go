@@ -1,6 +1,7 @@
 package models
 
 import (
+	"crypto/rand"
 	"fmt"
 	"time"
 
@@ -9,19 +10,24 @@ import (
 
 // GenerationJob 3D模型生成任务
 type GenerationJob struct {
-	ID           string     `json:"id" gorm:"primaryKey"`
-	UserID       string     `json:"user_id" gorm:"index"`
-	Prompt       string     `json:"prompt,omitempty"`
-	ImageURL     string     `json:"image_url,omitempty"`
-	ImageBase64  string     `json:"image_base64,omitempty"`
-	InputType    string     `json:"input_type"` // "text", "image", "multiview"
-	Status       string     `json:"status"`     // "pending", "processing", "completed", "failed"
-	TencentJobID string     `json:"tencent_job_id,omitempty"`
-	ResultFiles  []File3D   `json:"result_files,omitempty" gorm:"serializer:json"`
-	ErrorMsg     string     `json:"error_msg,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
-	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ID             string             `json:"id" gorm:"primaryKey"`
+	UserID         string             `json:"user_id" gorm:"index"`
+	Prompt         string             `json:"prompt,omitempty"`          // 提交给腾讯云的最终提示词
+	OriginalPrompt string             `json:"original_prompt,omitempty"` // 用户输入的提示词（模板展开后、翻译和扩展前）
+	ImageURL       string             `json:"image_url,omitempty"`
+	ImageBase64    string             `json:"image_base64,omitempty"`
+	InputType      string             `json:"input_type"`                                   // "text", "image", "multiview"
+	ViewImages     []ViewImage        `json:"view_images,omitempty" gorm:"serializer:json"` // 多视角生成时除正面图外的其他视角图片
+	Options        *GenerationOptions `json:"options,omitempty" gorm:"serializer:json"`
+	Preprocess     *ImagePreprocess   `json:"preprocess,omitempty" gorm:"serializer:json"`
+	Status         string             `json:"status"` // "pending", "processing", "completed", "failed", "cancelled"
+	TencentJobID   string             `json:"tencent_job_id,omitempty"`
+	ResultFiles    []File3D           `json:"result_files,omitempty" gorm:"serializer:json"`
+	Thumbnails     []Thumbnail        `json:"thumbnails,omitempty" gorm:"serializer:json"`
+	ErrorMsg       string             `json:"error_msg,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	CompletedAt    *time.Time         `json:"completed_at,omitempty"`
 }
 
 // File3D 3D文件信息
@@ -29,18 +35,277 @@ type File3D struct {
 	Type            string `json:"type"`
 	URL             string `json:"url"`
 	PreviewImageURL string `json:"preview_image_url"`
+	LOD             int    `json:"lod,omitempty"`     // LOD面数百分比，0表示原始模型
+	Key             string `json:"key,omitempty"`     // 本地存储的对象key
+	Size            int64  `json:"size,omitempty"`    // 本地存储的文件大小
+	Version         int    `json:"version,omitempty"` // 变换派生版本号，0表示原始模型
+
+	Transform *TransformRequest `json:"transform,omitempty"` // 生成该版本所用的变换参数
+}
+
+// Thumbnail 服务端渲染的缩略图
+type Thumbnail struct {
+	Name   string  `json:"name"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Yaw    float64 `json:"yaw"`
+	Pitch  float64 `json:"pitch"`
+	Frames int     `json:"frames,omitempty"` // 转台精灵图的帧数
+	URL    string  `json:"url"`
+	Key    string  `json:"key,omitempty"`
+	Size   int64   `json:"size,omitempty"`
+}
+
+// ImagePreprocess 提交前对输入图片的预处理记录
+type ImagePreprocess struct {
+	OriginalFormat string   `json:"original_format"`
+	OriginalWidth  int      `json:"original_width"`
+	OriginalHeight int      `json:"original_height"`
+	OriginalSize   int      `json:"original_size"`
+	Format         string   `json:"format"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	Size           int      `json:"size"`
+	Operations     []string `json:"operations"` // 例如 "orient:6"、"downscale:6000x4000->5000x3333"、"recompress:q75"
+	Delivery       string   `json:"delivery"`   // 提交方式：url（腾讯云直接下载原图）或 base64
 }
 
 // User 用户信息
 type User struct {
+	ID                  string     `json:"id" gorm:"primaryKey"`
+	Email               string     `json:"email" gorm:"uniqueIndex"`
+	Name                string     `json:"name"`
+	PasswordHash        string     `json:"-" gorm:"column:password_hash"` // 不返回给客户端
+	Role                string     `json:"role" gorm:"default:user;index"`
+	IsActive            bool       `json:"is_active" gorm:"default:true"`
+	EmailVerified       bool       `json:"email_verified" gorm:"default:false"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at,omitempty"`
+	TwoFactorEnabled    bool       `json:"two_factor_enabled" gorm:"default:false"`
+	TOTPSecret          string     `json:"-"`                  // 加密后的TOTP密钥，启用前保存待确认的密钥
+	TOTPLastCounter     int64      `json:"-" gorm:"default:0"` // 最近一次使用的时间步，防止验证码重放
+	TokenVersion        int        `json:"-" gorm:"default:0"` // 递增后已签发的访问令牌全部失效
+	LastLoginAt         *time.Time `json:"last_login_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" gorm:"index"` // 申请注销后删除账号和数据的时间，此前可以撤销
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Session 登录会话，每个设备一个，保存当前刷新令牌的哈希
+// 刷新令牌每次使用后轮换，旧令牌再次出现时整个会话被撤销
+type Session struct {
+	ID               string     `json:"id" gorm:"primaryKey"`
+	UserID           string     `json:"user_id" gorm:"index"`
+	RefreshTokenHash string     `json:"-"`
+	RotatedHashes    []string   `json:"-" gorm:"serializer:json"` // 最近轮换掉的刷新令牌哈希，用于识别重用
+	UserAgent        string     `json:"user_agent,omitempty"`
+	IP               string     `json:"ip,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason     string     `json:"revoke_reason,omitempty"` // logout、revoked、password_changed、password_reset、refresh_token_reuse等
+	Current          bool       `json:"current" gorm:"-"`        // 是否为发起请求的会话
+}
+
+// APIKey 个人API密钥，只保存哈希和用于识别的前缀
+type APIKey struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     string     `json:"user_id" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 例如 sk_Ab3dE9xQ，用于在列表中识别密钥
+	KeyHash    string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// 账号令牌的用途
+const (
+	TokenPurposeVerifyEmail    = "verify_email"
+	TokenPurposeResetPassword  = "reset_password"
+	TokenPurposeLoginChallenge = "login_challenge" // 密码验证通过、等待两步验证的登录
+	TokenPurposeSSOLogin       = "sso_login"       // 单点登录回调后交给前端换取令牌的一次性登录码
+	TokenPurposeDeleteAccount  = "delete_account"  // 未设置密码的账号确认注销
+)
+
+// UserIdentity 关联到用户的外部身份（OIDC提供方的subject）
+type UserIdentity struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	UserID      string     `json:"user_id" gorm:"index"`
+	Provider    string     `json:"provider" gorm:"uniqueIndex:idx_identity_subject"`
+	Subject     string     `json:"subject" gorm:"uniqueIndex:idx_identity_subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// SSOState 单点登录的授权请求状态，回调时校验并作废
+type SSOState struct {
 	ID           string     `json:"id" gorm:"primaryKey"`
-	Email        string     `json:"email" gorm:"uniqueIndex"`
-	Name         string     `json:"name"`
-	PasswordHash string     `json:"-" gorm:"column:password_hash"` // 不返回给客户端
-	IsActive     bool       `json:"is_active" gorm:"default:true"`
-	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	StateHash    string     `json:"-" gorm:"uniqueIndex"`
+	Provider     string     `json:"provider"`
+	Nonce        string     `json:"-"`
+	CodeVerifier string     `json:"-"` // PKCE
+	ExpiresAt    time.Time  `json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// SigningKey 签名访问令牌的密钥，ID即JWT头部的kid；私钥加密保存，公钥通过/.well-known/jwks.json公开
+// 新密钥在ActivatesAt之前已经公开，供其他服务提前缓存；被替换后在ExpiresAt之前仍用于验证
+type SigningKey struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Algorithm   string     `json:"algorithm"`
+	PrivateKey  string     `json:"-" gorm:"type:text"` // 加密的PKCS#8
+	PublicKey   string     `json:"-" gorm:"type:text"` // base64编码的PKIX
+	ActivatesAt time.Time  `json:"activates_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AccountToken 通过邮件发送的一次性令牌（邮箱验证、密码重置），只保存哈希
+type AccountToken struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	UserID    string     `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	Email     string     `json:"email"` // 发送时的邮箱，邮箱变更后令牌失效
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RecoveryCode 两步验证的恢复码，只保存哈希，每个只能使用一次
+type RecoveryCode struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	UserID    string     `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// 认证审计事件类型
+const (
+	AuthEventLoginSucceeded = "login_succeeded"
+	AuthEventLoginFailed    = "login_failed"
+	AuthEventLoginBlocked   = "login_blocked"  // 锁定期间的登录请求，不校验密码
+	AuthEventAccountLocked  = "account_locked" // 失败次数达到上限，开始锁定
+	AuthEventLoginUnlocked  = "login_unlocked" // 管理员解除锁定
+)
+
+// AuthEvent 认证审计事件，同时用于统计登录失败次数
+// Email为登录时提交的邮箱（小写），账号不存在时UserID为空
+type AuthEvent struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"index"`
+	UserID    string    `json:"user_id,omitempty" gorm:"index"`
+	Email     string    `json:"email" gorm:"index"`
+	IP        string    `json:"ip" gorm:"index"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// 用户角色，权限依次递增
+const (
+	RoleUser     = "user"
+	RoleOperator = "operator" // 运营：查看用户和任务、处理审核、维护全局模板
+	RoleAdmin    = "admin"    // 管理员：另外可以修改角色、停用账号和执行清理
+)
+
+var roleRanks = map[string]int{RoleUser: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// ValidRole 是否为已知角色
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// HasRole 用户角色是否不低于required，空角色视为普通用户
+func (u *User) HasRole(required string) bool {
+	role := u.Role
+	if role == "" {
+		role = RoleUser
+	}
+	return roleRanks[role] >= roleRanks[required]
+}
+
+// Upload 用户上传的图片，文件按内容哈希存储
+type Upload struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	UserID       string    `json:"user_id" gorm:"index"`
+	StorageKey   string    `json:"-" gorm:"index"`
+	URL          string    `json:"url"`
+	OriginalName string    `json:"original_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	SHA256       string    `json:"sha256" gorm:"column:sha256;index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UploadSession 断点续传会话
+type UploadSession struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"index"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`                                  // 文件总大小
+	Offset    int64     `json:"offset"`                                // 已接收的字节数
+	SHA256    string    `json:"sha256,omitempty" gorm:"column:sha256"` // 客户端声明的完整文件校验和，可选
+	SetID     string    `json:"set_id,omitempty" gorm:"index"`         // 所属的多视角图片组，可选
+	ViewType  string    `json:"view_type,omitempty"`                   // 在图片组中的视角
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UploadSetView 多视角图片组中的一个视角，同一组的每个视角只保留最后完成的一张图片
+type UploadSetView struct {
+	UserID    string    `json:"-" gorm:"primaryKey"`
+	SetID     string    `json:"set_id" gorm:"primaryKey"`
+	ViewType  string    `json:"view_type" gorm:"primaryKey"`
+	UploadID  string    `json:"upload_id" gorm:"index"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ModerationViolation 内容审核违规记录
+type ModerationViolation struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"index"`
+	Kind      string    `json:"kind"` // prompt 或 image
+	Category  string    `json:"category"`
+	Rule      string    `json:"rule"` // 命中的屏蔽词、正则或分类器名称
+	Score     float64   `json:"score,omitempty"`
+	Excerpt   string    `json:"excerpt"`  // 提示词片段或图片的SHA-256
+	Resolved  bool      `json:"resolved"` // 管理员恢复账号后不再计入自动封禁
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// PromptTemplate 提示词模板，UserID为空表示所有用户可用的全局模板
+type PromptTemplate struct {
+	ID          string            `json:"id" gorm:"primaryKey"`
+	UserID      string            `json:"user_id,omitempty" gorm:"index"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Template    string            `json:"template" gorm:"type:text"` // 例如 "{object}，{style}风格，低多边形游戏道具"
+	Variables   []string          `json:"variables" gorm:"serializer:json"`
+	Defaults    map[string]string `json:"defaults,omitempty" gorm:"serializer:json"` // 变量的默认值
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// StylePreset 风格预设，组合提示词后缀和生成选项，UserID为空表示全局预设
+type StylePreset struct {
+	ID           string             `json:"id" gorm:"primaryKey"`
+	UserID       string             `json:"user_id,omitempty" gorm:"index"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description,omitempty"`
+	PromptSuffix string             `json:"prompt_suffix,omitempty"`
+	Options      *GenerationOptions `json:"options,omitempty" gorm:"serializer:json"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
 }
 
 // Evaluation 评估记录
@@ -81,21 +346,39 @@ type APIUsage struct {
 
 // GenerationRequest 生成请求
 type GenerationRequest struct {
-	Prompt          string      `json:"prompt,omitempty"`
-	ImageURL        string      `json:"image_url,omitempty"`
-	ImageBase64     string      `json:"image_base64,omitempty"`
-	InputType       string      `json:"input_type"`
-	MultiViewImages []ViewImage `json:"multi_view_images,omitempty"`
-	ResultFormat    string      `json:"result_format,omitempty"`
-	EnablePBR       bool        `json:"enable_pbr,omitempty"`
-	FaceCount       int64       `json:"face_count,omitempty"`
-	GenerateType    string      `json:"generate_type,omitempty"`
+	Prompt          string            `json:"prompt,omitempty"`
+	ImageURL        string            `json:"image_url,omitempty"`
+	ImageBase64     string            `json:"image_base64,omitempty"`
+	UploadID        string            `json:"upload_id,omitempty"`   // 图片库中的上传ID，优先于image_url
+	SetID           string            `json:"set_id,omitempty"`      // 多视角图片组ID，正面图作为主图，其他视角作为multi_view_images提交，优先于upload_id
+	TemplateID      string            `json:"template_id,omitempty"` // 提示词模板ID，与prompt互斥
+	Variables       map[string]string `json:"variables,omitempty"`   // 模板变量
+	PresetID        string            `json:"preset_id,omitempty"`   // 风格预设ID
+	InputType       string            `json:"input_type"`
+	MultiViewImages []ViewImage       `json:"multi_view_images,omitempty"` // 由set_id解析得到，客户端提供的值会被忽略
+	ResultFormat    string            `json:"result_format,omitempty"`
+	EnablePBR       bool              `json:"enable_pbr,omitempty"`
+	FaceCount       int64             `json:"face_count,omitempty"`
+	GenerateType    string            `json:"generate_type,omitempty"`
+	Tier            string            `json:"tier,omitempty" binding:"omitempty,oneof=standard pro rapid"`
+	Enhance         *bool             `json:"enhance,omitempty"` // 是否翻译和扩展提示词，不指定时使用服务端默认值
+}
+
+// GenerationOptions 生成选项
+type GenerationOptions struct {
+	ResultFormat string `json:"result_format,omitempty"`
+	EnablePBR    bool   `json:"enable_pbr,omitempty"`
+	FaceCount    int64  `json:"face_count,omitempty"`
+	GenerateType string `json:"generate_type,omitempty"`
+	Tier         string `json:"tier,omitempty"`
+	Enhance      *bool  `json:"enhance,omitempty"`
 }
 
 // ViewImage 多视角图片
 type ViewImage struct {
 	ViewType     string `json:"view_type"`
 	ViewImageURL string `json:"view_image_url"`
+	UploadID     string `json:"upload_id,omitempty"` // 来自图片库时的上传ID
 }
 
 // GenerationResponse 生成响应
@@ -108,13 +391,94 @@ type GenerationResponse struct {
 
 // JobStatusResponse 任务状态响应
 type JobStatusResponse struct {
-	JobID       string    `json:"job_id"`
-	Status      string    `json:"status"`
-	Progress    int       `json:"progress"` // 0-100
-	ResultFiles []File3D  `json:"result_files,omitempty"`
-	ErrorMsg    string    `json:"error_msg,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	JobID       string           `json:"job_id"`
+	Status      string           `json:"status"`
+	Progress    int              `json:"progress"` // 0-100
+	ResultFiles []File3D         `json:"result_files,omitempty"`
+	Preprocess  *ImagePreprocess `json:"preprocess,omitempty"`
+	ErrorMsg    string           `json:"error_msg,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// CreateUploadSessionRequest 创建断点续传会话请求
+type CreateUploadSessionRequest struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size" binding:"required,min=1"`
+	SHA256   string `json:"sha256,omitempty"`
+	SetID    string `json:"set_id,omitempty" binding:"omitempty,max=64"` // 多视角图片组ID，由客户端指定，需与view_type同时提供
+	ViewType string `json:"view_type,omitempty" binding:"omitempty,oneof=front back left right"`
+}
+
+// UploadSessionResponse 断点续传会话状态
+type UploadSessionResponse struct {
+	UploadSession
+	ChunkSize int64 `json:"chunk_size"` // 建议的分片大小
+	MaxChunk  int64 `json:"max_chunk"`  // 单个分片的最大字节数
+}
+
+// PromptTemplateRequest 创建或更新提示词模板
+type PromptTemplateRequest struct {
+	Name        string            `json:"name" binding:"required,max=100"`
+	Description string            `json:"description,omitempty"`
+	Template    string            `json:"template" binding:"required"`
+	Defaults    map[string]string `json:"defaults,omitempty"`
+}
+
+// StylePresetRequest 创建或更新风格预设
+type StylePresetRequest struct {
+	Name         string             `json:"name" binding:"required,max=100"`
+	Description  string             `json:"description,omitempty"`
+	PromptSuffix string             `json:"prompt_suffix,omitempty"`
+	Options      *GenerationOptions `json:"options,omitempty"`
+}
+
+// RenderTemplateRequest 预览模板展开结果
+type RenderTemplateRequest struct {
+	Variables map[string]string `json:"variables,omitempty"`
+	PresetID  string            `json:"preset_id,omitempty"`
+}
+
+// RenderTemplateResponse 模板展开结果
+type RenderTemplateResponse struct {
+	Prompt  string             `json:"prompt"`
+	Options *GenerationOptions `json:"options,omitempty"`
+}
+
+// PromptStep 提示词处理步骤的结果
+type PromptStep struct {
+	Stage   string `json:"stage"`
+	Output  string `json:"output"`
+	Changed bool   `json:"changed"`
+}
+
+// PromptPreviewResponse 提示词处理预览
+type PromptPreviewResponse struct {
+	Original string       `json:"original"`
+	Prompt   string       `json:"prompt"`
+	Tier     string       `json:"tier,omitempty"`
+	Limit    int          `json:"limit"` // 档位对应的长度上限（字符）
+	Enhanced bool         `json:"enhanced"`
+	Steps    []PromptStep `json:"steps"`
+}
+
+// TransformRequest 模型变换请求
+type TransformRequest struct {
+	TargetHeightMM float64 `json:"target_height_mm,omitempty"` // 目标高度（毫米），设置后输出单位为毫米
+	Center         bool    `json:"center,omitempty"`           // 包围盒中心移到原点
+	DropToGround   bool    `json:"drop_to_ground,omitempty"`   // 最低点落到地面
+	SourceUpAxis   string  `json:"source_up_axis,omitempty"`   // 源模型朝上的轴："y"（默认）或"z"
+	UpAxis         string  `json:"up_axis,omitempty"`          // 输出模型朝上的轴："y"（默认）或"z"
+	Mirror         string  `json:"mirror,omitempty"`           // 镜像轴："x"、"y"或"z"
+	SourceLOD      int     `json:"source_lod,omitempty"`       // 以指定LOD为源模型，0表示原始模型
+}
+
+// TransformResponse 模型变换响应
+type TransformResponse struct {
+	JobID      string     `json:"job_id"`
+	File       File3D     `json:"file"`
+	Dimensions [3]float64 `json:"dimensions"` // 变换后包围盒尺寸（x, y, z）
+	Message    string     `json:"message,omitempty"`
 }
 
 // EvaluationRequest 评估请求
@@ -144,6 +508,44 @@ type StatisticsResponse struct {
 	AverageTime   int     `json:"average_time"` // 平均生成时间(秒)
 }
 
+// RetentionItem 清理候选文件
+type RetentionItem struct {
+	Key          string    `json:"key"`
+	ArtifactType string    `json:"artifact_type"` // "uploads", "results", "derivatives", "thumbnails"
+	Reason       string    `json:"reason"`        // "expired", "orphaned"
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mod_time"`
+	JobID        string    `json:"job_id,omitempty"`
+	UserID       string    `json:"user_id,omitempty"`
+}
+
+// RetentionSummary 按类型汇总的清理统计
+type RetentionSummary struct {
+	Scanned      int   `json:"scanned"`
+	ScannedBytes int64 `json:"scanned_bytes"`
+	Deleted      int   `json:"deleted"`
+	DeletedBytes int64 `json:"deleted_bytes"`
+}
+
+// RetentionReport 存储清理报告
+type RetentionReport struct {
+	DryRun     bool                         `json:"dry_run"`
+	StartedAt  time.Time                    `json:"started_at"`
+	FinishedAt time.Time                    `json:"finished_at"`
+	Items      []RetentionItem              `json:"items"`
+	ByType     map[string]*RetentionSummary `json:"by_type"`
+	FreedBytes int64                        `json:"freed_bytes"`
+	Errors     []string                     `json:"errors,omitempty"`
+}
+
+// StorageUsage 用户存储用量
+type StorageUsage struct {
+	UserID string           `json:"user_id"`
+	Files  int              `json:"files"`
+	Bytes  int64            `json:"bytes"`
+	ByType map[string]int64 `json:"by_type"`
+}
+
 // AuthRequest 认证请求
 type AuthRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -153,10 +555,188 @@ type AuthRequest struct {
 
 // AuthResponse 认证响应
 type AuthResponse struct {
-	Token     string    `json:"token"`
-	User      User      `json:"user"`
-	ExpiresAt time.Time `json:"expires_at"`
-	Message   string    `json:"message,omitempty"`
+	Token            string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	User             User      `json:"user"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Message          string    `json:"message,omitempty"`
+}
+
+// VerifyEmailRequest 邮箱验证请求，token来自验证邮件中的链接
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 找回密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求，token来自重置邮件中的链接
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// TwoFactorChallenge 开启两步验证的用户密码验证通过后的响应，使用challenge_token和验证码完成登录
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	Message           string    `json:"message,omitempty"`
+}
+
+// TwoFactorLoginRequest 两步验证登录请求，code为验证器应用中的6位验证码或恢复码
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest 需要验证码确认的两步验证操作
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorDisableRequest 关闭两步验证，需要密码和验证码（或恢复码）
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// DeleteAccountRequest 注销账号请求，开启两步验证时需要验证码或恢复码
+// 设置了密码的账号提供password；只通过单点登录使用的账号先不带参数请求，再提供邮件中的确认令牌token
+type DeleteAccountRequest struct {
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// AccountDeletionResponse 注销申请结果，DeletionScheduledAt为空表示已立即删除
+// ConfirmationSent为true表示账号没有密码，确认链接已发送到邮箱，尚未申请注销
+type AccountDeletionResponse struct {
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	ConfirmationSent    bool       `json:"confirmation_sent,omitempty"`
+	Message             string     `json:"message"`
+}
+
+// TwoFactorSetupResponse 两步验证注册信息，在验证器应用中扫描二维码或手动输入密钥
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"` // data:image/png;base64,...
+}
+
+// TwoFactorStatusResponse 两步验证状态
+type TwoFactorStatusResponse struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// RecoveryCodesResponse 新生成的恢复码，只在生成时返回一次
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Message       string   `json:"message,omitempty"`
+}
+
+// SSOProvider 可用的单点登录提供方
+type SSOProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// SSOProviderListResponse 单点登录提供方列表
+type SSOProviderListResponse struct {
+	Providers []SSOProvider `json:"providers"`
+}
+
+// SSOLoginURLResponse 单点登录授权地址
+type SSOLoginURLResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// SSOExchangeRequest 使用回调重定向中的一次性登录码换取令牌
+type SSOExchangeRequest struct {
+	LoginCode string `json:"login_code" binding:"required"`
+}
+
+// IdentityListResponse 用户关联的外部身份
+type IdentityListResponse struct {
+	Identities []UserIdentity `json:"identities"`
+}
+
+// JSONWebKey 公开的验证密钥（RFC 7517）
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+}
+
+// JSONWebKeySet 验证访问令牌的公钥集合
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// RefreshRequest 刷新令牌请求，登出时可选
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// APIKeyRequest 创建API密钥
+type APIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // 为空表示永不过期
+}
+
+// APIKeyCreateResponse 创建API密钥的响应，完整密钥只在此时返回一次
+type APIKeyCreateResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyListResponse API密钥列表
+type APIKeyListResponse struct {
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+// UserListResponse 用户列表（管理员）
+type UserListResponse struct {
+	Users  []User `json:"users"`
+	Total  int64  `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// RoleRequest 修改用户角色
+type RoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user operator admin"`
+}
+
+// AuthEventListResponse 认证审计事件列表（管理员）
+type AuthEventListResponse struct {
+	Events []AuthEvent `json:"events"`
+	Total  int64       `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// JobListResponse 任务列表（管理员）
+type JobListResponse struct {
+	Jobs   []GenerationJob `json:"jobs"`
+	Total  int64           `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+// SessionListResponse 登录会话列表
+type SessionListResponse struct {
+	Sessions []Session `json:"sessions"`
 }
 
 // ErrorResponse 错误响应
@@ -166,6 +746,23 @@ type ErrorResponse struct {
 	Code    int    `json:"code,omitempty"`
 }
 
+// ModerationRejectionResponse 内容审核未通过的响应
+type ModerationRejectionResponse struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Kind      string `json:"kind"`     // prompt 或 image
+	Category  string `json:"category"` // 命中的类别
+	Suspended bool   `json:"suspended,omitempty"`
+}
+
+// ModerationViolationListResponse 违规记录列表
+type ModerationViolationListResponse struct {
+	Violations []ModerationViolation `json:"violations"`
+	Total      int64                 `json:"total"`
+	Limit      int                   `json:"limit"`
+	Offset     int                   `json:"offset"`
+}
+
 // BeforeCreate GORM钩子
 func (j *GenerationJob) BeforeCreate(tx *gorm.DB) error {
 	if j.ID == "" {
@@ -181,6 +778,97 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+func (u *Upload) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == "" {
+		u.ID = generateID()
+	}
+	return nil
+}
+
+func (u *UploadSession) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == "" {
+		u.ID = generateID()
+	}
+	return nil
+}
+
+func (v *ModerationViolation) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = generateID()
+	}
+	return nil
+}
+
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = generateID()
+	}
+	return nil
+}
+
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = generateID()
+	}
+	return nil
+}
+
+func (k *SigningKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = generateID()
+	}
+	return nil
+}
+
+func (s *SSOState) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = generateID()
+	}
+	return nil
+}
+
+func (c *RecoveryCode) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = generateID()
+	}
+	return nil
+}
+
+func (e *AuthEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = generateID()
+	}
+	return nil
+}
+
+func (t *AccountToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = generateID()
+	}
+	return nil
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = generateID()
+	}
+	return nil
+}
+
+func (t *PromptTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = generateID()
+	}
+	return nil
+}
+
+func (p *StylePreset) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = generateID()
+	}
+	return nil
+}
+
 func (e *Evaluation) BeforeCreate(tx *gorm.DB) error {
 	if e.ID == "" {
 		e.ID = generateID()
@@ -198,15 +886,25 @@ func (a *APIUsage) BeforeCreate(tx *gorm.DB) error {
 }
 
 func generateID() string {
-	// 使用纳秒时间戳 + 随机字符串确保唯一性
-	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), randomString(12))
+	// 纳秒时间戳保证按创建时间排序，随机部分使ID无法推测（任务、上传等ID出现在地址中）
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), randomString(16))
 }
 
+// randomString 使用crypto/rand生成，拒绝采样避免取模偏差
 func randomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+	const limit = 256 - 256%len(charset)
+	b := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(b) < length {
+		if _, err := rand.Read(buf); err != nil {
+			panic(fmt.Sprintf("failed to read random bytes: %v", err))
+		}
+		for _, v := range buf {
+			if int(v) < limit && len(b) < length {
+				b = append(b, charset[int(v)%len(charset)])
+			}
+		}
 	}
 	return string(b)
 }
@@ -0,0 +1,86 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Label 分类器给出的风险类别和置信度（0-1）
+type Label struct {
+	Category string  `json:"category"`
+	Score    float64 `json:"score"`
+}
+
+// ImageClassifier 图片审核分类器，可以接入自建模型或第三方审核服务
+type ImageClassifier interface {
+	Name() string
+	Classify(ctx context.Context, data []byte, contentType string) ([]Label, error)
+}
+
+// SafeCategories 表示图片正常的类别，不会导致拒绝
+var SafeCategories = map[string]bool{
+	"normal":  true,
+	"neutral": true,
+	"safe":    true,
+}
+
+// HTTPClassifier 通过HTTP调用外部审核服务：
+// 以图片原始字节作为请求体POST到endpoint，响应为 {"labels": [{"category": "porn", "score": 0.97}]}
+type HTTPClassifier struct {
+	name     string
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+// NewHTTPClassifier endpoint由运维配置，token不为空时作为Bearer令牌发送
+func NewHTTPClassifier(name, endpoint, token string, timeout time.Duration) *HTTPClassifier {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &HTTPClassifier{
+		name:     name,
+		endpoint: endpoint,
+		token:    token,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *HTTPClassifier) Name() string {
+	return c.name
+}
+
+func (c *HTTPClassifier) Classify(ctx context.Context, data []byte, contentType string) ([]Label, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("classifier returned status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var result struct {
+		Labels []Label `json:"labels"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid classifier response: %w", err)
+	}
+	return result.Labels, nil
+}
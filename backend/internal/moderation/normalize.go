@@ -0,0 +1,65 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Text 同一段文本的几种归一化形式
+type Text struct {
+	Raw     string
+	Compact string   // 去掉空白、标点、符号和零宽字符后的连续文本，用于匹配中文屏蔽词
+	Words   []string // 按非字母数字切分的小写单词，用于整词匹配英文屏蔽词
+}
+
+// Normalize 归一化文本以对抗常见的绕过手段：
+// NFKC将全角字母数字、圈码等兼容字符转换为标准形式，再统一小写，
+// 并去掉插在字之间的空格、标点、表情和零宽字符（如"炸 弹"、"炸*弹"、"炸​弹"）
+func Normalize(s string) Text {
+	folded := strings.ToLower(norm.NFKC.String(s))
+
+	var compact strings.Builder
+	compact.Grow(len(folded))
+	for _, r := range folded {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			compact.WriteRune(r)
+		}
+	}
+
+	return Text{Raw: s, Compact: compact.String(), Words: asciiWords(folded)}
+}
+
+// asciiWords 提取连续的ASCII字母数字作为单词，中英文混写时（如"炸弹bomb"）英文部分也能整词匹配
+func asciiWords(s string) []string {
+	var words []string
+	start := -1
+	for i, r := range s {
+		isWord := r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsNumber(r))
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			words = append(words, s[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// isASCIIWord 只由ASCII字母数字组成的词按整词匹配，避免"class"命中"ass"之类的误判
+func isASCIIWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsNumber(r)) {
+			return false
+		}
+	}
+	return true
+}
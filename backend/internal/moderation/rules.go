@@ -0,0 +1,161 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultCategory 规则未指定分类时使用
+const DefaultCategory = "blocklist"
+
+// Match 文本命中的规则
+type Match struct {
+	Category string
+	Rule     string // 命中的屏蔽词或正则表达式
+}
+
+type term struct {
+	category string
+	text     string // 归一化后的屏蔽词
+	word     bool   // 纯ASCII词，按整词匹配
+	rule     string // 原始规则
+}
+
+type pattern struct {
+	category string
+	re       *regexp.Regexp
+	rule     string
+}
+
+// RuleSet 屏蔽词和正则规则
+type RuleSet struct {
+	terms    []term
+	patterns []pattern
+}
+
+// LoadRules 从规则文件加载，文件格式见ParseRules
+func LoadRules(path string) (*RuleSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rules file: %w", err)
+	}
+	defer f.Close()
+	return ParseRules(f)
+}
+
+// ParseRules 解析规则，每行一条，空行和以"#"开头的行被忽略：
+//
+//	炸弹                 屏蔽词，分类为blocklist
+//	violence:炸弹        指定分类的屏蔽词
+//	re:代[开办].{0,4}发票  正则表达式
+//	fraud:re:刷单|套现    指定分类的正则表达式
+//
+// 屏蔽词与输入文本经过同样的归一化后比较；正则同时匹配原文和归一化后的连续文本
+func ParseRules(r io.Reader) (*RuleSet, error) {
+	rules := &RuleSet{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		category := DefaultCategory
+		if prefix, rest, ok := strings.Cut(line, ":"); ok && prefix != "re" && isCategory(prefix) {
+			category, line = prefix, rest
+		}
+
+		if expr, ok := strings.CutPrefix(line, "re:"); ok {
+			if err := rules.AddPattern(category, expr); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			continue
+		}
+		rules.AddTerms(category, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rules: %w", err)
+	}
+	return rules, nil
+}
+
+// AddTerms 添加屏蔽词
+func (rs *RuleSet) AddTerms(category string, words ...string) {
+	for _, w := range words {
+		normalized := Normalize(w)
+		if normalized.Compact == "" {
+			continue
+		}
+		rs.terms = append(rs.terms, term{
+			category: category,
+			text:     normalized.Compact,
+			word:     isASCIIWord(normalized.Compact),
+			rule:     strings.TrimSpace(w),
+		})
+	}
+}
+
+// AddPattern 添加正则规则，默认不区分大小写
+func (rs *RuleSet) AddPattern(category, expr string) error {
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", expr, err)
+	}
+	rs.patterns = append(rs.patterns, pattern{category: category, re: re, rule: expr})
+	return nil
+}
+
+// Len 规则数量
+func (rs *RuleSet) Len() int {
+	if rs == nil {
+		return 0
+	}
+	return len(rs.terms) + len(rs.patterns)
+}
+
+// Check 返回第一条命中的规则，没有命中时返回nil
+func (rs *RuleSet) Check(s string) *Match {
+	if rs.Len() == 0 {
+		return nil
+	}
+	text := Normalize(s)
+
+	for _, t := range rs.terms {
+		var hit bool
+		if t.word {
+			hit = slices.Contains(text.Words, t.text)
+		} else {
+			hit = strings.Contains(text.Compact, t.text)
+		}
+		if hit {
+			return &Match{Category: t.category, Rule: t.rule}
+		}
+	}
+
+	for _, p := range rs.patterns {
+		if p.re.MatchString(text.Raw) || p.re.MatchString(text.Compact) {
+			return &Match{Category: p.category, Rule: p.rule}
+		}
+	}
+	return nil
+}
+
+// isCategory 分类名只允许字母、数字、下划线和连字符
+func isCategory(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultExpansionKey 扩展规则文件中表示默认描述词的键
+const DefaultExpansionKey = "*"
+
+// Expander 关键词扩展：提示词包含关键词时追加对应的描述词，提示词过短时追加默认描述词
+type Expander struct {
+	keywords  []string
+	rules     map[string][]string
+	defaults  []string
+	minLength int
+}
+
+// LoadExpander 从扩展规则文件加载，文件格式见ParseExpander
+func LoadExpander(path string, minLength int) (*Expander, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open expansion rules: %w", err)
+	}
+	defer f.Close()
+	return ParseExpander(f, minLength)
+}
+
+// ParseExpander 解析扩展规则，每行一条，空行和以"#"开头的行被忽略：
+//
+//	剑 = 金属质感, 精细的护手
+//	* = 高质量, 细节丰富
+//
+// 多个描述词用逗号分隔，"*"为提示词短于minLength个字符时追加的默认描述词
+func ParseExpander(r io.Reader, minLength int) (*Expander, error) {
+	e := NewExpander(minLength)
+	err := parsePairs(r, func(key, value string) error {
+		if key == DefaultExpansionKey {
+			e.defaults = append(e.defaults, splitPhrases(value)...)
+			return nil
+		}
+		e.Add(key, splitPhrases(value)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func NewExpander(minLength int) *Expander {
+	return &Expander{rules: make(map[string][]string), minLength: minLength}
+}
+
+// Add 添加关键词对应的描述词
+func (e *Expander) Add(keyword string, phrases ...string) {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" || len(phrases) == 0 {
+		return
+	}
+	if _, ok := e.rules[keyword]; !ok {
+		e.keywords = append(e.keywords, keyword)
+	}
+	e.rules[keyword] = append(e.rules[keyword], phrases...)
+}
+
+func (e *Expander) Name() string { return "expand" }
+
+// Apply 按规则文件中的顺序追加描述词，已经出现在提示词中的描述词不会重复追加
+func (e *Expander) Apply(ctx context.Context, text, tier string) (string, error) {
+	lower := strings.ToLower(text)
+	var additions []string
+	seen := make(map[string]bool)
+	add := func(phrases []string) {
+		for _, phrase := range phrases {
+			key := strings.ToLower(phrase)
+			if !seen[key] && !strings.Contains(lower, key) {
+				seen[key] = true
+				additions = append(additions, phrase)
+			}
+		}
+	}
+
+	for _, keyword := range e.keywords {
+		if strings.Contains(lower, keyword) {
+			add(e.rules[keyword])
+		}
+	}
+	if utf8.RuneCountInString(text) < e.minLength {
+		add(e.defaults)
+	}
+
+	return AppendSuffix(text, strings.Join(additions, ", ")), nil
+}
+
+func splitPhrases(value string) []string {
+	var phrases []string
+	for _, phrase := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '，' }) {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			phrases = append(phrases, phrase)
+		}
+	}
+	return phrases
+}
@@ -0,0 +1,42 @@
+package prompt
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxRapidPromptLength 极速版接口的提示词上限（字符）
+const MaxRapidPromptLength = 200
+
+// Limit 返回档位对应的提示词长度上限
+func Limit(tier string) int {
+	if tier == "rapid" {
+		return MaxRapidPromptLength
+	}
+	return MaxPromptLength
+}
+
+// LengthStage 将提示词截断到档位的长度上限内，优先在逗号等分隔符处截断
+type LengthStage struct{}
+
+func (LengthStage) Name() string { return "length" }
+
+func (LengthStage) Apply(ctx context.Context, text, tier string) (string, error) {
+	return Truncate(text, Limit(tier)), nil
+}
+
+// Truncate 将提示词截断到limit个字符以内
+// 截断点优先选在最后一个完整的分句之后，找不到分隔符时按字符截断
+func Truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	runes := []rune(text)[:limit]
+	cut := string(runes)
+	if i := strings.LastIndexAny(cut, ",，、;；。.\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \t\n,，、;；。.")
+}
@@ -0,0 +1,47 @@
+package prompt
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{"within limit", "a red chair", 20, "a red chair"},
+		{"exactly at limit", "a red chair", 11, "a red chair"},
+		{"cut at last comma", "a red chair, wooden legs", 15, "a red chair"},
+		{"cut at last of several separators", "chair, red; wooden legs", 15, "chair, red"},
+		{"no separator cuts by character", "abcdefghij", 4, "abcd"},
+		{"trailing space trimmed", "one two three four", 8, "one two"},
+		{"counts characters not bytes", "红色椅子，木质，高细节", 6, "红色椅子"},
+		{"chinese without separator", "红色木质椅子高细节", 4, "红色木质"},
+		{"separator just inside limit", "alpha, beta", 7, "alpha"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.text, tt.limit)
+			if got != tt.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); n > tt.limit {
+				t.Errorf("Truncate(%q, %d) has %d characters", tt.text, tt.limit, n)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	if got := Limit("rapid"); got != MaxRapidPromptLength {
+		t.Errorf("Limit(rapid) = %d, want %d", got, MaxRapidPromptLength)
+	}
+	for _, tier := range []string{"", "standard", "pro"} {
+		if got := Limit(tier); got != MaxPromptLength {
+			t.Errorf("Limit(%q) = %d, want %d", tier, got, MaxPromptLength)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+)
+
+// Stage 提交前的提示词处理步骤
+type Stage interface {
+	Name() string
+	// Apply 返回处理后的提示词，tier用于确定长度限制等与档位相关的行为
+	Apply(ctx context.Context, text, tier string) (string, error)
+}
+
+// Step 单个步骤的处理结果
+type Step struct {
+	Stage   string
+	Output  string
+	Changed bool
+}
+
+// Result 流水线处理结果
+type Result struct {
+	Original string
+	Prompt   string
+	Limit    int
+	Steps    []Step
+}
+
+// Pipeline 按顺序执行各个步骤
+type Pipeline struct {
+	stages []Stage
+}
+
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Stages 返回步骤名称
+func (p *Pipeline) Stages() []string {
+	names := make([]string, len(p.stages))
+	for i, stage := range p.stages {
+		names[i] = stage.Name()
+	}
+	return names
+}
+
+// Run 依次执行所有步骤，任一步骤失败即返回错误
+func (p *Pipeline) Run(ctx context.Context, text, tier string) (*Result, error) {
+	result := &Result{Original: text, Prompt: text, Limit: Limit(tier)}
+	for _, stage := range p.stages {
+		output, err := stage.Apply(ctx, result.Prompt, tier)
+		if err != nil {
+			return nil, fmt.Errorf("prompt stage %s: %w", stage.Name(), err)
+		}
+		result.Steps = append(result.Steps, Step{
+			Stage:   stage.Name(),
+			Output:  output,
+			Changed: output != result.Prompt,
+		})
+		result.Prompt = output
+	}
+	return result, nil
+}
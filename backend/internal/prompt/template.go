@@ -0,0 +1,150 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPromptLength 混元生3D接口的提示词上限（字符）
+const MaxPromptLength = 1024
+
+var (
+	ErrInvalidTemplate = errors.New("invalid template")
+	ErrMissingVariable = errors.New("missing template variable")
+	ErrPromptTooLong   = errors.New("prompt too long")
+)
+
+// Placeholders 解析模板中的占位符，按首次出现的顺序返回去重后的变量名
+// 占位符形如 {object}，变量名由字母、数字和下划线组成；"{{"和"}}"表示字面的花括号
+func Placeholders(tmpl string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	err := parse(tmpl, func(literal, name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	})
+	return names, err
+}
+
+// Render 用vars替换占位符，vars中没有的变量使用defaults，仍然缺失时返回ErrMissingVariable
+func Render(tmpl string, vars, defaults map[string]string) (string, error) {
+	var b strings.Builder
+	var missing []string
+	err := parse(tmpl, func(literal, name string) {
+		if name == "" {
+			b.WriteString(literal)
+			return
+		}
+		value, ok := vars[name]
+		if !ok || strings.TrimSpace(value) == "" {
+			value, ok = defaults[name]
+		}
+		if !ok {
+			missing = append(missing, name)
+			return
+		}
+		b.WriteString(strings.TrimSpace(value))
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(dedupe(missing), ", "))
+	}
+	return b.String(), nil
+}
+
+// AppendSuffix 在提示词后追加风格后缀，处理两者之间多余的空白和逗号
+func AppendSuffix(prompt, suffix string) string {
+	const separators = " \t\n,，、"
+	prompt = strings.TrimRight(prompt, separators)
+	suffix = strings.TrimLeft(suffix, separators)
+	switch {
+	case suffix == "":
+		return prompt
+	case prompt == "":
+		return suffix
+	default:
+		return prompt + ", " + suffix
+	}
+}
+
+// CheckLength 检查提示词是否超出接口限制
+func CheckLength(prompt string) error {
+	return CheckTierLength(prompt, "")
+}
+
+// CheckTierLength 检查提示词是否超出档位的长度限制
+func CheckTierLength(prompt, tier string) error {
+	limit := Limit(tier)
+	if n := utf8.RuneCountInString(prompt); n > limit {
+		return fmt.Errorf("%w: %d characters, at most %d", ErrPromptTooLong, n, limit)
+	}
+	return nil
+}
+
+// parse 依次回调字面文本（name为空）和占位符
+func parse(tmpl string, emit func(literal, name string)) error {
+	for len(tmpl) > 0 {
+		i := strings.IndexAny(tmpl, "{}")
+		if i < 0 {
+			emit(tmpl, "")
+			return nil
+		}
+		if i > 0 {
+			emit(tmpl[:i], "")
+		}
+		tmpl = tmpl[i:]
+
+		// 转义的花括号
+		if strings.HasPrefix(tmpl, "{{") || strings.HasPrefix(tmpl, "}}") {
+			emit(tmpl[:1], "")
+			tmpl = tmpl[2:]
+			continue
+		}
+		if tmpl[0] == '}' {
+			return fmt.Errorf("%w: unmatched '}'", ErrInvalidTemplate)
+		}
+
+		end := strings.IndexByte(tmpl, '}')
+		if end < 0 {
+			return fmt.Errorf("%w: unclosed '{'", ErrInvalidTemplate)
+		}
+		name := strings.TrimSpace(tmpl[1:end])
+		if !validName(name) {
+			return fmt.Errorf("%w: invalid placeholder %q", ErrInvalidTemplate, tmpl[:end+1])
+		}
+		emit("", name)
+		tmpl = tmpl[end+1:]
+	}
+	return nil
+}
+
+func validName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func dedupe(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
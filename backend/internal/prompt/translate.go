@@ -0,0 +1,222 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Translator 将提示词翻译为中文
+type Translator interface {
+	Translate(ctx context.Context, text string) (string, error)
+}
+
+// TranslateStage 对以拉丁字母为主的提示词调用Translator，中文提示词保持不变
+type TranslateStage struct {
+	Translator Translator
+}
+
+func (TranslateStage) Name() string { return "translate" }
+
+func (s TranslateStage) Apply(ctx context.Context, text, tier string) (string, error) {
+	if !NeedsTranslation(text) {
+		return text, nil
+	}
+	return s.Translator.Translate(ctx, text)
+}
+
+// NeedsTranslation 拉丁字母多于汉字时认为提示词需要翻译
+func NeedsTranslation(text string) bool {
+	latin, han := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	return latin > han
+}
+
+// Dictionary 基于词典的离线翻译，按最长匹配替换英文单词和短语，词典中没有的词保持原样
+type Dictionary struct {
+	entries  map[string]string
+	maxWords int
+}
+
+// LoadDictionary 从词典文件加载，文件格式见ParseDictionary
+func LoadDictionary(path string) (*Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dictionary: %w", err)
+	}
+	defer f.Close()
+	return ParseDictionary(f)
+}
+
+// ParseDictionary 解析词典，每行一条，空行和以"#"开头的行被忽略：
+//
+//	sword = 剑
+//	low poly = 低多边形
+//	the =
+//
+// 英文部分不区分大小写，可以是多个单词组成的短语；译文为空表示删除该词
+func ParseDictionary(r io.Reader) (*Dictionary, error) {
+	d := &Dictionary{}
+	err := parsePairs(r, func(key, value string) error {
+		d.Add(key, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Add 添加词条
+func (d *Dictionary) Add(phrase, translation string) {
+	words := strings.Fields(strings.ToLower(phrase))
+	if len(words) == 0 {
+		return
+	}
+	if d.entries == nil {
+		d.entries = make(map[string]string)
+	}
+	d.entries[strings.Join(words, " ")] = strings.TrimSpace(translation)
+	d.maxWords = max(d.maxWords, len(words))
+}
+
+// Len 词条数量
+func (d *Dictionary) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.entries)
+}
+
+type segment struct {
+	text       string
+	word       bool
+	translated bool
+}
+
+// Translate 实现Translator
+func (d *Dictionary) Translate(ctx context.Context, text string) (string, error) {
+	tokens := tokenize(text)
+	var out []segment
+	for i := 0; i < len(tokens); {
+		if !tokens[i].word {
+			out = append(out, tokens[i])
+			i++
+			continue
+		}
+		translation, next, ok := d.lookup(tokens, i)
+		if !ok {
+			out = append(out, tokens[i])
+			i++
+			continue
+		}
+		if translation != "" {
+			out = append(out, segment{text: translation, translated: true})
+		}
+		i = next
+	}
+	return join(out), nil
+}
+
+// lookup 从第i个词开始查找最长的词典短语，短语中的单词之间只允许空格
+func (d *Dictionary) lookup(tokens []segment, i int) (translation string, next int, ok bool) {
+	var words []string
+	var ends []int
+	for j := i; j < len(tokens) && len(words) < d.maxWords; j += 2 {
+		if !tokens[j].word {
+			break
+		}
+		words = append(words, strings.ToLower(tokens[j].text))
+		ends = append(ends, j+1)
+		if j+1 >= len(tokens) || strings.TrimLeft(tokens[j+1].text, " ") != "" {
+			break
+		}
+	}
+
+	for n := len(words); n > 0; n-- {
+		if t, found := d.entries[strings.Join(words[:n], " ")]; found {
+			return t, ends[n-1], true
+		}
+	}
+	// 简单处理英文复数
+	if singular, found := strings.CutSuffix(words[0], "s"); found && singular != "" {
+		if t, found := d.entries[singular]; found {
+			return t, ends[0], true
+		}
+	}
+	return "", 0, false
+}
+
+// tokenize 将文本切分为单词和非单词片段，两者交替出现
+func tokenize(text string) []segment {
+	var tokens []segment
+	start := 0
+	inWord := false
+	for i, r := range text {
+		isWord := r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '\'')
+		if i > 0 && isWord != inWord {
+			tokens = append(tokens, segment{text: text[start:i], word: inWord})
+			start = i
+		}
+		inWord = isWord
+	}
+	if start < len(text) {
+		tokens = append(tokens, segment{text: text[start:], word: inWord})
+	}
+	return tokens
+}
+
+// join 拼接翻译结果，两段中文之间的空格被去掉，删除的词留下的多余空格被合并
+func join(segments []segment) string {
+	var b strings.Builder
+	var prev *segment
+	for i := range segments {
+		seg := &segments[i]
+		if strings.TrimSpace(seg.text) == "" {
+			var next *segment
+			if i+1 < len(segments) {
+				next = &segments[i+1]
+			}
+			if prev == nil || next == nil || prev.translated && next.translated || strings.TrimSpace(next.text) == "" {
+				continue
+			}
+			b.WriteString(" ")
+			continue
+		}
+		b.WriteString(seg.text)
+		prev = seg
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// parsePairs 解析"key = value"格式的文件，空行和以"#"开头的行被忽略
+func parsePairs(r io.Reader, add func(key, value string) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("line %d: expected \"key = value\"", lineNo)
+		}
+		if err := add(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/mail"
+	"3d-model-generator-backend/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAccountToken  = errors.New("链接无效或已过期")
+	ErrEmailAlreadyVerified = errors.New("邮箱已验证")
+	ErrMailThrottled        = errors.New("邮件发送过于频繁，请稍后再试")
+)
+
+// AccountOptions 邮件中的链接和令牌有效期
+type AccountOptions struct {
+	VerifyEmailURL     string        // 邮箱验证页面的绝对地址，令牌以?token=附加
+	ResetPasswordURL   string        // 密码重置页面的绝对地址，令牌以?token=附加
+	DeleteAccountURL   string        // 确认注销页面的绝对地址，令牌以?token=附加
+	VerificationExpiry time.Duration // 验证链接有效期
+	ResetExpiry        time.Duration // 重置链接有效期
+	ResendInterval     time.Duration // 同一用户同类邮件的最小发送间隔
+	AdminEmails        []string      // 尚无管理员时，这些邮箱验证后成为管理员
+}
+
+// AccountService 邮箱验证和找回密码
+// 令牌通过邮件发送，数据库只保存哈希；每个令牌只能使用一次，签发新令牌时同类旧令牌作废
+type AccountService struct {
+	db      *gorm.DB
+	mailer  mail.Mailer
+	options AccountOptions
+}
+
+func NewAccountService(db *gorm.DB, mailer mail.Mailer, options AccountOptions) *AccountService {
+	if options.VerificationExpiry <= 0 {
+		options.VerificationExpiry = 48 * time.Hour
+	}
+	if options.ResetExpiry <= 0 {
+		options.ResetExpiry = time.Hour
+	}
+	if options.ResendInterval <= 0 {
+		options.ResendInterval = time.Minute
+	}
+	return &AccountService{
+		db:      db,
+		mailer:  mailer,
+		options: options,
+	}
+}
+
+// SendVerification 向用户当前的邮箱发送验证链接
+func (s *AccountService) SendVerification(ctx context.Context, userID string) error {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := s.issue(ctx, &user, models.TokenPurposeVerifyEmail, s.options.VerificationExpiry)
+	if err != nil {
+		return err
+	}
+	s.deliver(mail.Message{
+		To:      user.Email,
+		Subject: "验证你的邮箱",
+		Text: fmt.Sprintf("%s，你好：\n\n请打开以下链接完成邮箱验证，链接%s内有效：\n\n%s\n\n如果这不是你本人的操作，请忽略本邮件。\n",
+			displayName(&user), formatExpiry(s.options.VerificationExpiry), withToken(s.options.VerifyEmailURL, token)),
+	})
+	return nil
+}
+
+// VerifyEmail 使用验证令牌确认邮箱
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record, err := consumeToken(tx, token, models.TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND is_active = ?", record.UserID, true).First(&user).Error; err != nil {
+			return ErrInvalidAccountToken
+		}
+		if !strings.EqualFold(user.Email, record.Email) {
+			return ErrInvalidAccountToken
+		}
+		if user.EmailVerified {
+			return nil
+		}
+
+		now := time.Now()
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return bootstrapAdmin(tx, s.options.AdminEmails, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RequestPasswordReset 向邮箱发送密码重置链接
+// 邮箱未注册、账号已停用或发送过于频繁时同样返回成功，避免通过该接口探测已注册的邮箱
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("LOWER(email) = ? AND is_active = ?", normalizeEmail(email), true).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	token, err := s.issue(ctx, &user, models.TokenPurposeResetPassword, s.options.ResetExpiry)
+	if errors.Is(err, ErrMailThrottled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.deliver(mail.Message{
+		To:      user.Email,
+		Subject: "重置你的密码",
+		Text: fmt.Sprintf("%s，你好：\n\n我们收到了重置密码的请求，请打开以下链接设置新密码，链接%s内有效且只能使用一次：\n\n%s\n\n如果这不是你本人的操作，请忽略本邮件，你的密码不会改变。\n",
+			displayName(&user), formatExpiry(s.options.ResetExpiry), withToken(s.options.ResetPasswordURL, token)),
+	})
+	return nil
+}
+
+// SendDeletionConfirmation 向没有密码的账号发送注销确认链接，链接的有效期与密码重置相同
+// 能收到邮件说明操作者掌握该邮箱，确认令牌代替密码用于注销；发送过于频繁时不重复发送
+func (s *AccountService) SendDeletionConfirmation(ctx context.Context, user *models.User) error {
+	token, err := s.issue(ctx, user, models.TokenPurposeDeleteAccount, s.options.ResetExpiry)
+	if errors.Is(err, ErrMailThrottled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.deliver(mail.Message{
+		To:      user.Email,
+		Subject: "确认注销你的账号",
+		Text: fmt.Sprintf("%s，你好：\n\n我们收到了注销账号的申请。如果确认注销，请打开以下链接，链接%s内有效且只能使用一次：\n\n%s\n\n注销后账号及其全部数据将被删除。如果这不是你本人的操作，请忽略本邮件。\n",
+			displayName(user), formatExpiry(s.options.ResetExpiry), withToken(s.options.DeleteAccountURL, token)),
+	})
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码
+// 已签发的访问令牌全部失效，所有会话被撤销；能收到邮件说明用户掌握该邮箱，同时标记邮箱已验证
+func (s *AccountService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("密码加密失败")
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record, err := consumeToken(tx, token, models.TokenPurposeResetPassword)
+		if err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.Where("id = ? AND is_active = ?", record.UserID, true).First(&user).Error; err != nil {
+			return ErrInvalidAccountToken
+		}
+		if !strings.EqualFold(user.Email, record.Email) {
+			return ErrInvalidAccountToken
+		}
+
+		updates := map[string]interface{}{"password_hash": string(hashedPassword)}
+		if !user.EmailVerified {
+			user.EmailVerified = true
+			updates["email_verified"] = true
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		if err := bootstrapAdmin(tx, s.options.AdminEmails, &user); err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, user.ID, RevokeReasonPasswordReset)
+	})
+}
+
+// issue 签发新令牌并作废该用户同类未使用的令牌，距上次签发不足ResendInterval时返回ErrMailThrottled
+func (s *AccountService) issue(ctx context.Context, user *models.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last models.AccountToken
+		err := tx.Where("user_id = ? AND purpose = ?", user.ID, purpose).Order("created_at DESC").First(&last).Error
+		if err == nil && time.Since(last.CreatedAt) < s.options.ResendInterval {
+			return ErrMailThrottled
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.AccountToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+			Update("expires_at", now).Error; err != nil {
+			return fmt.Errorf("failed to invalidate tokens: %w", err)
+		}
+		return tx.Create(&models.AccountToken{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			TokenHash: hashToken(token),
+			Email:     user.Email,
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// deliver 异步发送邮件，接口的响应时间不受邮件服务器影响，也不会暴露邮箱是否已注册
+func (s *AccountService) deliver(msg mail.Message) {
+	deliverMail(s.mailer, msg)
+}
+
+func deliverMail(mailer mail.Mailer, msg mail.Message) {
+	go func() {
+		if err := mailer.Send(context.Background(), msg); err != nil {
+			log.Printf("Failed to send mail %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// consumeToken 将未过期、未使用的令牌标记为已使用并返回，并发使用同一个令牌时只有一个请求能成功
+func consumeToken(tx *gorm.DB, token, purpose string) (*models.AccountToken, error) {
+	record, err := findToken(tx, token, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if err := markTokenUsed(tx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// findToken 查找未过期、未使用的令牌，不标记为已使用
+func findToken(tx *gorm.DB, token, purpose string) (*models.AccountToken, error) {
+	if token == "" {
+		return nil, ErrInvalidAccountToken
+	}
+	var record models.AccountToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).First(&record).Error; err != nil {
+		return nil, ErrInvalidAccountToken
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, ErrInvalidAccountToken
+	}
+	return &record, nil
+}
+
+// markTokenUsed 以未使用为条件标记令牌，已被其他请求使用时返回ErrInvalidAccountToken
+func markTokenUsed(tx *gorm.DB, record *models.AccountToken) error {
+	now := time.Now()
+	result := tx.Model(&models.AccountToken{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to consume token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidAccountToken
+	}
+	record.UsedAt = &now
+	return nil
+}
+
+func withToken(link, token string) string {
+	sep := "?"
+	if strings.Contains(link, "?") {
+		sep = "&"
+	}
+	return link + sep + "token=" + url.QueryEscape(token)
+}
+
+func displayName(user *models.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.Email
+}
+
+// formatExpiry 将有效期格式化为邮件中的中文描述
+func formatExpiry(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d天", d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d小时", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d分钟", int(d.Minutes()))
+	}
+}
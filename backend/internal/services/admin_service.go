@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"3d-model-generator-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLastAdmin         = errors.New("cannot remove the last active admin")
+	ErrSelfModification  = errors.New("cannot change your own role or status")
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrInvalidUserFilter = errors.New("invalid user filter")
+)
+
+// UserFilter 用户列表筛选条件，零值表示不筛选
+type UserFilter struct {
+	Query  string // 按邮箱或名称模糊匹配
+	Role   string
+	Active *bool
+}
+
+// AdminService 用户管理和跨用户的任务查询
+type AdminService struct {
+	db *gorm.DB
+}
+
+func NewAdminService(db *gorm.DB) *AdminService {
+	return &AdminService{db: db}
+}
+
+// BootstrapAdmins 还没有管理员时，将配置中的邮箱对应的已注册用户提升为管理员，返回提升的数量
+// 只提升邮箱已验证的用户，注册时填写的邮箱未经确认，不能作为授予权限的依据；
+// 已有管理员后配置不再生效，之后的角色变更只能通过管理接口完成
+func (s *AdminService) BootstrapAdmins(ctx context.Context, emails []string) (int64, error) {
+	normalized := normalizeEmails(emails)
+	if len(normalized) == 0 {
+		return 0, nil
+	}
+
+	var admins int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		return 0, fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins > 0 {
+		return 0, nil
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("LOWER(email) IN ? AND email_verified = ?", normalized, true).
+		Update("role", models.RoleAdmin)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to bootstrap admins: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// bootstrapAdmin 用户的邮箱刚被确认（验证邮件、重置密码或身份提供方验证）时调用：
+// 还没有管理员且邮箱在配置中时提升为管理员
+func bootstrapAdmin(tx *gorm.DB, emails []string, user *models.User) error {
+	if !user.EmailVerified || user.Role == models.RoleAdmin || !slices.Contains(normalizeEmails(emails), normalizeEmail(user.Email)) {
+		return nil
+	}
+
+	var admins int64
+	if err := tx.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	if err := tx.Model(user).Update("role", models.RoleAdmin).Error; err != nil {
+		return fmt.Errorf("failed to bootstrap admin: %w", err)
+	}
+	user.Role = models.RoleAdmin
+	log.Printf("Bootstrapping %s as the first admin", user.Email)
+	return nil
+}
+
+func normalizeEmails(emails []string) []string {
+	normalized := make([]string, 0, len(emails))
+	for _, email := range emails {
+		if email = normalizeEmail(email); email != "" {
+			normalized = append(normalized, email)
+		}
+	}
+	return normalized
+}
+
+// ListUsers 分页获取用户，按注册时间倒序
+func (s *AdminService) ListUsers(ctx context.Context, filter UserFilter, limit, offset int) ([]models.User, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.User{})
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(name) LIKE ?", like, like)
+	}
+	if filter.Role != "" {
+		if !models.ValidRole(filter.Role) {
+			return nil, 0, fmt.Errorf("%w: unknown role %q", ErrInvalidUserFilter, filter.Role)
+		}
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Active != nil {
+		query = query.Where("is_active = ?", *filter.Active)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []models.User
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, total, nil
+}
+
+// GetUser 获取用户，包括已停用的用户
+func (s *AdminService) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// SetRole 修改用户角色，不能修改自己的角色，也不能降级最后一个有效的管理员
+func (s *AdminService) SetRole(ctx context.Context, actorID, userID, role string) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+	if actorID == userID {
+		return nil, ErrSelfModification
+	}
+
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if user.Role == models.RoleAdmin && role != models.RoleAdmin {
+			if err := ensureOtherAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+		user.Role = role
+		return tx.Model(&user).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserActive 启用或停用账号，停用时同时使已签发的令牌失效
+func (s *AdminService) SetUserActive(ctx context.Context, actorID, userID string, active bool) error {
+	if actorID == userID {
+		return ErrSelfModification
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if !active && user.Role == models.RoleAdmin {
+			if err := ensureOtherAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&user).Update("is_active", active).Error; err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		if active {
+			return nil
+		}
+		return revokeUserTokens(tx, userID, RevokeReasonDeactivated)
+	})
+}
+
+// ForceLogout 使用户已签发的访问令牌立即失效，并撤销所有会话
+func (s *AdminService) ForceLogout(ctx context.Context, userID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return revokeUserTokens(tx, userID, RevokeReasonForced)
+	})
+}
+
+// ResetTwoFactor 关闭用户的两步验证（用户丢失验证器和恢复码时），同时使已签发的令牌失效
+func (s *AdminService) ResetTwoFactor(ctx context.Context, actorID, userID string) error {
+	if actorID == userID {
+		return ErrSelfModification
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", userID).First(&models.User{}).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if err := clearTwoFactor(tx, userID); err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, userID, RevokeReasonForced)
+	})
+}
+
+// UnlockLogin 解除用户邮箱的登录锁定，之前的失败次数不再计入
+func (s *AdminService) UnlockLogin(ctx context.Context, actorID, userID string) error {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	event := &models.AuthEvent{
+		Type:   models.AuthEventLoginUnlocked,
+		UserID: user.ID,
+		Email:  normalizeEmail(user.Email),
+		Reason: "unlocked by " + actorID,
+	}
+	if err := s.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to unlock login: %w", err)
+	}
+	return nil
+}
+
+// AuthEventFilter 认证审计事件筛选条件，零值表示不筛选
+type AuthEventFilter struct {
+	Type   string
+	UserID string
+	Email  string
+	IP     string
+}
+
+// ListAuthEvents 分页获取认证审计事件，按时间倒序
+func (s *AdminService) ListAuthEvents(ctx context.Context, filter AuthEventFilter, limit, offset int) ([]models.AuthEvent, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.AuthEvent{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Email != "" {
+		query = query.Where("email = ?", normalizeEmail(filter.Email))
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count auth events: %w", err)
+	}
+
+	var events []models.AuthEvent
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list auth events: %w", err)
+	}
+	return events, total, nil
+}
+
+// ListJobs 分页获取任意用户的任务，userID和status为空时不筛选
+func (s *AdminService) ListJobs(ctx context.Context, userID, status string, limit, offset int) ([]models.GenerationJob, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.GenerationJob{})
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	var jobs []models.GenerationJob
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// GetJob 获取任意用户的任务
+func (s *AdminService) GetJob(ctx context.Context, jobID string) (*models.GenerationJob, error) {
+	var job models.GenerationJob
+	if err := s.db.WithContext(ctx).Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return &job, nil
+}
+
+// ensureOtherAdmin 除userID以外至少还有一个有效的管理员
+func ensureOtherAdmin(tx *gorm.DB, userID string) error {
+	var count int64
+	err := tx.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id <> ?", models.RoleAdmin, true, userID).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if count == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrInvalidScope   = errors.New("invalid api key scope")
+	ErrInvalidExpiry  = errors.New("invalid api key expiry")
+	ErrTooManyAPIKeys = errors.New("too many api keys")
+)
+
+// API密钥的权限范围，每个范围对应一组路由
+const (
+	ScopeGenerate    = "generate"    // 创建生成任务、预览提示词
+	ScopeJobs        = "jobs"        // 查询任务、下载和变换模型、统计和存储用量
+	ScopeUploads     = "uploads"     // 上传图片和图片库
+	ScopeEvaluations = "evaluations" // 评估和A/B测试
+	ScopePrompts     = "prompts"     // 提示词模板和风格预设
+)
+
+// APIKeyScopes 所有可分配的权限范围
+var APIKeyScopes = []string{ScopeGenerate, ScopeJobs, ScopeUploads, ScopeEvaluations, ScopePrompts}
+
+const (
+	// APIKeyPrefix 密钥的固定前缀，便于在日志和代码中识别泄露的密钥
+	APIKeyPrefix = "sk_"
+	// MaxAPIKeysPerUser 每个用户未撤销的密钥数量上限
+	MaxAPIKeysPerUser = 20
+
+	apiKeyVisibleChars = 8
+	// 最后使用时间的更新间隔，避免每个请求都写数据库
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService 管理个人API密钥，数据库只保存密钥哈希
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// IsAPIKey 判断凭据是否为API密钥（而不是JWT）
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// Create 创建密钥，返回的Key只在创建时可见
+func (s *APIKeyService) Create(ctx context.Context, userID string, req *models.APIKeyRequest) (*models.APIKeyCreateResponse, error) {
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiry)
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to count api keys: %w", err)
+	}
+	if count >= MaxAPIKeysPerUser {
+		return nil, fmt.Errorf("%w: at most %d active keys", ErrTooManyAPIKeys, MaxAPIKeysPerUser)
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := APIKeyPrefix + secret
+
+	apiKey := models.APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    key[:len(APIKeyPrefix)+apiKeyVisibleChars],
+		KeyHash:   hashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.db.WithContext(ctx).Create(&apiKey).Error; err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return &models.APIKeyCreateResponse{APIKey: apiKey, Key: key}, nil
+}
+
+// List 获取用户未撤销的密钥
+func (s *APIKeyService) List(ctx context.Context, userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke 撤销密钥，撤销后立即失效
+func (s *APIKeyService) Revoke(ctx context.Context, userID, keyID string) error {
+	result := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke api key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate 校验密钥，返回密钥记录，并按间隔更新最后使用时间
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	if !IsAPIKey(key) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var apiKey models.APIKey
+	if err := s.db.WithContext(ctx).Where("key_hash = ?", hashToken(key)).First(&apiKey).Error; err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if apiKey.RevokedAt != nil || apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		apiKey.LastUsedAt = &now
+		s.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", apiKey.ID).Update("last_used_at", now)
+	}
+	return &apiKey, nil
+}
+
+// normalizeScopes 去重排序，拒绝未知的权限范围
+func normalizeScopes(scopes []string) ([]string, error) {
+	var out []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(APIKeyScopes, scope) {
+			return nil, fmt.Errorf("%w: %q, allowed: %s", ErrInvalidScope, scope, strings.Join(APIKeyScopes, ", "))
+		}
+		if !slices.Contains(out, scope) {
+			out = append(out, scope)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
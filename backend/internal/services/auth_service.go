@@ -1,7 +1,17 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"3d-model-generator-backend/internal/models"
@@ -11,29 +21,122 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("无效的刷新令牌")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，会话已撤销")
+	ErrAuthSessionNotFound = errors.New("会话不存在")
+	ErrTokenRevoked        = errors.New("token已失效，请重新登录")
+	ErrInvalidChallenge    = errors.New("登录验证已过期，请重新登录")
+)
+
+// loginChallengeExpiry 密码验证通过后完成两步验证的时限
+const loginChallengeExpiry = 5 * time.Minute
+
+// refreshTokenHistory 会话保留的已轮换刷新令牌哈希数量，超出后更早的令牌视为无效令牌而不是重用
+const refreshTokenHistory = 5
+
+// 会话撤销原因
+const (
+	RevokeReasonLogout          = "logout"
+	RevokeReasonRevoked         = "revoked"
+	RevokeReasonPasswordChanged = "password_changed"
+	RevokeReasonPasswordReset   = "password_reset"
+	RevokeReasonTokenReuse      = "refresh_token_reuse"
+	RevokeReasonForced          = "forced_logout"
+	RevokeReasonDeactivated     = "deactivated"
+	RevokeReasonSuspended       = "suspended"
+)
+
 type AuthService struct {
 	db        *gorm.DB
-	jwtSecret string
+	keys      *Keyring
+	options   AuthOptions
+	twoFactor *TwoFactorService
+	guard     *loginGuard
+	// 账号不存在时用于比较的哈希，使两种失败路径耗时一致
+	dummyHash []byte
+}
+
+// AuthOptions 令牌有效期
+type AuthOptions struct {
+	TokenExpiry        time.Duration // 访问令牌有效期
+	RefreshTokenExpiry time.Duration // 刷新令牌有效期，每次刷新后重新计算
+	Login              LoginPolicy   // 登录失败次数限制
+}
+
+// ClientInfo 登录设备信息，记录在会话中
+type ClientInfo struct {
+	UserAgent string
+	IP        string
 }
 
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID       string `json:"user_id"`
+	Email        string `json:"email"`
+	SessionID    string `json:"sid,omitempty"`
+	TokenVersion int    `json:"ver"` // 签发时用户的令牌版本，与当前版本不一致的令牌无效
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(db *gorm.DB, jwtSecret string) *AuthService {
+func NewAuthService(db *gorm.DB, keys *Keyring, twoFactor *TwoFactorService, options AuthOptions) *AuthService {
+	if options.TokenExpiry <= 0 {
+		options.TokenExpiry = 15 * time.Minute
+	}
+	if options.RefreshTokenExpiry <= 0 {
+		options.RefreshTokenExpiry = 30 * 24 * time.Hour
+	}
+	secret, err := randomToken()
+	if err != nil {
+		panic(fmt.Sprintf("failed to generate random token: %v", err))
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("failed to hash dummy password: %v", err))
+	}
 	return &AuthService{
 		db:        db,
-		jwtSecret: jwtSecret,
+		keys:      keys,
+		options:   options,
+		twoFactor: twoFactor,
+		guard:     newLoginGuard(db, options.Login),
+		dummyHash: dummyHash,
+	}
+}
+
+// Start 定时清理过期的认证审计事件
+func (s *AuthService) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
 	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := s.guard.prune()
+				if err != nil {
+					log.Printf("Auth event cleanup failed: %v", err)
+					continue
+				}
+				if deleted > 0 {
+					log.Printf("Auth event cleanup deleted %d events", deleted)
+				}
+			}
+		}
+	}()
 }
 
 // Register 用户注册
-func (s *AuthService) Register(req *models.AuthRequest) (*models.AuthResponse, error) {
+func (s *AuthService) Register(req *models.AuthRequest, client ClientInfo) (*models.AuthResponse, error) {
+	// 邮箱不区分大小写，统一保存为规范形式
+	email := normalizeEmail(req.Email)
+
 	// 检查邮箱是否已存在
 	var existingUser models.User
-	if err := s.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := s.db.Where("LOWER(email) = ?", email).First(&existingUser).Error; err == nil {
 		return nil, errors.New("邮箱已被注册")
 	}
 
@@ -45,9 +148,10 @@ func (s *AuthService) Register(req *models.AuthRequest) (*models.AuthResponse, e
 
 	// 创建用户
 	user := &models.User{
-		Email:        req.Email,
+		Email:        email,
 		Name:         req.Name,
 		PasswordHash: string(hashedPassword),
+		Role:         models.RoleUser,
 		IsActive:     true,
 	}
 
@@ -55,60 +159,267 @@ func (s *AuthService) Register(req *models.AuthRequest) (*models.AuthResponse, e
 		return nil, errors.New("用户创建失败")
 	}
 
-	// 生成JWT token
-	token, expiresAt, err := s.generateToken(user)
+	// 创建会话并生成令牌
+	response, err := s.startSession(user, client)
 	if err != nil {
-		return nil, errors.New("Token生成失败")
+		return nil, err
 	}
-
-	return &models.AuthResponse{
-		Token:     token,
-		User:      *user,
-		ExpiresAt: expiresAt,
-		Message:   "注册成功",
-	}, nil
+	response.Message = "注册成功"
+	return response, nil
 }
 
 // Login 用户登录
-func (s *AuthService) Login(req *models.AuthRequest) (*models.AuthResponse, error) {
+// 邮箱或IP失败次数过多、或距上一次失败不足延迟时间时返回*LoginLockedError；账号不存在、已停用和密码错误返回相同的错误，
+// 且都会执行一次bcrypt比较，响应时间不会暴露账号是否存在
+// 开启两步验证的用户密码验证通过后只返回challenge，需调用CompleteTwoFactorLogin完成登录
+func (s *AuthService) Login(req *models.AuthRequest, client ClientInfo) (*models.AuthResponse, *models.TwoFactorChallenge, error) {
+	email := normalizeEmail(req.Email)
+	release, err := s.guard.check(email, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
 	// 查找用户
 	var user models.User
-	if err := s.db.Where("email = ? AND is_active = ?", req.Email, true).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("邮箱或密码错误")
-		}
-		return nil, errors.New("用户查找失败")
+	passwordHash := s.dummyHash
+	reason := "unknown_email"
+	err = s.db.Where("LOWER(email) = ? AND is_active = ?", email, true).First(&user).Error
+	switch {
+	case err == nil:
+		passwordHash = []byte(user.PasswordHash)
+		reason = "wrong_password"
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil, errors.New("用户查找失败")
 	}
 
 	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, errors.New("邮箱或密码错误")
+	if err := bcrypt.CompareHashAndPassword(passwordHash, []byte(req.Password)); err != nil || user.ID == "" {
+		s.guard.fail(user.ID, email, client, reason)
+		return nil, nil, errors.New("邮箱或密码错误")
+	}
+
+	// 两步验证完成前不计为登录成功，失败次数不清零
+	if user.TwoFactorEnabled {
+		challenge, err := s.issueChallenge(&user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, challenge, nil
+	}
+
+	response, err := s.completeLogin(&user, client, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return response, nil, nil
+}
+
+// LoginExternal 为已通过外部身份（如OIDC）认证的用户登录，method记录在审计事件中
+// 开启两步验证的用户同样需要完成两步验证
+func (s *AuthService) LoginExternal(user *models.User, client ClientInfo, method string) (*models.AuthResponse, *models.TwoFactorChallenge, error) {
+	if !user.IsActive {
+		return nil, nil, ErrUserNotFound
+	}
+	if user.TwoFactorEnabled {
+		challenge, err := s.issueChallenge(user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, challenge, nil
 	}
 
+	response, err := s.completeLogin(user, client, method)
+	if err != nil {
+		return nil, nil, err
+	}
+	return response, nil, nil
+}
+
+// CompleteTwoFactorLogin 使用challenge和验证码（或恢复码）完成登录
+// 验证码错误与密码错误一样计入失败次数，达到上限后锁定
+func (s *AuthService) CompleteTwoFactorLogin(req *models.TwoFactorLoginRequest, client ClientInfo) (*models.AuthResponse, error) {
+	record, err := findToken(s.db, req.ChallengeToken, models.TokenPurposeLoginChallenge)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	release, err := s.guard.check(record.Email, client)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var user models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND is_active = ?", record.UserID, true).First(&user).Error; err != nil {
+			return ErrInvalidChallenge
+		}
+		if !user.TwoFactorEnabled {
+			return ErrInvalidChallenge
+		}
+		if err := s.twoFactor.Verify(tx, &user, req.Code); err != nil {
+			return err
+		}
+		if err := markTokenUsed(tx, record); err != nil {
+			return ErrInvalidChallenge
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		s.guard.fail(user.ID, record.Email, client, "wrong_two_factor_code")
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(&user, client, "two_factor")
+}
+
+// issueChallenge 为密码验证通过的用户签发一次性的两步验证challenge
+func (s *AuthService) issueChallenge(user *models.User) (*models.TwoFactorChallenge, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, errors.New("Token生成失败")
+	}
+	expiresAt := time.Now().Add(loginChallengeExpiry)
+	if err := s.db.Create(&models.AccountToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposeLoginChallenge,
+		TokenHash: hashToken(token),
+		Email:     normalizeEmail(user.Email),
+		ExpiresAt: expiresAt,
+	}).Error; err != nil {
+		return nil, errors.New("登录验证创建失败")
+	}
+	return &models.TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+		Message:           "请输入验证器应用中的验证码",
+	}, nil
+}
+
+// completeLogin 记录登录成功，更新最后登录时间并创建会话
+func (s *AuthService) completeLogin(user *models.User, client ClientInfo, method string) (*models.AuthResponse, error) {
+	s.guard.succeed(user, client, method)
+
 	// 更新最后登录时间
 	now := time.Now()
 	user.LastLoginAt = &now
-	s.db.Save(&user)
+	s.db.Model(user).Update("last_login_at", now)
+
+	// 创建会话并生成令牌
+	response, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	response.Message = "登录成功"
+	return response, nil
+}
 
-	// 生成JWT token
-	token, expiresAt, err := s.generateToken(&user)
+// Refresh 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效
+// 已轮换的刷新令牌再次出现说明令牌可能被盗用，此时撤销整个会话；
+// 会话ID是公开的（JWT的sid、会话列表），与任何轮换过的令牌都不匹配的请求只返回错误，不撤销会话
+func (s *AuthService) Refresh(refreshToken string, client ClientInfo) (*models.AuthResponse, error) {
+	session, secret, err := s.findSession(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	oldHash := hashToken(secret)
+	if subtle.ConstantTimeCompare([]byte(oldHash), []byte(session.RefreshTokenHash)) != 1 {
+		if rotatedOut(session, oldHash) {
+			s.revokeReusedSession(session)
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newSecret, err := randomToken()
 	if err != nil {
 		return nil, errors.New("Token生成失败")
 	}
+	now := time.Now()
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(s.options.RefreshTokenExpiry)
+
+	// 以旧哈希为条件更新，并发使用同一个刷新令牌时只有一个请求能成功
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": hashToken(newSecret),
+			"rotated_hashes":     rotatedHashes(session, oldHash),
+			"last_used_at":       session.LastUsedAt,
+			"expires_at":         session.ExpiresAt,
+			"user_agent":         client.UserAgent,
+			"ip":                 client.IP,
+		})
+	if result.Error != nil {
+		return nil, errors.New("会话更新失败")
+	}
+	if result.RowsAffected == 0 {
+		s.revokeReusedSession(session)
+		return nil, ErrRefreshTokenReused
+	}
 
-	return &models.AuthResponse{
-		Token:     token,
-		User:      user,
-		ExpiresAt: expiresAt,
-		Message:   "登录成功",
-	}, nil
+	return s.buildResponse(user, session, newSecret)
+}
+
+// Logout 撤销刷新令牌对应的会话
+func (s *AuthService) Logout(refreshToken string) error {
+	session, secret, err := s.findSession(refreshToken)
+	if err != nil {
+		return err
+	}
+	// 不匹配的令牌不触发重用检测，避免登出请求撤销他人的会话
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(session.RefreshTokenHash)) != 1 {
+		return ErrInvalidRefreshToken
+	}
+	_, err = revokeSessions(s.db.Where("id = ?", session.ID), RevokeReasonLogout)
+	return err
+}
+
+// ListSessions 获取用户未过期、未撤销的会话，currentID对应的会话标记为当前会话
+func (s *AuthService) ListSessions(userID, currentID string) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, errors.New("会话查询失败")
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession 撤销用户的某个会话
+func (s *AuthService) RevokeSession(userID, sessionID, reason string) error {
+	revoked, err := revokeSessions(s.db.Where("id = ? AND user_id = ?", sessionID, userID), reason)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrAuthSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions 撤销用户除keepID以外的所有会话，keepID为空时撤销全部，返回撤销的数量
+func (s *AuthService) RevokeOtherSessions(userID, keepID, reason string) (int64, error) {
+	return revokeSessions(s.db.Where("user_id = ? AND id <> ?", userID, keepID), reason)
 }
 
 // ValidateToken 验证JWT token
 func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keys.Keyfunc)
 
 	if err != nil {
 		return nil, err
@@ -121,6 +432,41 @@ func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 	return nil, errors.New("无效的token")
 }
 
+// JWKS 验证访问令牌的公钥，供其他服务使用
+func (s *AuthService) JWKS() models.JSONWebKeySet {
+	return s.keys.JWKS()
+}
+
+// Authenticate 验证访问令牌并返回对应的用户
+// 每次请求都会从数据库读取用户和令牌所属的会话，令牌版本、账号状态和会话撤销（登出、撤销会话、
+// 刷新令牌重用等）立即生效，不需要额外的撤销列表
+func (s *AuthService) Authenticate(tokenString string) (*models.User, *Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+	if claims.TokenVersion != user.TokenVersion {
+		return nil, nil, ErrTokenRevoked
+	}
+	if claims.SessionID != "" {
+		var active int64
+		err := s.db.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", claims.SessionID, user.ID).
+			Count(&active).Error
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check session: %w", err)
+		}
+		if active == 0 {
+			return nil, nil, ErrTokenRevoked
+		}
+	}
+	return user, claims, nil
+}
+
 // GetUserByID 根据用户ID获取用户信息
 func (s *AuthService) GetUserByID(userID string) (*models.User, error) {
 	var user models.User
@@ -130,13 +476,139 @@ func (s *AuthService) GetUserByID(userID string) (*models.User, error) {
 	return &user, nil
 }
 
+// startSession 为新登录创建会话，返回访问令牌和刷新令牌
+func (s *AuthService) startSession(user *models.User, client ClientInfo) (*models.AuthResponse, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return nil, errors.New("Token生成失败")
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(secret),
+		UserAgent:        client.UserAgent,
+		IP:               client.IP,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.options.RefreshTokenExpiry),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, errors.New("会话创建失败")
+	}
+
+	return s.buildResponse(user, session, secret)
+}
+
+func (s *AuthService) buildResponse(user *models.User, session *models.Session, secret string) (*models.AuthResponse, error) {
+	token, expiresAt, err := s.generateToken(user, session.ID)
+	if err != nil {
+		return nil, errors.New("Token生成失败")
+	}
+	return &models.AuthResponse{
+		Token:            token,
+		RefreshToken:     session.ID + "." + secret,
+		User:             *user,
+		ExpiresAt:        expiresAt,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// findSession 解析"会话ID.随机串"格式的刷新令牌，返回未过期、未撤销的会话
+func (s *AuthService) findSession(refreshToken string) (*models.Session, string, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	var session models.Session
+	if err := s.db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	return &session, secret, nil
+}
+
+// rotatedOut 判断哈希是否属于该会话已轮换掉的刷新令牌
+func rotatedOut(session *models.Session, hash string) bool {
+	for _, rotated := range session.RotatedHashes {
+		if subtle.ConstantTimeCompare([]byte(rotated), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// rotatedHashes 将刚轮换掉的哈希加入历史，只保留最近refreshTokenHistory个
+func rotatedHashes(session *models.Session, hash string) string {
+	hashes := append(session.RotatedHashes, hash)
+	if len(hashes) > refreshTokenHistory {
+		hashes = hashes[len(hashes)-refreshTokenHistory:]
+	}
+	data, _ := json.Marshal(hashes)
+	return string(data)
+}
+
+func (s *AuthService) revokeReusedSession(session *models.Session) {
+	log.Printf("Refresh token reuse detected for session %s of user %s, revoking session", session.ID, session.UserID)
+	if _, err := revokeSessions(s.db.Where("id = ?", session.ID), RevokeReasonTokenReuse); err != nil {
+		log.Printf("Failed to revoke session %s: %v", session.ID, err)
+	}
+}
+
+// revokeUserTokens 递增用户的令牌版本，使已签发的访问令牌全部失效，并撤销所有会话
+func revokeUserTokens(tx *gorm.DB, userID, reason string) error {
+	result := tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	_, err := revokeSessions(tx.Where("user_id = ?", userID), reason)
+	return err
+}
+
+// revokeSessions 撤销query匹配的未撤销会话，返回撤销的数量
+func revokeSessions(query *gorm.DB, reason string) (int64, error) {
+	result := query.Model(&models.Session{}).
+		Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{
+			"revoked_at":    time.Now(),
+			"revoke_reason": reason,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// randomToken 生成刷新令牌的随机部分
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 刷新令牌是高熵随机串，SHA-256即可，不需要慢哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // generateToken 生成JWT token
-func (s *AuthService) generateToken(user *models.User) (string, time.Time, error) {
-	expiresAt := time.Now().Add(24 * time.Hour) // 24小时过期
+func (s *AuthService) generateToken(user *models.User, sessionID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(s.options.TokenExpiry)
 
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:       user.ID,
+		Email:        user.Email,
+		SessionID:    sessionID,
+		TokenVersion: user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -146,8 +618,7 @@ func (s *AuthService) generateToken(user *models.User) (string, time.Time, error
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -155,32 +626,51 @@ func (s *AuthService) generateToken(user *models.User) (string, time.Time, error
 	return tokenString, expiresAt, nil
 }
 
-// ChangePassword 修改密码
-func (s *AuthService) ChangePassword(userID, oldPassword, newPassword string) error {
+// ChangePassword 修改密码，已签发的访问令牌全部失效，除当前会话以外的会话被撤销
+// 返回当前会话的新访问令牌
+func (s *AuthService) ChangePassword(userID, sessionID, oldPassword, newPassword string) (string, time.Time, error) {
 	// 获取用户
 	var user models.User
 	if err := s.db.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
-		return errors.New("用户不存在")
+		return "", time.Time{}, errors.New("用户不存在")
 	}
 
 	// 验证旧密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)); err != nil {
-		return errors.New("旧密码错误")
+		return "", time.Time{}, errors.New("旧密码错误")
 	}
 
 	// 加密新密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
-		return errors.New("密码加密失败")
+		return "", time.Time{}, errors.New("密码加密失败")
 	}
 
-	// 更新密码
-	user.PasswordHash = string(hashedPassword)
-	if err := s.db.Save(&user).Error; err != nil {
-		return errors.New("密码更新失败")
+	// 更新密码并递增令牌版本
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password_hash": string(hashedPassword),
+			"token_version": gorm.Expr("token_version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		_, err = revokeSessions(tx.Where("user_id = ? AND id <> ?", userID, sessionID), RevokeReasonPasswordChanged)
+		return err
+	})
+	if err != nil {
+		return "", time.Time{}, errors.New("密码更新失败")
 	}
 
-	return nil
+	// 当前设备换发新版本的访问令牌，刷新令牌不受影响
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return "", time.Time{}, errors.New("用户不存在")
+	}
+	token, expiresAt, err := s.generateToken(&user, sessionID)
+	if err != nil {
+		return "", time.Time{}, errors.New("Token生成失败")
+	}
+	return token, expiresAt, nil
 }
 
 // UpdateProfile 更新用户资料
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"3d-model-generator-backend/internal/models"
+)
+
+func newTestAuthService(t *testing.T) (*AuthService, *models.AuthResponse) {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.AuthEvent{})
+	keys, err := NewKeyring(db, KeyringOptions{Algorithm: AlgorithmHS256, Secret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	s := NewAuthService(db, keys, nil, AuthOptions{})
+
+	response, err := s.Register(&models.AuthRequest{Email: "user@example.com", Password: "Passw0rd!123", Name: "user"}, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return s, response
+}
+
+func refresh(t *testing.T, s *AuthService, token string) string {
+	t.Helper()
+	response, err := s.Refresh(token, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	return response.RefreshToken
+}
+
+func sessionRevoked(t *testing.T, s *AuthService, token string) bool {
+	t.Helper()
+	sessionID, _, _ := strings.Cut(token, ".")
+	var session models.Session
+	if err := s.db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		t.Fatalf("find session: %v", err)
+	}
+	return session.RevokedAt != nil
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s, login := newTestAuthService(t)
+
+	first := login.RefreshToken
+	second := refresh(t, s, first)
+	if second == first {
+		t.Fatal("Refresh returned the same refresh token")
+	}
+	third := refresh(t, s, second)
+	if sessionRevoked(t, s, third) {
+		t.Fatal("session revoked after normal rotation")
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	s, login := newTestAuthService(t)
+
+	first := login.RefreshToken
+	second := refresh(t, s, first)
+
+	if _, err := s.Refresh(first, ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replaying rotated-out token: error = %v, want ErrRefreshTokenReused", err)
+	}
+	if !sessionRevoked(t, s, second) {
+		t.Fatal("session not revoked after reuse")
+	}
+	if _, err := s.Refresh(second, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("current token after reuse: error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshUnknownSecretKeepsSession(t *testing.T) {
+	s, login := newTestAuthService(t)
+
+	current := refresh(t, s, login.RefreshToken)
+	sessionID, _, _ := strings.Cut(current, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"forged secret", sessionID + ".forged-secret"},
+		{"missing secret", sessionID + "."},
+		{"unknown session", "unknown." + strings.Repeat("a", 43)},
+		{"malformed", "no-separator"},
+	}
+	for _, tt := range tests {
+		if _, err := s.Refresh(tt.token, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("%s: error = %v, want ErrInvalidRefreshToken", tt.name, err)
+		}
+	}
+
+	// 猜测的令牌不能用来撤销他人的会话
+	if sessionRevoked(t, s, current) {
+		t.Fatal("session revoked by a token that was never issued")
+	}
+	refresh(t, s, current)
+}
+
+func TestRefreshReuseHistoryIsBounded(t *testing.T) {
+	s, login := newTestAuthService(t)
+
+	tokens := []string{login.RefreshToken}
+	for i := 0; i <= refreshTokenHistory; i++ {
+		tokens = append(tokens, refresh(t, s, tokens[len(tokens)-1]))
+	}
+
+	// 最早的令牌已超出保留的历史，按未知令牌处理
+	if _, err := s.Refresh(tokens[0], ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("token beyond history: error = %v, want ErrInvalidRefreshToken", err)
+	}
+	current := tokens[len(tokens)-1]
+	if sessionRevoked(t, s, current) {
+		t.Fatal("session revoked by a token beyond the history")
+	}
+
+	// 历史内的令牌仍能识别为重用
+	if _, err := s.Refresh(tokens[1], ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("token within history: error = %v, want ErrRefreshTokenReused", err)
+	}
+	if !sessionRevoked(t, s, current) {
+		t.Fatal("session not revoked after reuse within history")
+	}
+}
+
+func TestAuthenticateRejectsRevokedSession(t *testing.T) {
+	s, login := newTestAuthService(t)
+
+	user, claims, err := s.Authenticate(login.Token)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if err := s.RevokeSession(user.ID, claims.SessionID, RevokeReasonLogout); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, _, err := s.Authenticate(login.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("access token of revoked session: error = %v, want ErrTokenRevoked", err)
+	}
+}
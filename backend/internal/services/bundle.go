@@ -0,0 +1,337 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/mesh"
+	"3d-model-generator-backend/internal/models"
+)
+
+// Bundle 任务打包下载，包含模型、材质、贴图、预览图和manifest.json
+type Bundle struct {
+	service *ModelService
+	job     *models.GenerationJob
+}
+
+// BundleManifest 打包清单
+type BundleManifest struct {
+	JobID       string                    `json:"job_id"`
+	Prompt      string                    `json:"prompt,omitempty"`
+	ImageURL    string                    `json:"image_url,omitempty"`
+	InputType   string                    `json:"input_type"`
+	Options     *models.GenerationOptions `json:"options,omitempty"`
+	Status      string                    `json:"status"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	UpdatedAt   time.Time                 `json:"updated_at"`
+	CompletedAt *time.Time                `json:"completed_at,omitempty"`
+	GeneratedAt time.Time                 `json:"generated_at"`
+	Files       []BundleFile              `json:"files"`
+	Missing     []string                  `json:"missing,omitempty"` // 无法获取的文件
+	MeshReport  *mesh.Report              `json:"mesh_report,omitempty"`
+	License     string                    `json:"license,omitempty"`
+}
+
+// BundleFile 打包清单中的文件
+type BundleFile struct {
+	Path      string `json:"path"`
+	Kind      string `json:"kind"` // "model", "material", "texture", "preview", "thumbnail"
+	Type      string `json:"type,omitempty"`
+	LOD       int    `json:"lod,omitempty"`
+	Version   int    `json:"version,omitempty"`
+	Size      int64  `json:"size"`
+	SourceURL string `json:"source_url,omitempty"`
+}
+
+// NewBundle 准备用户任务的打包，任务不属于该用户时返回ErrJobNotFound，未完成时返回错误
+func (s *ModelService) NewBundle(ctx context.Context, userID, jobID string) (*Bundle, error) {
+	job, err := s.getCompletedJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{service: s, job: job}, nil
+}
+
+// Filename 下载文件名
+func (b *Bundle) Filename() string {
+	return fmt.Sprintf("job_%s.zip", b.job.ID)
+}
+
+// Write 以流的方式写出ZIP包
+func (b *Bundle) Write(ctx context.Context, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	if err := b.writeTo(ctx, zw, ""); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeTo 将打包内容写入zw的prefix目录下，manifest中的路径相对于prefix
+func (b *Bundle) writeTo(ctx context.Context, zw *zip.Writer, prefix string) error {
+	manifest := &BundleManifest{
+		JobID:       b.job.ID,
+		Prompt:      b.job.Prompt,
+		ImageURL:    b.job.ImageURL,
+		InputType:   b.job.InputType,
+		Options:     b.job.Options,
+		Status:      b.job.Status,
+		CreatedAt:   b.job.CreatedAt,
+		UpdatedAt:   b.job.UpdatedAt,
+		CompletedAt: b.job.CompletedAt,
+		GeneratedAt: time.Now(),
+		License:     b.service.options.BundleLicense,
+	}
+	written := make(map[string]bool)
+
+	add := func(name string, data []byte, file BundleFile) error {
+		name = sanitizeZipPath(name)
+		if name == "" || written[name] {
+			return nil
+		}
+		fw, err := zw.Create(path.Join(prefix, name))
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return err
+		}
+		written[name] = true
+		file.Path = name
+		file.Size = int64(len(data))
+		manifest.Files = append(manifest.Files, file)
+		return nil
+	}
+
+	for _, file := range b.job.ResultFiles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data, err := b.service.fetchResultFile(ctx, file)
+		if err != nil {
+			manifest.Missing = append(manifest.Missing, file.URL)
+			continue
+		}
+
+		dir := "model"
+		name := "model." + file.Type
+		switch {
+		case file.Version > 0:
+			dir = "versions"
+			name = fmt.Sprintf("transform_v%d.%s", file.Version, file.Type)
+		case file.LOD > 0:
+			dir = "lod"
+			name = fmt.Sprintf("lod_%d.%s", file.LOD, file.Type)
+		}
+		entry := BundleFile{Kind: "model", Type: file.Type, LOD: file.LOD, Version: file.Version, SourceURL: file.URL}
+
+		// 腾讯云返回的ZIP包直接展开到model目录
+		if isZip(data) {
+			if err := b.addArchive(dir, data, entry, add); err != nil {
+				manifest.Missing = append(manifest.Missing, file.URL)
+			}
+			continue
+		}
+
+		if err := add(path.Join(dir, name), data, entry); err != nil {
+			return err
+		}
+		if file.Type == "obj" && file.LOD == 0 && file.Version == 0 {
+			b.addMaterials(ctx, dir, file.URL, data, manifest, add)
+		}
+	}
+
+	previews := make(map[string]bool)
+	for _, file := range b.job.ResultFiles {
+		if file.PreviewImageURL == "" || previews[file.PreviewImageURL] {
+			continue
+		}
+		previews[file.PreviewImageURL] = true
+
+		data, err := b.service.download(ctx, file.PreviewImageURL)
+		if err != nil {
+			manifest.Missing = append(manifest.Missing, file.PreviewImageURL)
+			continue
+		}
+		name := fmt.Sprintf("previews/preview_%d%s", len(previews), urlExt(file.PreviewImageURL, ".png"))
+		if err := add(name, data, BundleFile{Kind: "preview", SourceURL: file.PreviewImageURL}); err != nil {
+			return err
+		}
+	}
+
+	for _, thumb := range b.job.Thumbnails {
+		data, err := b.service.readObject(thumb.Key)
+		if err != nil {
+			manifest.Missing = append(manifest.Missing, thumb.Name)
+			continue
+		}
+		if err := add(path.Join("thumbnails", thumb.Name), data, BundleFile{Kind: "thumbnail"}); err != nil {
+			return err
+		}
+	}
+
+	if m, _, err := b.service.LoadMesh(ctx, b.job); err == nil && m != nil {
+		manifest.MeshReport = mesh.Analyze(m)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	fw, err := zw.Create(path.Join(prefix, "manifest.json"))
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(manifestData)
+	return err
+}
+
+// addArchive 将ZIP包中的文件逐个加入打包
+func (b *Bundle) addArchive(dir string, data []byte, entry BundleFile, add func(string, []byte, BundleFile) error) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxModelSize))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+
+		fileEntry := entry
+		fileEntry.Kind = assetKind(f.Name)
+		fileEntry.Type = strings.TrimPrefix(strings.ToLower(path.Ext(f.Name)), ".")
+		if err := add(path.Join(dir, f.Name), content, fileEntry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMaterials 获取OBJ引用的MTL文件以及MTL引用的贴图
+func (b *Bundle) addMaterials(ctx context.Context, dir, baseURL string, objData []byte, manifest *BundleManifest, add func(string, []byte, BundleFile) error) {
+	for _, lib := range scanReferences(objData, "mtllib") {
+		mtlURL, ok := resolveReference(baseURL, lib)
+		if !ok {
+			continue
+		}
+		mtlData, err := b.service.download(ctx, mtlURL)
+		if err != nil {
+			manifest.Missing = append(manifest.Missing, mtlURL)
+			continue
+		}
+		if err := add(path.Join(dir, lib), mtlData, BundleFile{Kind: "material", Type: "mtl", SourceURL: mtlURL}); err != nil {
+			return
+		}
+
+		for _, texture := range scanReferences(mtlData, "map_Kd", "map_Ka", "map_Ks", "map_Bump", "map_bump", "bump", "map_d", "norm", "map_Pr", "map_Pm") {
+			texURL, ok := resolveReference(mtlURL, texture)
+			if !ok {
+				continue
+			}
+			texData, err := b.service.download(ctx, texURL)
+			if err != nil {
+				manifest.Missing = append(manifest.Missing, texURL)
+				continue
+			}
+			if err := add(path.Join(dir, path.Dir(lib), texture), texData, BundleFile{Kind: "texture", SourceURL: texURL}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// fetchResultFile 优先读取本地镜像，否则下载远程文件
+func (s *ModelService) fetchResultFile(ctx context.Context, file models.File3D) ([]byte, error) {
+	if file.Key != "" {
+		if data, err := s.readObject(file.Key); err == nil {
+			return data, nil
+		}
+	}
+	if file.URL == "" || strings.HasPrefix(file.URL, "/") {
+		return nil, fmt.Errorf("file %s is not available", file.URL)
+	}
+	return s.download(ctx, file.URL)
+}
+
+// scanReferences 从OBJ/MTL中提取指定关键字引用的文件名（取行尾的文件名，忽略选项）
+func scanReferences(data []byte, keywords ...string) []string {
+	var refs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, keyword := range keywords {
+			if fields[0] == keyword {
+				refs = append(refs, fields[len(fields)-1])
+				break
+			}
+		}
+	}
+	return refs
+}
+
+// resolveReference 解析相对于基础URL的引用，只允许同目录或子目录下的文件
+func resolveReference(base, ref string) (string, bool) {
+	if ref == "" || strings.Contains(ref, "..") || strings.Contains(ref, "://") || strings.HasPrefix(ref, "/") {
+		return "", false
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil || baseURL.Scheme == "" {
+		return "", false
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", false
+	}
+	return baseURL.ResolveReference(refURL).String(), true
+}
+
+// sanitizeZipPath 规范化ZIP内路径，防止出现绝对路径或上级目录
+func sanitizeZipPath(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	cleaned := path.Clean("/" + name)
+	return strings.TrimPrefix(cleaned, "/")
+}
+
+func assetKind(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mtl":
+		return "material"
+	case ".png", ".jpg", ".jpeg", ".webp", ".tga", ".bmp":
+		return "texture"
+	default:
+		return "model"
+	}
+}
+
+func urlExt(rawURL, fallback string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fallback
+	}
+	if ext := path.Ext(u.Path); ext != "" && len(ext) <= 5 {
+		return ext
+	}
+	return fallback
+}
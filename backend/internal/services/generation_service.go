@@ -5,35 +5,91 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
 	"strings"
 	"time"
 
 	"3d-model-generator-backend/internal/cache"
+	"3d-model-generator-backend/internal/fetch"
+	"3d-model-generator-backend/internal/imaging"
 	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/storage"
+	"3d-model-generator-backend/internal/urls"
 	"3d-model-generator-backend/pkg/tencentcloud"
 
 	"gorm.io/gorm"
 )
 
+// ErrImageFetch 无法获取输入图片（地址不合法、被拒绝访问或内容不是图片）
+var ErrImageFetch = errors.New("failed to fetch image")
+
+// 图片输入的提交方式
+const (
+	ImageSubmitAuto   = "auto"   // 地址可从公网访问且图片无需预处理时提交地址，否则提交base64
+	ImageSubmitURL    = "url"    // 始终提交地址，由腾讯云下载，本地不获取也不预处理图片
+	ImageSubmitBase64 = "base64" // 始终获取图片，预处理后提交base64
+)
+
 type GenerationService struct {
 	db            *gorm.DB
 	cache         *cache.CacheService
 	tencentClient *tencentcloud.Client
+	modelService  *ModelService
+	storage       *storage.LocalStorage
+	fetcher       *fetch.Fetcher
+	urls          *urls.Builder
+	moderation    *ModerationService
+	prompts       *PromptService
+	maxImageSize  int64  // 远程图片的大小上限
+	imageMode     string // 图片提交方式
 }
 
-func NewGenerationService(db *gorm.DB, cache *cache.CacheService, tencentClient *tencentcloud.Client) *GenerationService {
+func NewGenerationService(db *gorm.DB, cache *cache.CacheService, tencentClient *tencentcloud.Client, modelService *ModelService, store *storage.LocalStorage, fetcher *fetch.Fetcher, urlBuilder *urls.Builder, moderation *ModerationService, prompts *PromptService, maxImageSize int64, imageMode string) *GenerationService {
+	switch imageMode {
+	case ImageSubmitAuto, ImageSubmitURL, ImageSubmitBase64:
+	default:
+		log.Printf("Unknown image submit mode %q, using %q", imageMode, ImageSubmitAuto)
+		imageMode = ImageSubmitAuto
+	}
+
 	return &GenerationService{
 		db:            db,
 		cache:         cache,
 		tencentClient: tencentClient,
+		modelService:  modelService,
+		storage:       store,
+		fetcher:       fetcher,
+		urls:          urlBuilder,
+		moderation:    moderation,
+		prompts:       prompts,
+		maxImageSize:  maxImageSize,
+		imageMode:     imageMode,
 	}
 }
 
 // GenerateFromText 从文本生成3D模型
-func (s *GenerationService) GenerateFromText(ctx context.Context, userID, prompt string, options *GenerationOptions) (*models.GenerationResponse, error) {
+func (s *GenerationService) GenerateFromText(ctx context.Context, userID, original string, options *GenerationOptions) (*models.GenerationResponse, error) {
+	// 翻译、扩展和按档位截断
+	enhanced, err := s.prompts.Enhance(ctx, original, options)
+	if err != nil {
+		return nil, err
+	}
+	prompt := enhanced.Prompt
+
+	// 审核必须在查缓存之前，否则命中缓存的违规内容会绕过审核
+	// 翻译后的提示词也要审核，避免用英文绕过中文屏蔽词
+	if err := s.moderation.CheckPrompt(ctx, userID, original); err != nil {
+		return nil, err
+	}
+	if prompt != original {
+		if err := s.moderation.CheckPrompt(ctx, userID, prompt); err != nil {
+			return nil, err
+		}
+	}
+
 	// 检查缓存
 	cacheKey := s.cache.GeneratePromptCacheKey(prompt)
 	var cachedJob models.GenerationJob
@@ -48,12 +104,14 @@ func (s *GenerationService) GenerateFromText(ctx context.Context, userID, prompt
 
 	// 创建新的生成任务
 	job := &models.GenerationJob{
-		UserID:    userID,
-		Prompt:    prompt,
-		InputType: "text",
-		Status:    "pending",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		UserID:         userID,
+		Prompt:         prompt,
+		OriginalPrompt: original,
+		InputType:      "text",
+		Options:        options,
+		Status:         "pending",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
 	// 保存到数据库
@@ -62,7 +120,7 @@ func (s *GenerationService) GenerateFromText(ctx context.Context, userID, prompt
 	}
 
 	// 异步提交到腾讯云
-	go s.submitToTencentCloud(ctx, job, options)
+	go s.submitToTencentCloud(ctx, job, options, nil)
 
 	// 缓存任务信息
 	s.cache.Set(ctx, cacheKey, job, 24*time.Hour)
@@ -77,11 +135,23 @@ func (s *GenerationService) GenerateFromText(ctx context.Context, userID, prompt
 
 // GenerateFromImage 从图片生成3D模型
 func (s *GenerationService) GenerateFromImage(ctx context.Context, userID, imageURL string, options *GenerationOptions) (*models.GenerationResponse, error) {
-	// 计算图片哈希
-	imageHash, err := s.calculateImageHash(imageURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to calculate image hash: %w", err)
+	// 只获取一次图片，审核、哈希和提交共用同一份数据；强制提交地址且不需要审核图片时不获取图片，按地址去重
+	var imageData []byte
+	var hash [md5.Size]byte
+	if s.imageMode == ImageSubmitURL && !s.moderation.ScreensImages() {
+		hash = md5.Sum([]byte(imageURL))
+	} else {
+		var err error
+		imageData, err = s.fetchImage(ctx, imageURL)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.moderation.CheckImage(ctx, userID, imageData); err != nil {
+			return nil, err
+		}
+		hash = md5.Sum(imageData)
 	}
+	imageHash := hex.EncodeToString(hash[:])
 
 	// 检查缓存
 	cacheKey := s.cache.GenerateImageCacheKey(imageHash)
@@ -99,6 +169,7 @@ func (s *GenerationService) GenerateFromImage(ctx context.Context, userID, image
 		UserID:    userID,
 		ImageURL:  imageURL,
 		InputType: "image",
+		Options:   options,
 		Status:    "pending",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -110,7 +181,7 @@ func (s *GenerationService) GenerateFromImage(ctx context.Context, userID, image
 	}
 
 	// 异步提交到腾讯云
-	go s.submitToTencentCloud(ctx, job, options)
+	go s.submitToTencentCloud(ctx, job, options, imageData)
 
 	// 缓存任务信息
 	s.cache.Set(ctx, cacheKey, job, 24*time.Hour)
@@ -123,8 +194,89 @@ func (s *GenerationService) GenerateFromImage(ctx context.Context, userID, image
 	}, nil
 }
 
+// GenerateFromMultiView 从多视角图片生成3D模型，imageURL为正面图，views为back、left、right视角的图片
+// 正面图与单图生成一样预处理后提交，其他视角图片始终以地址提交，由腾讯云直接下载
+func (s *GenerationService) GenerateFromMultiView(ctx context.Context, userID, imageURL string, views []models.ViewImage, options *GenerationOptions) (*models.GenerationResponse, error) {
+	imageData, err := s.fetchImage(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.moderation.CheckImage(ctx, userID, imageData); err != nil {
+		return nil, err
+	}
+	if s.moderation.ScreensImages() {
+		for _, view := range views {
+			data, err := s.fetchImage(ctx, view.ViewImageURL)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.moderation.CheckImage(ctx, userID, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 哈希包含各视角的图片，正面图相同但其他视角不同的请求不会命中同一个任务
+	hash := md5.New()
+	hash.Write(imageData)
+	for _, view := range views {
+		fmt.Fprintf(hash, "\n%s=%s", view.ViewType, view.ViewImageURL)
+	}
+	imageHash := hex.EncodeToString(hash.Sum(nil))
+
+	// 检查缓存
+	cacheKey := s.cache.GenerateImageCacheKey(imageHash)
+	var cachedJob models.GenerationJob
+	if err := s.cache.Get(ctx, cacheKey, &cachedJob); err == nil {
+		return &models.GenerationResponse{
+			JobID:   cachedJob.ID,
+			Status:  cachedJob.Status,
+			Message: "Generated from cache",
+		}, nil
+	}
+
+	// 创建新的生成任务
+	job := &models.GenerationJob{
+		UserID:     userID,
+		ImageURL:   imageURL,
+		InputType:  "multiview",
+		ViewImages: views,
+		Options:    options,
+		Status:     "pending",
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	// 保存到数据库
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create generation job: %w", err)
+	}
+
+	// 异步提交到腾讯云
+	go s.submitToTencentCloud(ctx, job, options, imageData)
+
+	// 缓存任务信息
+	s.cache.Set(ctx, cacheKey, job, 24*time.Hour)
+
+	return &models.GenerationResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		Message:       "Generation job created successfully",
+		EstimatedTime: s.getEstimatedTime("multiview"),
+	}, nil
+}
+
 // GenerateFromImageBase64 从Base64图片生成3D模型
 func (s *GenerationService) GenerateFromImageBase64(ctx context.Context, userID, imageBase64 string, options *GenerationOptions) (*models.GenerationResponse, error) {
+	// 无法解码的图片在提交时会失败，这里只审核能解码的图片
+	if s.moderation.ScreensImages() {
+		if data, err := decodeImageBase64(imageBase64); err == nil {
+			if err := s.moderation.CheckImage(ctx, userID, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// 计算图片哈希
 	hash := md5.Sum([]byte(imageBase64))
 	imageHash := hex.EncodeToString(hash[:])
@@ -145,6 +297,7 @@ func (s *GenerationService) GenerateFromImageBase64(ctx context.Context, userID,
 		UserID:      userID,
 		ImageBase64: imageBase64,
 		InputType:   "image",
+		Options:     options,
 		Status:      "pending",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
@@ -156,7 +309,7 @@ func (s *GenerationService) GenerateFromImageBase64(ctx context.Context, userID,
 	}
 
 	// 异步提交到腾讯云
-	go s.submitToTencentCloud(ctx, job, options)
+	go s.submitToTencentCloud(ctx, job, options, nil)
 
 	// 缓存任务信息
 	s.cache.Set(ctx, cacheKey, job, 24*time.Hour)
@@ -169,12 +322,19 @@ func (s *GenerationService) GenerateFromImageBase64(ctx context.Context, userID,
 	}, nil
 }
 
-// GetJobStatus 获取任务状态
-func (s *GenerationService) GetJobStatus(ctx context.Context, jobID string) (*models.JobStatusResponse, error) {
+// GetJobStatus 获取任务状态，userID不为空时只查找该用户的任务
+func (s *GenerationService) GetJobStatus(ctx context.Context, userID, jobID string) (*models.JobStatusResponse, error) {
+	query := s.db.Where("id = ?", jobID)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
 	var job models.GenerationJob
-	err := s.db.Where("id = ?", jobID).First(&job).Error
+	err := query.First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
 	if err != nil {
-		return nil, fmt.Errorf("job not found: %w", err)
+		return nil, fmt.Errorf("failed to get job: %w", err)
 	}
 
 	// 如果任务还在处理中，查询腾讯云状态
@@ -199,7 +359,9 @@ func (s *GenerationService) GetJobStatus(ctx context.Context, jobID string) (*mo
 		}
 
 		job.UpdatedAt = time.Now()
-		s.db.Save(job)
+		if s.saveJob(&job) && job.Status == "completed" {
+			s.startPostProcess(job.ID)
+		}
 	}
 
 	// 如果状态是 "DONE"，映射为 "completed"
@@ -208,7 +370,9 @@ func (s *GenerationService) GetJobStatus(ctx context.Context, jobID string) (*mo
 		now := time.Now()
 		job.CompletedAt = &now
 		job.UpdatedAt = time.Now()
-		s.db.Save(job)
+		if s.saveJob(&job) {
+			s.startPostProcess(job.ID)
+		}
 	}
 
 	// 计算进度
@@ -219,6 +383,7 @@ func (s *GenerationService) GetJobStatus(ctx context.Context, jobID string) (*mo
 		Status:      job.Status,
 		Progress:    progress,
 		ResultFiles: job.ResultFiles,
+		Preprocess:  job.Preprocess,
 		ErrorMsg:    job.ErrorMsg,
 		CreatedAt:   job.CreatedAt,
 		UpdatedAt:   job.UpdatedAt,
@@ -260,11 +425,14 @@ func (s *GenerationService) GetStatistics(ctx context.Context, userID string) (*
 
 // 私有方法
 
-func (s *GenerationService) submitToTencentCloud(ctx context.Context, job *models.GenerationJob, options *GenerationOptions) {
+// submitToTencentCloud 提交任务，imageData为已获取的图片内容，为nil时从任务的输入中读取
+func (s *GenerationService) submitToTencentCloud(ctx context.Context, job *models.GenerationJob, options *GenerationOptions, imageData []byte) {
 	// 更新状态为处理中
 	job.Status = "processing"
 	job.UpdatedAt = time.Now()
-	s.db.Save(job)
+	if !s.saveJob(job) {
+		return
+	}
 
 	// 创建带超时的上下文，使用更长的超时时间
 	submitCtx, cancel := context.WithTimeout(context.Background(), 600*time.Second)
@@ -277,37 +445,53 @@ func (s *GenerationService) submitToTencentCloud(ctx context.Context, job *model
 	switch job.InputType {
 	case "text":
 		response, err = s.tencentClient.SubmitTextTo3DJob(submitCtx, job.Prompt, s.convertOptions(options))
-	case "image":
-		// 下载图片并转换为base64
-		fmt.Printf("DEBUG: Starting image download from URL: %s\n", job.ImageURL)
-		imageBase64, err := s.downloadAndEncodeImage(job.ImageURL)
-		if err != nil {
-			fmt.Printf("DEBUG: Failed to download and encode image: %v\n", err)
+	case "image", "multiview":
+		// 获取图片并预处理为符合接口要求的格式
+		imageURL, imageBase64, prepErr := s.prepareImage(submitCtx, job, imageData)
+		if prepErr != nil {
+			log.Printf("Failed to prepare image for job %s: %v", job.ID, prepErr)
 			job.Status = "failed"
-			job.ErrorMsg = err.Error()
-			s.db.Save(job)
+			job.ErrorMsg = prepErr.Error()
+			s.saveJob(job)
 			return
 		}
-		fmt.Printf("DEBUG: Image encoded successfully, base64 length: %d\n", len(imageBase64))
-		response, err = s.tencentClient.SubmitImageTo3DJob(submitCtx, imageBase64, s.convertOptions(options))
+		submitOptions := s.convertOptions(options)
+		if len(job.ViewImages) > 0 {
+			if submitOptions == nil {
+				submitOptions = s.convertOptions(&GenerationOptions{})
+			}
+			for _, view := range job.ViewImages {
+				submitOptions.MultiViewImages = append(submitOptions.MultiViewImages, tencentcloud.ViewImage{
+					ViewType:     view.ViewType,
+					ViewImageURL: view.ViewImageURL,
+				})
+			}
+		}
+		if imageURL != "" {
+			response, err = s.tencentClient.SubmitImageURLTo3DJob(submitCtx, imageURL, submitOptions)
+		} else {
+			response, err = s.tencentClient.SubmitImageTo3DJob(submitCtx, imageBase64, submitOptions)
+		}
 	default:
 		job.Status = "failed"
 		job.ErrorMsg = "unsupported input type"
-		s.db.Save(job)
+		s.saveJob(job)
 		return
 	}
 
 	if err != nil {
 		job.Status = "failed"
 		job.ErrorMsg = err.Error()
-		s.db.Save(job)
+		s.saveJob(job)
 		return
 	}
 
 	// 更新任务信息
 	job.TencentJobID = response.JobID
 	job.Status = response.Status
-	s.db.Save(job)
+	if !s.saveJob(job) {
+		return
+	}
 
 	// 立即查询一次状态，如果是模拟环境可能会直接返回完成状态
 	if job.Status == "processing" {
@@ -329,11 +513,36 @@ func (s *GenerationService) submitToTencentCloud(ctx context.Context, job *model
 				}
 			}
 			job.UpdatedAt = time.Now()
-			s.db.Save(job)
+			if s.saveJob(job) && job.Status == "completed" {
+				s.startPostProcess(job.ID)
+			}
 		}
 	}
 }
 
+// saveJob 保存任务的最新状态，任务已被取消或删除（例如账号注销）时不写入并返回false
+// 不能使用Save：记录已删除时Save会重新插入
+func (s *GenerationService) saveJob(job *models.GenerationJob) bool {
+	result := s.db.Model(job).Where("status <> ?", "cancelled").Select("*").Updates(job)
+	if result.Error != nil {
+		log.Printf("Failed to save job %s: %v", job.ID, result.Error)
+		return false
+	}
+	return result.RowsAffected > 0
+}
+
+// startPostProcess 异步执行结果后处理（LOD等），失败不影响任务状态
+func (s *GenerationService) startPostProcess(jobID string) {
+	if s.modelService == nil {
+		return
+	}
+	go func() {
+		if err := s.modelService.PostProcess(context.Background(), jobID); err != nil {
+			log.Printf("Post-process failed for job %s: %v", jobID, err)
+		}
+	}()
+}
+
 func (s *GenerationService) queryTencentJobStatus(ctx context.Context, tencentJobID, inputType string) (*tencentcloud.JobStatus, error) {
 	// 根据输入类型选择查询API
 	var jobType tencentcloud.JobType
@@ -347,42 +556,98 @@ func (s *GenerationService) queryTencentJobStatus(ctx context.Context, tencentJo
 	return s.tencentClient.QueryJobStatus(ctx, tencentJobID, jobType)
 }
 
-func (s *GenerationService) calculateImageHash(imageURL string) (string, error) {
-	// 下载图片
-	resp, err := http.Get(imageURL)
+// prepareImage 决定图片的提交方式：返回imageURL时直接提交地址，否则返回预处理后的base64，处理记录保存到任务上
+func (s *GenerationService) prepareImage(ctx context.Context, job *models.GenerationJob, data []byte) (imageURL, imageBase64 string, err error) {
+	byURL := job.ImageBase64 == "" && job.ImageURL != ""
+	if byURL && s.imageMode == ImageSubmitURL {
+		log.Printf("Submitting image for job %s by url", job.ID)
+		return job.ImageURL, "", nil
+	}
+
+	switch {
+	case data != nil:
+	case job.ImageBase64 != "":
+		data, err = decodeImageBase64(job.ImageBase64)
+	default:
+		data, err = s.fetchImage(ctx, job.ImageURL)
+	}
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer resp.Body.Close()
 
-	// 读取图片数据
-	data, err := io.ReadAll(resp.Body)
+	// 腾讯云能直接访问该地址且原图无需任何处理时提交地址，避免base64带来的流量和大小膨胀
+	if byURL && s.imageMode == ImageSubmitAuto && fetch.IsPublicURL(ctx, job.ImageURL) {
+		result, err := imaging.Preprocess(data, imaging.PreprocessOptions{
+			MinDimension: MinImageDimension,
+			MaxDimension: MaxImageDimension,
+			MaxBytes:     MaxSubmitImageURLSize,
+		})
+		if err == nil && len(result.Operations) == 0 {
+			recordPreprocess(job, result, ImageSubmitURL)
+			return job.ImageURL, "", nil
+		}
+	}
+
+	result, err := imaging.Preprocess(data, imaging.PreprocessOptions{
+		MinDimension: MinImageDimension,
+		MaxDimension: MaxImageDimension,
+		MaxBytes:     MaxSubmitImageSize,
+	})
 	if err != nil {
-		return "", err
+		return "", "", fmt.Errorf("failed to preprocess image: %w", err)
+	}
+
+	recordPreprocess(job, result, ImageSubmitBase64)
+	if len(result.Operations) > 0 {
+		log.Printf("Preprocessed image for job %s: %s", job.ID, strings.Join(result.Operations, ", "))
 	}
 
-	// 计算MD5哈希
-	hash := md5.Sum(data)
-	return hex.EncodeToString(hash[:]), nil
+	return "", base64.StdEncoding.EncodeToString(result.Data), nil
 }
 
-func (s *GenerationService) downloadAndEncodeImage(imageURL string) (string, error) {
-	// 下载图片
-	resp, err := http.Get(imageURL)
-	if err != nil {
-		return "", err
+// recordPreprocess 将预处理结果和提交方式记录到任务上
+func recordPreprocess(job *models.GenerationJob, result *imaging.PreprocessResult, delivery string) {
+	job.Preprocess = &models.ImagePreprocess{
+		OriginalFormat: result.OriginalFormat,
+		OriginalWidth:  result.OriginalWidth,
+		OriginalHeight: result.OriginalHeight,
+		OriginalSize:   result.OriginalSize,
+		Format:         string(result.Format),
+		Width:          result.Width,
+		Height:         result.Height,
+		Size:           len(result.Data),
+		Operations:     result.Operations,
+		Delivery:       delivery,
 	}
-	defer resp.Body.Close()
+}
 
-	// 读取图片数据
-	data, err := io.ReadAll(resp.Body)
+// fetchImage 获取输入图片：指向本服务的上传图片直接读取存储，其他地址（包括路径相同但主机不同的地址）
+// 通过限制了目标地址和大小的fetcher获取
+func (s *GenerationService) fetchImage(ctx context.Context, imageURL string) ([]byte, error) {
+	if key, ok := s.storage.KeyFromURL(imageURL); ok && strings.HasPrefix(key, "images/") && s.urls.Local(imageURL) {
+		if f, err := s.storage.Open(key); err == nil {
+			defer f.Close()
+			return io.ReadAll(io.LimitReader(f, s.maxImageSize))
+		}
+	}
+
+	resp, err := s.fetcher.Fetch(ctx, imageURL, s.maxImageSize, "image/")
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("%w: %v", ErrImageFetch, err)
 	}
+	return resp.Data, nil
+}
 
-	// 转换为base64
-	base64Data := base64.StdEncoding.EncodeToString(data)
-	return base64Data, nil
+// decodeImageBase64 解码base64图片，兼容data URI前缀
+func decodeImageBase64(imageBase64 string) ([]byte, error) {
+	if i := strings.Index(imageBase64, ";base64,"); i >= 0 && strings.HasPrefix(imageBase64, "data:") {
+		imageBase64 = imageBase64[i+len(";base64,"):]
+	}
+	data, err := base64.StdEncoding.DecodeString(imageBase64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 image: %w", err)
+	}
+	return data, nil
 }
 
 func (s *GenerationService) convertOptions(options *GenerationOptions) *tencentcloud.GenerationOptions {
@@ -458,7 +723,7 @@ func (s *GenerationService) getEstimatedTime(inputType string) int {
 	switch inputType {
 	case "text":
 		return 300 // 5分钟
-	case "image":
+	case "image", "multiview":
 		return 240 // 4分钟
 	default:
 		return 300
@@ -466,9 +731,4 @@ func (s *GenerationService) getEstimatedTime(inputType string) int {
 }
 
 // 类型定义
-type GenerationOptions struct {
-	ResultFormat string `json:"result_format,omitempty"`
-	EnablePBR    bool   `json:"enable_pbr,omitempty"`
-	FaceCount    int64  `json:"face_count,omitempty"`
-	GenerateType string `json:"generate_type,omitempty"`
-}
+type GenerationOptions = models.GenerationOptions
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 访问令牌的签名算法
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 2048
+	// kid未知时最多每隔这么久从数据库重新加载一次，其他实例轮换的密钥无需等待定时任务
+	keyReloadInterval = 10 * time.Second
+)
+
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// KeyringOptions 签名密钥配置
+type KeyringOptions struct {
+	Algorithm        string        // HS256、RS256或EdDSA
+	Secret           string        // HS256的共享密钥
+	EncryptionKey    string        // 加密数据库中私钥的服务端密钥
+	RotationInterval time.Duration // 每个密钥用于签名的时长
+	PublishAhead     time.Duration // 新密钥在开始签名之前提前公开的时长，应不小于验证方缓存JWKS的时长
+	TokenLifetime    time.Duration // 访问令牌有效期，被替换的密钥在这之后不再用于验证
+}
+
+// Keyring 访问令牌的签名和验证密钥
+// HS256使用配置的共享密钥，与之前签发的令牌兼容，不公开任何密钥；
+// RS256和EdDSA的密钥保存在数据库中，多个实例共享，按RotationInterval定时轮换，
+// 令牌头部的kid指明签名密钥，轮换期间新旧密钥同时可用于验证
+type Keyring struct {
+	db      *gorm.DB
+	options KeyringOptions
+	aead    cipher.AEAD
+	secret  []byte
+
+	mu         sync.RWMutex
+	keys       []*signingKey // 按ActivatesAt升序
+	lastReload time.Time
+}
+
+type signingKey struct {
+	id          string
+	algorithm   string
+	method      jwt.SigningMethod
+	private     crypto.Signer
+	public      crypto.PublicKey
+	activatesAt time.Time
+	expiresAt   *time.Time
+}
+
+func NewKeyring(db *gorm.DB, options KeyringOptions) (*Keyring, error) {
+	if options.RotationInterval <= 0 {
+		options.RotationInterval = 30 * 24 * time.Hour
+	}
+	if options.PublishAhead < 0 || options.PublishAhead >= options.RotationInterval {
+		return nil, errors.New("jwt key publish-ahead must be shorter than the rotation interval")
+	}
+	if options.TokenLifetime <= 0 {
+		options.TokenLifetime = 15 * time.Minute
+	}
+
+	k := &Keyring{db: db, options: options}
+	switch options.Algorithm {
+	case AlgorithmHS256:
+		if options.Secret == "" {
+			return nil, errors.New("jwt secret is required for HS256")
+		}
+		k.secret = []byte(options.Secret)
+		return k, nil
+	case AlgorithmRS256, AlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", options.Algorithm)
+	}
+
+	if options.EncryptionKey == "" {
+		return nil, errors.New("jwt key encryption key is required")
+	}
+	aead, err := newAEAD("jwt-signing-key", options.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	k.aead = aead
+
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+	if err := k.rotateIfDue(time.Now()); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Algorithm 签名算法
+func (k *Keyring) Algorithm() string {
+	return k.options.Algorithm
+}
+
+// Sign 使用当前密钥签名，非对称算法在头部写入kid
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	if k.secret != nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	key := k.current(time.Now())
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// Keyfunc 供jwt.Parse使用，按kid选择验证密钥，令牌的算法必须与密钥一致
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if k.secret != nil {
+		if token.Method.Alg() != AlgorithmHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return k.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownSigningKey
+	}
+	key := k.lookup(kid)
+	if key == nil {
+		// 可能是其他实例刚轮换的密钥
+		if k.reloadThrottled() {
+			key = k.lookup(kid)
+		}
+		if key == nil {
+			return nil, ErrUnknownSigningKey
+		}
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.public, nil
+}
+
+// JWKS 当前公开的验证密钥，包括即将启用和尚未过期的旧密钥；HS256时为空
+func (k *Keyring) JWKS() models.JSONWebKeySet {
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	now := time.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.expired(now) {
+			continue
+		}
+		jwk := models.JSONWebKey{Use: "sig", Alg: key.algorithm, Kid: key.id}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// Start 定时重新加载其他实例轮换的密钥，到期时生成下一个密钥并删除过期的密钥
+func (k *Keyring) Start(ctx context.Context, interval time.Duration) {
+	if k.secret != nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := k.reload(); err != nil {
+					log.Printf("Failed to reload signing keys: %v", err)
+					continue
+				}
+				if err := k.rotateIfDue(time.Now()); err != nil {
+					log.Printf("Signing key rotation failed: %v", err)
+				}
+				if err := k.prune(); err != nil {
+					log.Printf("Signing key cleanup failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// rotateIfDue 当前算法没有可用密钥时立即生成并启用；最新的密钥签名满RotationInterval前PublishAhead时生成下一个，
+// 到期后启用；被替换的密钥在新密钥启用后TokenLifetime过期
+func (k *Keyring) rotateIfDue(now time.Time) error {
+	err := k.db.Transaction(func(tx *gorm.DB) error {
+		var latest models.SigningKey
+		err := tx.Where("algorithm = ? AND (expires_at IS NULL OR expires_at > ?)", k.options.Algorithm, now).
+			Order("activates_at DESC").First(&latest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to find signing key: %w", err)
+		}
+
+		// 加密密钥更换后旧私钥无法解密，同样需要立即生成新密钥
+		activatesAt := now
+		if err == nil && k.usable(latest.ID) {
+			next := latest.ActivatesAt.Add(k.options.RotationInterval)
+			if now.Before(next.Add(-k.options.PublishAhead)) {
+				return nil
+			}
+			activatesAt = maxTime(now, next)
+		}
+
+		record, err := k.generate(activatesAt)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("failed to save signing key: %w", err)
+		}
+		// 包括切换算法前的旧密钥
+		if err := tx.Model(&models.SigningKey{}).
+			Where("id <> ? AND expires_at IS NULL", record.ID).
+			Update("expires_at", activatesAt.Add(k.options.TokenLifetime)).Error; err != nil {
+			return fmt.Errorf("failed to retire signing keys: %w", err)
+		}
+		log.Printf("Generated %s signing key %s, active from %s", record.Algorithm, record.ID, activatesAt.Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return k.reload()
+}
+
+// generate 生成新的密钥对，私钥加密后保存
+func (k *Keyring) generate(activatesAt time.Time) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch k.options.Algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	sealed, err := sealBytes(k.aead, privateDER)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+	return &models.SigningKey{
+		ID:          hex.EncodeToString(id),
+		Algorithm:   k.options.Algorithm,
+		PrivateKey:  sealed,
+		PublicKey:   base64.StdEncoding.EncodeToString(publicDER),
+		ActivatesAt: activatesAt,
+	}, nil
+}
+
+// reload 从数据库加载未过期的密钥；无法解密的私钥（加密密钥已更换）只用于验证
+func (k *Keyring) reload() error {
+	var records []models.SigningKey
+	if err := k.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("activates_at ASC").Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := make([]*signingKey, 0, len(records))
+	for _, record := range records {
+		key, err := k.decode(&record)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.ID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.lastReload = time.Now()
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *Keyring) reloadThrottled() bool {
+	k.mu.RLock()
+	recent := time.Since(k.lastReload) < keyReloadInterval
+	k.mu.RUnlock()
+	if recent {
+		return false
+	}
+	if err := k.reload(); err != nil {
+		log.Printf("Failed to reload signing keys: %v", err)
+		return false
+	}
+	return true
+}
+
+func (k *Keyring) decode(record *models.SigningKey) (*signingKey, error) {
+	var method jwt.SigningMethod
+	switch record.Algorithm {
+	case AlgorithmRS256:
+		method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", record.Algorithm)
+	}
+
+	publicDER, err := base64.StdEncoding.DecodeString(record.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	public, err := x509.ParsePKIXPublicKey(publicDER)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	key := &signingKey{
+		id:          record.ID,
+		algorithm:   record.Algorithm,
+		method:      method,
+		public:      public,
+		activatesAt: record.ActivatesAt,
+		expiresAt:   record.ExpiresAt,
+	}
+
+	privateDER, err := openBytes(k.aead, record.PrivateKey)
+	if err != nil {
+		log.Printf("Signing key %s cannot be decrypted, using it for verification only", record.ID)
+		return key, nil
+	}
+	private, err := x509.ParsePKCS8PrivateKey(privateDER)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	key.private = signer
+	return key, nil
+}
+
+// current 已启用的最新密钥，必须是当前配置的算法且私钥可用
+func (k *Keyring) current(now time.Time) *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		key := k.keys[i]
+		if key.algorithm == k.options.Algorithm && key.private != nil && !key.activatesAt.After(now) && !key.expired(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+func (k *Keyring) usable(kid string) bool {
+	key := k.lookup(kid)
+	return key != nil && key.private != nil
+}
+
+func (k *Keyring) lookup(kid string) *signingKey {
+	now := time.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.id == kid && !key.expired(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// prune 删除已过期的密钥
+func (k *Keyring) prune() error {
+	result := k.db.Where("expires_at IS NOT NULL AND expires_at < ?", time.Now()).Delete(&models.SigningKey{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete expired signing keys: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Deleted %d expired signing keys", result.RowsAffected)
+	}
+	return nil
+}
+
+func (key *signingKey) expired(now time.Time) bool {
+	return key.expiresAt != nil && !now.Before(*key.expiresAt)
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+)
+
+func newTestKeyring(t *testing.T, options KeyringOptions) *Keyring {
+	t.Helper()
+	db := newTestDB(t, &models.SigningKey{})
+	k, err := NewKeyring(db, options)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func signingKeys(t *testing.T, k *Keyring) []models.SigningKey {
+	t.Helper()
+	var keys []models.SigningKey
+	if err := k.db.Order("activates_at").Find(&keys).Error; err != nil {
+		t.Fatalf("list signing keys: %v", err)
+	}
+	return keys
+}
+
+func TestKeyringRotateIfDue(t *testing.T) {
+	options := KeyringOptions{
+		Algorithm:        AlgorithmEdDSA,
+		EncryptionKey:    "test-encryption-key",
+		RotationInterval: 24 * time.Hour,
+		PublishAhead:     time.Hour,
+		TokenLifetime:    15 * time.Minute,
+	}
+	k := newTestKeyring(t, options)
+
+	keys := signingKeys(t, k)
+	if len(keys) != 1 {
+		t.Fatalf("NewKeyring created %d keys, want 1", len(keys))
+	}
+	first := keys[0]
+	if first.ExpiresAt != nil {
+		t.Fatalf("first key expires at %v, want no expiry", first.ExpiresAt)
+	}
+	next := first.ActivatesAt.Add(options.RotationInterval)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantKeys int
+	}{
+		{"not yet due", first.ActivatesAt.Add(time.Hour), 1},
+		{"just before publish ahead", next.Add(-options.PublishAhead - time.Second), 1},
+		{"within publish ahead", next.Add(-options.PublishAhead + time.Second), 2},
+		{"next key already published", next.Add(-time.Minute), 2},
+	}
+	for _, tt := range tests {
+		if err := k.rotateIfDue(tt.now); err != nil {
+			t.Fatalf("%s: rotateIfDue: %v", tt.name, err)
+		}
+		if got := len(signingKeys(t, k)); got != tt.wantKeys {
+			t.Fatalf("%s: %d keys, want %d", tt.name, got, tt.wantKeys)
+		}
+	}
+
+	keys = signingKeys(t, k)
+	second := keys[1]
+	if !second.ActivatesAt.Equal(next) {
+		t.Errorf("next key activates at %v, want %v", second.ActivatesAt, next)
+	}
+	if second.ExpiresAt != nil {
+		t.Errorf("next key expires at %v, want no expiry", second.ExpiresAt)
+	}
+	wantExpiry := next.Add(options.TokenLifetime)
+	if keys[0].ExpiresAt == nil || !keys[0].ExpiresAt.Equal(wantExpiry) {
+		t.Errorf("replaced key expires at %v, want %v", keys[0].ExpiresAt, wantExpiry)
+	}
+
+	// 新密钥公开后、启用前仍使用旧密钥签名
+	if key := k.current(next.Add(-time.Minute)); key == nil || key.id != first.ID {
+		t.Errorf("current before activation = %v, want %s", key, first.ID)
+	}
+	if key := k.current(next.Add(time.Minute)); key == nil || key.id != second.ID {
+		t.Errorf("current after activation = %v, want %s", key, second.ID)
+	}
+}
+
+func TestKeyringRotateIfDueOverdue(t *testing.T) {
+	options := KeyringOptions{
+		Algorithm:        AlgorithmEdDSA,
+		EncryptionKey:    "test-encryption-key",
+		RotationInterval: 24 * time.Hour,
+		TokenLifetime:    15 * time.Minute,
+	}
+	k := newTestKeyring(t, options)
+	first := signingKeys(t, k)[0]
+
+	// 长时间没有运行轮换时，新密钥立即启用，而不是启用于过去的时间
+	now := first.ActivatesAt.Add(3 * options.RotationInterval)
+	if err := k.rotateIfDue(now); err != nil {
+		t.Fatalf("rotateIfDue: %v", err)
+	}
+	keys := signingKeys(t, k)
+	if len(keys) != 2 {
+		t.Fatalf("%d keys, want 2", len(keys))
+	}
+	if !keys[1].ActivatesAt.Equal(now) {
+		t.Errorf("new key activates at %v, want %v", keys[1].ActivatesAt, now)
+	}
+}
+
+func TestKeyringRotateIfDueUnusableKey(t *testing.T) {
+	options := KeyringOptions{
+		Algorithm:        AlgorithmEdDSA,
+		EncryptionKey:    "old-encryption-key",
+		RotationInterval: 24 * time.Hour,
+		PublishAhead:     time.Hour,
+		TokenLifetime:    15 * time.Minute,
+	}
+	k := newTestKeyring(t, options)
+	first := signingKeys(t, k)[0]
+
+	// 更换加密密钥后旧私钥无法解密，即使未到轮换时间也立即生成可用的密钥
+	options.EncryptionKey = "new-encryption-key"
+	k2, err := NewKeyring(k.db, options)
+	if err != nil {
+		t.Fatalf("NewKeyring with new encryption key: %v", err)
+	}
+	keys := signingKeys(t, k2)
+	if len(keys) != 2 {
+		t.Fatalf("%d keys, want 2", len(keys))
+	}
+	if keys[1].ID == first.ID || keys[0].ExpiresAt == nil {
+		t.Errorf("old key was not replaced: %+v", keys)
+	}
+	if key := k2.current(time.Now()); key == nil || key.id != keys[1].ID {
+		t.Errorf("current = %v, want %s", key, keys[1].ID)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import "sync"
+
+// keyedMutex 按key加锁的互斥锁集合，零值可用
+// 每个key的锁在没有持有者和等待者时从map中移除，key数量不会随任务、会话或邮箱的数量无限增长
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int // 持有和等待该锁的数量，由keyedMutex.mu保护
+}
+
+// lock 获取key对应的锁，返回解锁函数
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l := m.locks[key]
+	if l == nil {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
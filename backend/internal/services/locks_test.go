@@ -0,0 +1,29 @@
+package services
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestKeyedMutexRemovesReleasedKeys(t *testing.T) {
+	var m keyedMutex
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := m.lock("job")
+			counter++
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if counter != 50 {
+		t.Fatalf("counter = %d, want 50", counter)
+	}
+	if len(m.locks) != 0 {
+		t.Fatalf("%d locks left after release, want 0", len(m.locks))
+	}
+}
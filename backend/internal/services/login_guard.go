@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrLoginLocked 登录失败次数过多，暂时锁定
+var ErrLoginLocked = errors.New("登录失败次数过多，请稍后再试")
+
+// LoginLockedError 携带锁定的剩余时间，errors.Is(err, ErrLoginLocked)成立
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return ErrLoginLocked.Error()
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrLoginLocked
+}
+
+// LoginPolicy 登录失败的限制策略
+type LoginPolicy struct {
+	MaxAttempts     int           // 同一邮箱在Window内允许的失败次数，达到后锁定
+	IPMaxAttempts   int           // 同一IP在Window内允许的失败次数（不区分邮箱），达到后锁定
+	Window          time.Duration // 失败次数的统计窗口
+	LockoutDuration time.Duration // 锁定时长，从最后一次失败开始计算
+	DelayBase       time.Duration // 第二次失败开始的响应延迟，此后每次翻倍
+	MaxDelay        time.Duration // 响应延迟上限
+	EventRetention  time.Duration // 审计事件保留时长
+}
+
+// loginGuard 基于认证审计事件统计登录失败次数，不依赖Redis，多实例共享同一个数据库即可
+// 邮箱的失败次数在登录成功或管理员解锁后重新计算；IP的失败次数不会因为登录成功而清零，
+// 避免攻击者用自己的账号登录来重置计数
+// 同一实例内同一邮箱的登录串行执行；失败后的延迟同时作为下一次尝试的最早时间，并发请求和其他实例也不能绕过
+type loginGuard struct {
+	db     *gorm.DB
+	policy LoginPolicy
+	emails keyedMutex
+}
+
+func newLoginGuard(db *gorm.DB, policy LoginPolicy) *loginGuard {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 5
+	}
+	if policy.IPMaxAttempts <= 0 {
+		policy.IPMaxAttempts = 20
+	}
+	if policy.Window <= 0 {
+		policy.Window = 15 * time.Minute
+	}
+	if policy.LockoutDuration <= 0 {
+		policy.LockoutDuration = 15 * time.Minute
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 4 * time.Second
+	}
+	if policy.EventRetention <= 0 {
+		policy.EventRetention = 90 * 24 * time.Hour
+	}
+	return &loginGuard{db: db, policy: policy}
+}
+
+// failures 失败次数的统计结果
+type failures struct {
+	count int64
+	last  time.Time
+}
+
+// check 获取该邮箱的登录锁，调用方完成验证并调用fail或succeed后执行返回的release
+// 邮箱或IP处于锁定期、或距上一次失败不足对应的延迟时返回*LoginLockedError并记录login_blocked事件，此时不持有锁
+func (g *loginGuard) check(email string, client ClientInfo) (release func(), err error) {
+	release = g.emails.lock(email)
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+	return release, g.allow(email, client)
+}
+
+func (g *loginGuard) allow(email string, client ClientInfo) error {
+	now := time.Now()
+	var retryAfter time.Duration
+
+	byEmail, err := g.emailFailures(email, now)
+	if err != nil {
+		return err
+	}
+	if byEmail.count >= int64(g.policy.MaxAttempts) {
+		retryAfter = byEmail.last.Add(g.policy.LockoutDuration).Sub(now)
+	} else if byEmail.count > 0 {
+		retryAfter = byEmail.last.Add(g.delay(byEmail.count)).Sub(now)
+	}
+
+	if client.IP != "" {
+		byIP, err := g.countFailures(g.db.Where("ip = ?", client.IP), now.Add(-g.policy.Window))
+		if err != nil {
+			return err
+		}
+		if byIP.count >= int64(g.policy.IPMaxAttempts) {
+			retryAfter = max(retryAfter, byIP.last.Add(g.policy.LockoutDuration).Sub(now))
+		}
+	}
+
+	if retryAfter <= 0 {
+		return nil
+	}
+	g.record(models.AuthEventLoginBlocked, "", email, client, "")
+	return &LoginLockedError{RetryAfter: retryAfter.Round(time.Second) + time.Second}
+}
+
+// fail 记录一次失败，按该邮箱的失败次数延迟响应，达到上限时记录account_locked事件
+// 延迟期间仍持有该邮箱的登录锁，同一实例的后续尝试在延迟结束后才会执行
+func (g *loginGuard) fail(userID, email string, client ClientInfo, reason string) {
+	g.record(models.AuthEventLoginFailed, userID, email, client, reason)
+
+	byEmail, err := g.emailFailures(email, time.Now())
+	if err != nil {
+		log.Printf("Failed to count login failures for %s: %v", email, err)
+		return
+	}
+	if byEmail.count == int64(g.policy.MaxAttempts) {
+		log.Printf("Login locked for %s from %s after %d failed attempts", email, client.IP, byEmail.count)
+		g.record(models.AuthEventAccountLocked, userID, email, client, fmt.Sprintf("%d failed attempts", byEmail.count))
+	}
+	time.Sleep(g.delay(byEmail.count))
+}
+
+// succeed 记录登录成功，之后该邮箱的失败次数重新计算，method为空表示密码登录
+func (g *loginGuard) succeed(user *models.User, client ClientInfo, method string) {
+	g.record(models.AuthEventLoginSucceeded, user.ID, normalizeEmail(user.Email), client, method)
+}
+
+// delay 第一次失败不延迟，之后从DelayBase开始每次翻倍，不超过MaxDelay
+func (g *loginGuard) delay(count int64) time.Duration {
+	if count < 2 || g.policy.DelayBase <= 0 {
+		return 0
+	}
+	d := g.policy.DelayBase
+	for i := int64(2); i < count && d < g.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, g.policy.MaxDelay)
+}
+
+// emailFailures 统计窗口内、最近一次登录成功或解锁之后该邮箱的失败次数
+func (g *loginGuard) emailFailures(email string, now time.Time) (failures, error) {
+	since := now.Add(-g.policy.Window)
+	var reset models.AuthEvent
+	err := g.db.Where("email = ? AND type IN ? AND created_at > ?", email,
+		[]string{models.AuthEventLoginSucceeded, models.AuthEventLoginUnlocked}, since).
+		Order("created_at DESC").First(&reset).Error
+	if err == nil {
+		since = reset.CreatedAt
+	}
+	return g.countFailures(g.db.Where("email = ?", email), since)
+}
+
+func (g *loginGuard) countFailures(query *gorm.DB, since time.Time) (failures, error) {
+	var result failures
+	query = query.Model(&models.AuthEvent{}).
+		Where("type = ? AND created_at > ?", models.AuthEventLoginFailed, since).
+		Session(&gorm.Session{})
+	if err := query.Count(&result.count).Error; err != nil {
+		return result, fmt.Errorf("failed to count login failures: %w", err)
+	}
+	if result.count == 0 {
+		return result, nil
+	}
+
+	var last models.AuthEvent
+	if err := query.Order("created_at DESC").First(&last).Error; err != nil {
+		return result, fmt.Errorf("failed to find last login failure: %w", err)
+	}
+	result.last = last.CreatedAt
+	return result, nil
+}
+
+// record 写入审计事件，失败只记录日志，不影响登录流程
+func (g *loginGuard) record(eventType, userID, email string, client ClientInfo, reason string) {
+	event := &models.AuthEvent{
+		Type:      eventType,
+		UserID:    userID,
+		Email:     email,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+	}
+	if err := g.db.Create(event).Error; err != nil {
+		log.Printf("Failed to record auth event %s for %s: %v", eventType, email, err)
+	}
+}
+
+// prune 删除超过保留时长的审计事件
+func (g *loginGuard) prune() (int64, error) {
+	result := g.db.Where("created_at < ?", time.Now().Add(-g.policy.EventRetention)).Delete(&models.AuthEvent{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to prune auth events: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
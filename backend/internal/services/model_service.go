@@ -0,0 +1,534 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/fetch"
+	"3d-model-generator-backend/internal/mesh"
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// maxModelSize 下载模型文件的大小上限
+const maxModelSize = 200 * 1024 * 1024
+
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobNotCompleted   = errors.New("job not completed")
+	ErrThumbnailNotFound = errors.New("thumbnail not found")
+	ErrNoMeshResult      = errors.New("job has no OBJ result to process")
+	ErrInvalidTransform  = errors.New("invalid transform")
+)
+
+// ModelService 生成结果后处理服务：镜像原始模型并生成派生模型
+type ModelService struct {
+	db       *gorm.DB
+	storage  *storage.LocalStorage
+	fetcher  *fetch.Fetcher
+	options  ModelOptions
+	jobLocks keyedMutex // 串行化同一任务结果文件的修改
+}
+
+// ModelOptions 后处理配置
+type ModelOptions struct {
+	LODLevels        []int // LOD面数百分比
+	ThumbnailSizes   []int
+	ThumbnailAngles  []int
+	ThumbnailPitch   int
+	TurntableFrames  int
+	TurntableColumns int
+	BundleLicense    string // 写入打包manifest的许可声明
+}
+
+func NewModelService(db *gorm.DB, store *storage.LocalStorage, fetcher *fetch.Fetcher, options ModelOptions) *ModelService {
+	return &ModelService{
+		db:      db,
+		storage: store,
+		fetcher: fetcher,
+		options: options,
+	}
+}
+
+// PostProcess 对已完成的任务执行后处理，生成各级LOD模型和缩略图
+func (s *ModelService) PostProcess(ctx context.Context, jobID string) error {
+	unlock := s.lockJob(jobID)
+	defer unlock()
+
+	job, err := s.getCompletedJob(jobID, "")
+	if err != nil {
+		return err
+	}
+
+	m, original, err := s.LoadMesh(ctx, job)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		// 没有可处理的OBJ结果
+		return s.saveResultFiles(job)
+	}
+
+	for _, level := range s.options.LODLevels {
+		if level <= 0 || level >= 100 || findResultFile(job.ResultFiles, "obj", level) >= 0 {
+			continue
+		}
+
+		// 原始结果的MTL和贴图不会复制到derived/下，LOD只保留几何信息，避免引用不存在的材质文件
+		lod := mesh.Decimate(m, float64(level)/100)
+		lod.StripMaterials()
+		key := fmt.Sprintf("derived/%s/lod_%d.obj", job.ID, level)
+		size, err := s.putMesh(key, lod)
+		if err != nil {
+			return fmt.Errorf("failed to store LOD %d: %w", level, err)
+		}
+
+		job.ResultFiles = append(job.ResultFiles, models.File3D{
+			Type:            "obj",
+			URL:             s.storage.URL(key),
+			PreviewImageURL: original.PreviewImageURL,
+			LOD:             level,
+			Key:             key,
+			Size:            size,
+		})
+	}
+
+	if err := s.renderThumbnails(job, m); err != nil {
+		return err
+	}
+
+	return s.saveResultFiles(job)
+}
+
+// Transform 对用户任务的模型执行几何变换，结果保存为新的版本
+func (s *ModelService) Transform(ctx context.Context, userID, jobID string, req *models.TransformRequest) (*models.TransformResponse, error) {
+	unlock := s.lockJob(jobID)
+	defer unlock()
+
+	job, err := s.getCompletedJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *mesh.Mesh
+	var source models.File3D
+	if req.SourceLOD == 0 {
+		m, source, err = s.LoadMesh(ctx, job)
+	} else {
+		idx := findResultFile(job.ResultFiles, "obj", req.SourceLOD)
+		if idx < 0 {
+			return nil, fmt.Errorf("LOD %d not found: %w", req.SourceLOD, ErrNoMeshResult)
+		}
+		source = job.ResultFiles[idx]
+		m, err = s.readMesh(source.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNoMeshResult
+	}
+
+	if err := applyTransform(m, req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTransform, err)
+	}
+
+	version := 1
+	for _, file := range job.ResultFiles {
+		if file.Version >= version {
+			version = file.Version + 1
+		}
+	}
+
+	// 与LOD一样只输出几何信息，derived/下没有原始结果的MTL和贴图
+	m.StripMaterials()
+	key := fmt.Sprintf("derived/%s/transform_v%d.obj", job.ID, version)
+	size, err := s.putMesh(key, m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store transformed model: %w", err)
+	}
+
+	file := models.File3D{
+		Type:            "obj",
+		URL:             s.storage.URL(key),
+		PreviewImageURL: source.PreviewImageURL,
+		Key:             key,
+		Size:            size,
+		Version:         version,
+		Transform:       req,
+	}
+	job.ResultFiles = append(job.ResultFiles, file)
+	if err := s.saveResultFiles(job); err != nil {
+		return nil, fmt.Errorf("failed to save job: %w", err)
+	}
+
+	dims := m.Size()
+	return &models.TransformResponse{
+		JobID:      job.ID,
+		File:       file,
+		Dimensions: [3]float64{dims.X, dims.Y, dims.Z},
+		Message:    "Model transformed successfully",
+	}, nil
+}
+
+// applyTransform 依次执行坐标轴转换、镜像、缩放、居中和落地
+func applyTransform(m *mesh.Mesh, req *models.TransformRequest) error {
+	sourceUp, err := parseUpAxis(req.SourceUpAxis)
+	if err != nil {
+		return err
+	}
+	up, err := parseUpAxis(req.UpAxis)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case sourceUp == mesh.AxisY && up == mesh.AxisZ:
+		m.YUpToZUp()
+	case sourceUp == mesh.AxisZ && up == mesh.AxisY:
+		m.ZUpToYUp()
+	}
+
+	if req.Mirror != "" {
+		axis, err := mesh.ParseAxis(req.Mirror)
+		if err != nil {
+			return fmt.Errorf("invalid mirror: %w", err)
+		}
+		m.Mirror(axis)
+	}
+
+	if req.TargetHeightMM < 0 {
+		return fmt.Errorf("target_height_mm must be positive")
+	}
+	if req.TargetHeightMM > 0 {
+		if err := m.ScaleToHeight(req.TargetHeightMM, up); err != nil {
+			return err
+		}
+	}
+
+	if req.Center {
+		m.Center()
+	}
+	if req.DropToGround {
+		m.DropToGround(up)
+	}
+	return nil
+}
+
+func parseUpAxis(s string) (mesh.Axis, error) {
+	if s == "" {
+		return mesh.AxisY, nil
+	}
+	axis, err := mesh.ParseAxis(s)
+	if err != nil || axis == mesh.AxisX {
+		return 0, fmt.Errorf("invalid up axis %q: must be y or z", s)
+	}
+	return axis, nil
+}
+
+// GetThumbnails 获取用户任务的缩略图列表
+func (s *ModelService) GetThumbnails(ctx context.Context, userID, jobID string) ([]models.Thumbnail, error) {
+	job, err := s.getCompletedJob(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return job.Thumbnails, nil
+}
+
+// ThumbnailPath 获取缩略图在本地磁盘上的路径
+func (s *ModelService) ThumbnailPath(ctx context.Context, userID, jobID, name string) (string, error) {
+	thumbnails, err := s.GetThumbnails(ctx, userID, jobID)
+	if err != nil {
+		return "", err
+	}
+	for _, thumb := range thumbnails {
+		if thumb.Name == name {
+			return s.storage.Path(thumb.Key)
+		}
+	}
+	return "", ErrThumbnailNotFound
+}
+
+// renderThumbnails 按配置的尺寸和角度渲染缩略图，以及可选的转台精灵图
+func (s *ModelService) renderThumbnails(job *models.GenerationJob, m *mesh.Mesh) error {
+	existing := make(map[string]bool, len(job.Thumbnails))
+	for _, thumb := range job.Thumbnails {
+		existing[thumb.Name] = true
+	}
+
+	for _, size := range s.options.ThumbnailSizes {
+		if size <= 0 {
+			continue
+		}
+		for _, angle := range s.options.ThumbnailAngles {
+			name := fmt.Sprintf("thumb_%d_%d.png", size, angle)
+			if existing[name] {
+				continue
+			}
+
+			opts := mesh.DefaultRenderOptions(size)
+			opts.Yaw = float64(angle)
+			opts.Pitch = float64(s.options.ThumbnailPitch)
+			thumb := models.Thumbnail{Name: name, Width: size, Height: size, Yaw: opts.Yaw, Pitch: opts.Pitch}
+			if err := s.putPNG(job.ID, &thumb, mesh.Render(m, opts)); err != nil {
+				return err
+			}
+			job.Thumbnails = append(job.Thumbnails, thumb)
+		}
+	}
+
+	if s.options.TurntableFrames > 0 && len(s.options.ThumbnailSizes) > 0 {
+		size := s.options.ThumbnailSizes[0]
+		for _, candidate := range s.options.ThumbnailSizes {
+			if candidate > 0 && candidate < size {
+				size = candidate
+			}
+		}
+		name := fmt.Sprintf("turntable_%d.png", size)
+		if existing[name] || size <= 0 {
+			return nil
+		}
+
+		opts := mesh.DefaultRenderOptions(size)
+		opts.Yaw = 0
+		opts.Pitch = float64(s.options.ThumbnailPitch)
+		sheet := mesh.RenderSpriteSheet(m, opts, s.options.TurntableFrames, s.options.TurntableColumns)
+		thumb := models.Thumbnail{
+			Name:   name,
+			Width:  sheet.Rect.Dx(),
+			Height: sheet.Rect.Dy(),
+			Pitch:  opts.Pitch,
+			Frames: s.options.TurntableFrames,
+		}
+		if err := s.putPNG(job.ID, &thumb, sheet); err != nil {
+			return err
+		}
+		job.Thumbnails = append(job.Thumbnails, thumb)
+	}
+
+	return nil
+}
+
+func (s *ModelService) putPNG(jobID string, thumb *models.Thumbnail, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", thumb.Name, err)
+	}
+
+	key := fmt.Sprintf("thumbnails/%s/%s", jobID, thumb.Name)
+	size, err := s.storage.Put(key, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", thumb.Name, err)
+	}
+	thumb.Key = key
+	thumb.URL = s.storage.URL(key)
+	thumb.Size = size
+	return nil
+}
+
+// getCompletedJob 获取已完成的任务；userID不为空时只返回该用户的任务，其他用户的任务视为不存在
+func (s *ModelService) getCompletedJob(jobID, userID string) (*models.GenerationJob, error) {
+	query := s.db.Where("id = ?", jobID)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	var job models.GenerationJob
+	if err := query.First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job.Status != "completed" {
+		return nil, ErrJobNotCompleted
+	}
+	return &job, nil
+}
+
+// LoadMesh 加载任务的原始OBJ模型，首次加载时镜像到本地存储
+// 任务没有OBJ结果时返回nil
+func (s *ModelService) LoadMesh(ctx context.Context, job *models.GenerationJob) (*mesh.Mesh, models.File3D, error) {
+	idx := findResultFile(job.ResultFiles, "obj", 0)
+	if idx < 0 {
+		return nil, models.File3D{}, nil
+	}
+
+	if job.ResultFiles[idx].Key == "" {
+		if err := s.mirror(ctx, job.ID, &job.ResultFiles[idx]); err != nil {
+			return nil, models.File3D{}, err
+		}
+	}
+	original := job.ResultFiles[idx]
+
+	m, err := s.readMesh(original.Key)
+	return m, original, err
+}
+
+// readMesh 从本地存储读取OBJ（或包含OBJ的ZIP）并解析
+func (s *ModelService) readMesh(key string) (*mesh.Mesh, error) {
+	data, err := s.readObject(key)
+	if err != nil {
+		return nil, err
+	}
+
+	objData, err := extractOBJ(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := mesh.ParseOBJ(bytes.NewReader(objData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OBJ: %w", err)
+	}
+	return m, nil
+}
+
+// mirror 下载远程结果文件并保存到本地存储
+func (s *ModelService) mirror(ctx context.Context, jobID string, file *models.File3D) error {
+	data, err := s.download(ctx, file.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+
+	name := "model." + file.Type
+	if isZip(data) {
+		name = "model.zip"
+	}
+	key := fmt.Sprintf("results/%s/%s", jobID, name)
+
+	size, err := s.storage.Put(key, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to mirror model: %w", err)
+	}
+	file.Key = key
+	file.Size = size
+	return nil
+}
+
+func (s *ModelService) download(ctx context.Context, url string) ([]byte, error) {
+	resp, err := s.fetcher.Fetch(ctx, url, maxModelSize)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (s *ModelService) readObject(key string) ([]byte, error) {
+	f, err := s.storage.Open(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (s *ModelService) putMesh(key string, m *mesh.Mesh) (int64, error) {
+	var buf bytes.Buffer
+	if err := m.WriteOBJ(&buf); err != nil {
+		return 0, err
+	}
+	return s.storage.Put(key, &buf)
+}
+
+// saveResultFiles 只更新结果文件和缩略图，避免覆盖并发修改的其他字段
+func (s *ModelService) saveResultFiles(job *models.GenerationJob) error {
+	job.UpdatedAt = time.Now()
+	return s.db.Model(job).Select("result_files", "thumbnails", "updated_at").Updates(job).Error
+}
+
+// DetachFiles 从任务记录中移除已被存储清理删除的文件
+// 与后处理和格式转换一样在任务锁内读改写结果文件列表，避免覆盖并发写入的条目
+func (s *ModelService) DetachFiles(jobID string, keys map[string]bool) error {
+	unlock := s.lockJob(jobID)
+	defer unlock()
+
+	var job models.GenerationJob
+	if err := s.db.Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 任务已删除，没有需要更新的记录
+			return nil
+		}
+		return fmt.Errorf("failed to load job %s: %w", jobID, err)
+	}
+
+	files := job.ResultFiles[:0]
+	for _, file := range job.ResultFiles {
+		if keys[file.Key] {
+			// 派生文件只存在于本地，直接移除；镜像的原始文件保留远程URL
+			if file.LOD > 0 || file.Version > 0 {
+				continue
+			}
+			file.Key = ""
+			file.Size = 0
+		}
+		files = append(files, file)
+	}
+	job.ResultFiles = files
+
+	thumbnails := job.Thumbnails[:0]
+	for _, thumb := range job.Thumbnails {
+		if !keys[thumb.Key] {
+			thumbnails = append(thumbnails, thumb)
+		}
+	}
+	job.Thumbnails = thumbnails
+
+	job.UpdatedAt = time.Now()
+	if err := s.db.Model(&job).Select("result_files", "thumbnails", "updated_at").Updates(&job).Error; err != nil {
+		return fmt.Errorf("failed to update job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// lockJob 获取任务级别的互斥锁，返回解锁函数
+func (s *ModelService) lockJob(jobID string) func() {
+	return s.jobLocks.lock(jobID)
+}
+
+// findResultFile 按类型和LOD查找未经变换的结果文件，返回下标
+func findResultFile(files []models.File3D, fileType string, lod int) int {
+	for i, file := range files {
+		if file.Type == fileType && file.LOD == lod && file.Version == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// extractOBJ 腾讯云可能返回包含OBJ/MTL/贴图的ZIP包，从中取出OBJ
+func extractOBJ(data []byte) ([]byte, error) {
+	if !isZip(data) {
+		return data, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if strings.EqualFold(path.Ext(f.Name), ".obj") {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(io.LimitReader(rc, maxModelSize))
+		}
+	}
+	return nil, fmt.Errorf("no OBJ file in model archive")
+}
+
+func isZip(data []byte) bool {
+	return len(data) > 4 && bytes.Equal(data[:4], []byte("PK\x03\x04"))
+}
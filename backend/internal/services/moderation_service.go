@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"3d-model-generator-backend/internal/imaging"
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/moderation"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrContentRejected       = errors.New("content rejected by moderation")
+	ErrModerationUnavailable = errors.New("moderation service unavailable")
+	ErrUserNotFound          = errors.New("user not found")
+)
+
+// 违规记录中保存的提示词最大长度（字符）
+const maxViolationExcerpt = 200
+
+// ModerationRejection 审核未通过的原因，errors.Is(err, ErrContentRejected)为true
+type ModerationRejection struct {
+	Kind      string // prompt 或 image
+	Category  string
+	Suspended bool // 本次违规导致账号被自动封禁
+}
+
+func (r *ModerationRejection) Error() string {
+	return fmt.Sprintf("%s rejected by moderation: %s", r.Kind, r.Category)
+}
+
+func (r *ModerationRejection) Is(target error) bool {
+	return target == ErrContentRejected
+}
+
+// ModerationPolicy 审核策略
+type ModerationPolicy struct {
+	ImageThreshold   float64       // 分类器置信度达到该值即拒绝
+	FailClosed       bool          // 分类器不可用时拒绝请求，否则放行并记录日志
+	SuspendThreshold int           // 窗口内违规次数达到该值时自动封禁，0表示不封禁
+	SuspendWindow    time.Duration // 违规计数窗口
+}
+
+// ModerationService 在创建任务前审核提示词和输入图片，记录违规并自动封禁屡次违规的用户
+type ModerationService struct {
+	db          *gorm.DB
+	rules       *moderation.RuleSet
+	classifiers []moderation.ImageClassifier
+	policy      ModerationPolicy
+}
+
+func NewModerationService(db *gorm.DB, rules *moderation.RuleSet, classifiers []moderation.ImageClassifier, policy ModerationPolicy) *ModerationService {
+	if rules == nil {
+		rules = &moderation.RuleSet{}
+	}
+	return &ModerationService{
+		db:          db,
+		rules:       rules,
+		classifiers: classifiers,
+		policy:      policy,
+	}
+}
+
+// ScreensImages 是否配置了图片分类器
+func (s *ModerationService) ScreensImages() bool {
+	return s != nil && len(s.classifiers) > 0
+}
+
+// CheckPrompt 审核提示词，命中规则时返回*ModerationRejection
+func (s *ModerationService) CheckPrompt(ctx context.Context, userID, prompt string) error {
+	if s == nil {
+		return nil
+	}
+	match := s.rules.Check(prompt)
+	if match == nil {
+		return nil
+	}
+	return s.reject(ctx, &models.ModerationViolation{
+		UserID:   userID,
+		Kind:     "prompt",
+		Category: match.Category,
+		Rule:     match.Rule,
+		Excerpt:  truncateRunes(prompt, maxViolationExcerpt),
+	})
+}
+
+// CheckImage 依次调用图片分类器，任一类别的置信度达到阈值即拒绝
+func (s *ModerationService) CheckImage(ctx context.Context, userID string, data []byte) error {
+	if !s.ScreensImages() {
+		return nil
+	}
+
+	contentType := http.DetectContentType(data)
+	if format, ok := imaging.DetectFormat(data); ok {
+		contentType = format.MIMEType()
+	}
+
+	for _, classifier := range s.classifiers {
+		labels, err := classifier.Classify(ctx, data, contentType)
+		if err != nil {
+			log.Printf("Image classifier %s failed: %v", classifier.Name(), err)
+			if s.policy.FailClosed {
+				return fmt.Errorf("%w: %v", ErrModerationUnavailable, err)
+			}
+			continue
+		}
+
+		for _, label := range labels {
+			if moderation.SafeCategories[label.Category] || label.Score < s.policy.ImageThreshold {
+				continue
+			}
+			sum := sha256.Sum256(data)
+			return s.reject(ctx, &models.ModerationViolation{
+				UserID:   userID,
+				Kind:     "image",
+				Category: label.Category,
+				Rule:     classifier.Name(),
+				Score:    label.Score,
+				Excerpt:  hex.EncodeToString(sum[:]),
+			})
+		}
+	}
+	return nil
+}
+
+// ListViolations 分页获取违规记录，userID为空时返回所有用户的记录
+func (s *ModerationService) ListViolations(ctx context.Context, userID string, limit, offset int) ([]models.ModerationViolation, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.ModerationViolation{})
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count violations: %w", err)
+	}
+
+	var violations []models.ModerationViolation
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&violations).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list violations: %w", err)
+	}
+	return violations, total, nil
+}
+
+// Reinstate 恢复被封禁的账号，已有的违规记录标记为已处理，不再计入自动封禁
+func (s *ModerationService) Reinstate(ctx context.Context, userID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).Update("is_active", true)
+		if result.Error != nil {
+			return fmt.Errorf("failed to reinstate user: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		return tx.Model(&models.ModerationViolation{}).
+			Where("user_id = ? AND resolved = ?", userID, false).
+			Update("resolved", true).Error
+	})
+}
+
+// reject 记录违规，窗口内违规次数达到阈值时封禁账号
+func (s *ModerationService) reject(ctx context.Context, violation *models.ModerationViolation) error {
+	rejection := &ModerationRejection{Kind: violation.Kind, Category: violation.Category}
+
+	if err := s.db.WithContext(ctx).Create(violation).Error; err != nil {
+		log.Printf("Failed to record moderation violation for user %s: %v", violation.UserID, err)
+		return rejection
+	}
+	log.Printf("Moderation rejected %s from user %s: category=%s rule=%q", violation.Kind, violation.UserID, violation.Category, violation.Rule)
+
+	if s.policy.SuspendThreshold <= 0 {
+		return rejection
+	}
+
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.ModerationViolation{}).
+		Where("user_id = ? AND resolved = ? AND created_at > ?", violation.UserID, false, time.Now().Add(-s.policy.SuspendWindow)).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("Failed to count moderation violations for user %s: %v", violation.UserID, err)
+		return rejection
+	}
+	if count < int64(s.policy.SuspendThreshold) {
+		return rejection
+	}
+
+	// 封禁时使已签发的令牌立即失效，恢复账号后需要重新登录
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND is_active = ?", violation.UserID, true).
+			Update("is_active", false)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		log.Printf("Suspended user %s after %d moderation violations", violation.UserID, count)
+		return revokeUserTokens(tx, violation.UserID, RevokeReasonSuspended)
+	})
+	if err != nil {
+		log.Printf("Failed to suspend user %s: %v", violation.UserID, err)
+		return rejection
+	}
+	rejection.Suspended = true
+	return rejection
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,467 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"3d-model-generator-backend/internal/cache"
+	"3d-model-generator-backend/internal/evaluation"
+	"3d-model-generator-backend/internal/mail"
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/storage"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeletionScheduled    = errors.New("账号已申请注销")
+	ErrDeletionNotScheduled = errors.New("账号没有待执行的注销申请")
+	// ErrDeletionConfirmationSent 账号没有密码，已向邮箱发送确认链接，使用其中的令牌完成注销
+	ErrDeletionConfirmationSent = errors.New("账号未设置密码，确认链接已发送到邮箱")
+)
+
+// RevokeReasonDeletionRequested 申请注销账号时撤销所有会话
+const RevokeReasonDeletionRequested = "deletion_requested"
+
+// PrivacyOptions 账号注销配置
+type PrivacyOptions struct {
+	DeletionGrace time.Duration // 申请注销到删除数据的宽限期，期间可以登录并撤销；0表示立即删除
+}
+
+// PrivacyService 个人数据导出和账号注销
+// 注销时删除用户本人的任务、上传、评估、模板和登录记录等数据；API用量统计去掉用户ID后保留，用于汇总费用。
+// 上传的图片按内容哈希存储，仍被其他用户引用的文件不会删除
+type PrivacyService struct {
+	db        *gorm.DB
+	storage   *storage.LocalStorage
+	uploads   *UploadService
+	models    *ModelService
+	retention *RetentionService
+	accounts  *AccountService
+	cache     *cache.CacheService
+	twoFactor *TwoFactorService
+	mailer    mail.Mailer
+	options   PrivacyOptions
+}
+
+func NewPrivacyService(db *gorm.DB, store *storage.LocalStorage, uploadService *UploadService, modelService *ModelService, retentionService *RetentionService, accountService *AccountService, cacheService *cache.CacheService, twoFactor *TwoFactorService, mailer mail.Mailer, options PrivacyOptions) *PrivacyService {
+	return &PrivacyService{
+		db:        db,
+		storage:   store,
+		uploads:   uploadService,
+		models:    modelService,
+		retention: retentionService,
+		accounts:  accountService,
+		cache:     cacheService,
+		twoFactor: twoFactor,
+		mailer:    mailer,
+		options:   options,
+	}
+}
+
+// RequestDeletion 确认密码（开启两步验证时还需验证码）后申请注销
+// 没有密码的账号（只通过单点登录使用）以邮件中的确认令牌代替密码：token为空时发送确认邮件并返回ErrDeletionConfirmationSent
+// 返回删除数据的时间；宽限期为0时立即删除并返回nil。申请后所有会话被撤销，宽限期内重新登录可以撤销申请
+func (s *PrivacyService) RequestDeletion(ctx context.Context, userID, password, token, code string) (*time.Time, error) {
+	var user models.User
+	var scheduledAt *time.Time
+	confirm := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if user.DeletionScheduledAt != nil {
+			return ErrDeletionScheduled
+		}
+		switch {
+		case user.PasswordHash != "":
+			if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+				return ErrInvalidPassword
+			}
+		case token == "":
+			confirm = true
+			return nil
+		default:
+			record, err := findToken(tx, token, models.TokenPurposeDeleteAccount)
+			if err != nil || record.UserID != user.ID {
+				return ErrInvalidAccountToken
+			}
+			if err := markTokenUsed(tx, record); err != nil {
+				return err
+			}
+		}
+		if user.TwoFactorEnabled {
+			if err := s.twoFactor.Verify(tx, &user, code); err != nil {
+				return err
+			}
+		}
+		if user.Role == models.RoleAdmin {
+			if err := ensureOtherAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		if s.options.DeletionGrace <= 0 {
+			return nil
+		}
+		at := time.Now().Add(s.options.DeletionGrace)
+		scheduledAt = &at
+		if err := tx.Model(&user).Update("deletion_scheduled_at", at).Error; err != nil {
+			return fmt.Errorf("failed to schedule deletion: %w", err)
+		}
+		return revokeUserTokens(tx, user.ID, RevokeReasonDeletionRequested)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		if err := s.accounts.SendDeletionConfirmation(ctx, &user); err != nil {
+			return nil, err
+		}
+		return nil, ErrDeletionConfirmationSent
+	}
+
+	if scheduledAt == nil {
+		if err := s.purge(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	deliverMail(s.mailer, mail.Message{
+		To:      user.Email,
+		Subject: "你的账号将被注销",
+		Text: fmt.Sprintf("%s，你好：\n\n我们收到了注销账号的申请，账号及其全部数据将在%s删除，删除后无法恢复。\n\n如果想保留账号，请在此之前登录并撤销注销申请。如果这不是你本人的操作，请立即登录撤销并修改密码。\n",
+			displayName(&user), scheduledAt.Format("2006-01-02 15:04 MST")),
+	})
+	log.Printf("Account %s scheduled for deletion at %s", user.ID, scheduledAt.Format(time.RFC3339))
+	return scheduledAt, nil
+}
+
+// CancelDeletion 撤销注销申请
+func (s *PrivacyService) CancelDeletion(ctx context.Context, userID string) error {
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL", userID).
+		Update("deletion_scheduled_at", nil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to cancel deletion: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeletionNotScheduled
+	}
+	log.Printf("Account %s deletion cancelled", userID)
+	return nil
+}
+
+// Start 定时删除宽限期已满的账号
+func (s *PrivacyService) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.PurgeDue(ctx); err != nil {
+					log.Printf("Account deletion sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// PurgeDue 删除宽限期已满的账号，单个账号失败不影响其他账号，下次重试
+func (s *PrivacyService) PurgeDue(ctx context.Context) error {
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("failed to find accounts due for deletion: %w", err)
+	}
+	for _, id := range ids {
+		if err := s.purge(ctx, id); err != nil {
+			log.Printf("Failed to delete account %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// purge 删除账号及其数据，数据库记录在一个事务中删除，之后删除不再被引用的文件
+// 先取消进行中的任务，再持有每个任务的锁直到删除完成，后处理和格式转换不会在删除后写入文件或记录
+// 已提交到腾讯云的任务无法撤回，结果不再写入
+func (s *PrivacyService) purge(ctx context.Context, userID string) error {
+	if userID == "" {
+		return ErrUserNotFound
+	}
+	db := s.db.WithContext(ctx)
+
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return ErrUserNotFound
+	}
+
+	// 生成流程的状态写入以任务未取消为条件，取消后不会再提交或更新
+	err := db.Model(&models.GenerationJob{}).
+		Where("user_id = ? AND status NOT IN ?", userID, []string{"completed", "failed"}).
+		Updates(map[string]interface{}{"status": "cancelled", "updated_at": time.Now()}).Error
+	if err != nil {
+		return fmt.Errorf("failed to cancel jobs: %w", err)
+	}
+	var ids []string
+	if err := db.Model(&models.GenerationJob{}).Where("user_id = ?", userID).Order("id").Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, id := range ids {
+		unlock := s.models.lockJob(id)
+		defer unlock()
+	}
+
+	var jobs []models.GenerationJob
+	if err := db.Where("user_id = ?", userID).Find(&jobs).Error; err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+	var uploads []models.Upload
+	if err := db.Where("user_id = ?", userID).Find(&uploads).Error; err != nil {
+		return fmt.Errorf("failed to list uploads: %w", err)
+	}
+	var uploadSessions []models.UploadSession
+	if err := db.Where("user_id = ?", userID).Find(&uploadSessions).Error; err != nil {
+		return fmt.Errorf("failed to list upload sessions: %w", err)
+	}
+
+	var keys []string
+	for _, job := range jobs {
+		if key, ok := s.storage.KeyFromURL(job.ImageURL); ok {
+			keys = append(keys, key)
+		}
+		for _, file := range job.ResultFiles {
+			if file.Key != "" {
+				keys = append(keys, file.Key)
+			}
+		}
+		for _, thumb := range job.Thumbnails {
+			if thumb.Key != "" {
+				keys = append(keys, thumb.Key)
+			}
+		}
+	}
+	for _, upload := range uploads {
+		keys = append(keys, upload.StorageKey)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		jobIDs := tx.Model(&models.GenerationJob{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("user_id = ? OR job_id IN (?)", userID, jobIDs).Delete(&models.Evaluation{}).Error; err != nil {
+			return fmt.Errorf("failed to delete evaluations: %w", err)
+		}
+		if err := tx.Where("job_id IN (?)", jobIDs).Delete(&evaluation.ABTestAssignment{}).Error; err != nil {
+			return fmt.Errorf("failed to delete ab test assignments: %w", err)
+		}
+		if err := tx.Where("email = ? OR user_id = ?", normalizeEmail(user.Email), userID).Delete(&models.AuthEvent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete auth events: %w", err)
+		}
+		if err := tx.Model(&models.APIUsage{}).Where("user_id = ?", userID).Update("user_id", "").Error; err != nil {
+			return fmt.Errorf("failed to anonymize api usage: %w", err)
+		}
+
+		owned := []interface{}{
+			&models.GenerationJob{},
+			&models.Upload{},
+			&models.UploadSession{},
+			&models.UploadSetView{},
+			&models.ModerationViolation{},
+			&models.PromptTemplate{},
+			&models.StylePreset{},
+			&models.Session{},
+			&models.APIKey{},
+			&models.AccountToken{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to delete %T: %w", model, err)
+			}
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, session := range uploadSessions {
+		if err := s.uploads.DeleteSessionData(session.ID); err != nil {
+			log.Printf("Failed to delete upload session data %s: %v", session.ID, err)
+		}
+	}
+	deleted, err := s.retention.DeleteUnreferenced(ctx, keys)
+	if err != nil {
+		// 数据库记录已删除，剩余文件由存储清理按孤儿文件回收
+		log.Printf("Failed to delete files of account %s: %v", userID, err)
+	}
+	// 其他用户提交相同的提示词时不再命中已删除的任务
+	for _, job := range jobs {
+		if job.InputType == "text" && job.Prompt != "" {
+			s.cache.Delete(ctx, s.cache.GeneratePromptCacheKey(job.Prompt))
+		}
+	}
+
+	log.Printf("Deleted account %s: %d jobs, %d uploads, %d files", userID, len(jobs), len(uploads), deleted)
+	return nil
+}
+
+// ExportManifest 数据导出的说明文件
+type ExportManifest struct {
+	UserID     string    `json:"user_id"`
+	ExportedAt time.Time `json:"exported_at"`
+	Files      []string  `json:"files"`
+	Missing    []string  `json:"missing,omitempty"` // 无法读取的文件
+}
+
+// Export 以ZIP流的方式导出用户的个人数据：
+// 资料、登录方式和会话（profile.json）、生成任务及提示词（jobs.json）、模板和风格预设（prompts.json）、
+// 评估（evaluations.json）、上传记录（uploads.json及uploads/目录下的原图）、登录记录、审核记录和API用量，
+// 已完成任务的模型文件按任务打包在jobs/<任务ID>/目录下
+func (s *PrivacyService) Export(ctx context.Context, userID string, w io.Writer) error {
+	db := s.db.WithContext(ctx)
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return ErrUserNotFound
+	}
+
+	zw := zip.NewWriter(w)
+	manifest := &ExportManifest{UserID: userID, ExportedAt: time.Now()}
+	writeJSON := func(name string, v interface{}) error {
+		fw, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, name)
+		return nil
+	}
+
+	var (
+		identities    []models.UserIdentity
+		sessions      []models.Session
+		apiKeys       []models.APIKey
+		jobs          []models.GenerationJob
+		uploads       []models.Upload
+		evaluations   []models.Evaluation
+		templates     []models.PromptTemplate
+		presets       []models.StylePreset
+		authEvents    []models.AuthEvent
+		violations    []models.ModerationViolation
+		apiUsage      []models.APIUsage
+		queryFailures []error
+	)
+	find := func(dest interface{}, query string, args ...interface{}) {
+		if err := db.Where(query, args...).Order("created_at ASC").Find(dest).Error; err != nil {
+			queryFailures = append(queryFailures, err)
+		}
+	}
+	find(&identities, "user_id = ?", userID)
+	find(&sessions, "user_id = ?", userID)
+	find(&apiKeys, "user_id = ?", userID)
+	find(&jobs, "user_id = ?", userID)
+	find(&uploads, "user_id = ?", userID)
+	find(&evaluations, "user_id = ?", userID)
+	find(&templates, "user_id = ?", userID)
+	find(&presets, "user_id = ?", userID)
+	find(&authEvents, "user_id = ? OR email = ?", userID, normalizeEmail(user.Email))
+	find(&violations, "user_id = ?", userID)
+	find(&apiUsage, "user_id = ?", userID)
+	if len(queryFailures) > 0 {
+		return fmt.Errorf("failed to collect personal data: %w", errors.Join(queryFailures...))
+	}
+
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"profile.json", map[string]interface{}{
+			"user":       user,
+			"identities": identities,
+			"sessions":   sessions,
+			"api_keys":   apiKeys,
+		}},
+		{"jobs.json", jobs},
+		{"prompts.json", map[string]interface{}{
+			"templates":     templates,
+			"style_presets": presets,
+		}},
+		{"evaluations.json", evaluations},
+		{"uploads.json", uploads},
+		{"login_history.json", authEvents},
+		{"moderation.json", violations},
+		{"api_usage.json", apiUsage},
+	}
+	for _, file := range files {
+		if err := writeJSON(file.name, file.value); err != nil {
+			return err
+		}
+	}
+
+	for _, upload := range uploads {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := "uploads/" + upload.ID + path.Ext(upload.StorageKey)
+		if err := s.copyObject(zw, name, upload.StorageKey); err != nil {
+			manifest.Missing = append(manifest.Missing, name)
+			continue
+		}
+		manifest.Files = append(manifest.Files, name)
+	}
+
+	for i := range jobs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if jobs[i].Status != "completed" {
+			continue
+		}
+		bundle := &Bundle{service: s.models, job: &jobs[i]}
+		dir := "jobs/" + jobs[i].ID
+		if err := bundle.writeTo(ctx, zw, dir); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, dir+"/")
+	}
+
+	if err := writeJSON("manifest.json", manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// copyObject 将存储中的文件写入ZIP包，文件不存在时不创建条目
+func (s *PrivacyService) copyObject(zw *zip.Writer, name, key string) error {
+	f, err := s.storage.Open(key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
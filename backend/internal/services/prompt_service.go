@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/prompt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("prompt template not found")
+	ErrPresetNotFound   = errors.New("style preset not found")
+	ErrInvalidPreset    = errors.New("invalid style preset")
+	ErrInvalidPrompt    = errors.New("invalid prompt")
+)
+
+// ValidTiers 生成选项中允许的服务档位
+var ValidTiers = map[string]bool{"": true, "standard": true, "pro": true, "rapid": true}
+
+// PromptService 管理提示词模板和风格预设，并在提交前处理提示词
+// ownerID为空表示全局模板/预设，只能通过管理员接口修改；用户可以读取自己的和全局的
+type PromptService struct {
+	db             *gorm.DB
+	pipeline       *prompt.Pipeline
+	enhanceDefault bool
+}
+
+// NewPromptService pipeline为nil时只检查长度；enhanceDefault为请求未指定enhance时是否执行流水线
+func NewPromptService(db *gorm.DB, pipeline *prompt.Pipeline, enhanceDefault bool) *PromptService {
+	return &PromptService{
+		db:             db,
+		pipeline:       pipeline,
+		enhanceDefault: enhanceDefault,
+	}
+}
+
+// Enhance 执行提示词处理流水线；未启用时只按档位检查长度，超出时返回prompt.ErrPromptTooLong
+func (s *PromptService) Enhance(ctx context.Context, text string, options *GenerationOptions) (*models.PromptPreviewResponse, error) {
+	var tier string
+	enhance := s.enhanceDefault
+	if options != nil {
+		tier = options.Tier
+		if options.Enhance != nil {
+			enhance = *options.Enhance
+		}
+	}
+
+	preview := &models.PromptPreviewResponse{
+		Original: text,
+		Prompt:   text,
+		Tier:     tier,
+		Limit:    prompt.Limit(tier),
+		Steps:    []models.PromptStep{},
+	}
+	if !enhance || s.pipeline == nil {
+		if err := prompt.CheckTierLength(text, tier); err != nil {
+			return nil, err
+		}
+		return preview, nil
+	}
+
+	result, err := s.pipeline.Run(ctx, text, tier)
+	if err != nil {
+		return nil, err
+	}
+	preview.Prompt = result.Prompt
+	preview.Enhanced = true
+	for _, step := range result.Steps {
+		preview.Steps = append(preview.Steps, models.PromptStep{
+			Stage:   step.Stage,
+			Output:  step.Output,
+			Changed: step.Changed,
+		})
+	}
+	return preview, nil
+}
+
+// ListTemplates 获取用户可用的模板：全局模板在前，然后是用户自己的模板
+func (s *PromptService) ListTemplates(ctx context.Context, userID string) ([]models.PromptTemplate, error) {
+	var templates []models.PromptTemplate
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? OR user_id = ?", "", userID).
+		Order("user_id ASC, name ASC").
+		Find(&templates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate 获取用户自己的或全局的模板
+func (s *PromptService) GetTemplate(ctx context.Context, userID, id string) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	err := s.db.WithContext(ctx).
+		Where("id = ? AND (user_id = ? OR user_id = ?)", id, "", userID).
+		First(&template).Error
+	if err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	return &template, nil
+}
+
+// CreateTemplate 创建模板，ownerID为空时创建全局模板
+func (s *PromptService) CreateTemplate(ctx context.Context, ownerID string, req *models.PromptTemplateRequest) (*models.PromptTemplate, error) {
+	template := &models.PromptTemplate{UserID: ownerID}
+	if err := applyTemplateRequest(template, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(template).Error; err != nil {
+		return nil, fmt.Errorf("failed to create template: %w", err)
+	}
+	return template, nil
+}
+
+// UpdateTemplate 整体替换模板内容，只能修改属于ownerID的模板
+func (s *PromptService) UpdateTemplate(ctx context.Context, ownerID, id string, req *models.PromptTemplateRequest) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, ownerID).First(&template).Error; err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	if err := applyTemplateRequest(&template, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(&template).Error; err != nil {
+		return nil, fmt.Errorf("failed to update template: %w", err)
+	}
+	return &template, nil
+}
+
+// DeleteTemplate 删除属于ownerID的模板
+func (s *PromptService) DeleteTemplate(ctx context.Context, ownerID, id string) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, ownerID).Delete(&models.PromptTemplate{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete template: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// ListPresets 获取用户可用的风格预设：全局预设在前，然后是用户自己的预设
+func (s *PromptService) ListPresets(ctx context.Context, userID string) ([]models.StylePreset, error) {
+	var presets []models.StylePreset
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? OR user_id = ?", "", userID).
+		Order("user_id ASC, name ASC").
+		Find(&presets).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presets: %w", err)
+	}
+	return presets, nil
+}
+
+// GetPreset 获取用户自己的或全局的风格预设
+func (s *PromptService) GetPreset(ctx context.Context, userID, id string) (*models.StylePreset, error) {
+	var preset models.StylePreset
+	err := s.db.WithContext(ctx).
+		Where("id = ? AND (user_id = ? OR user_id = ?)", id, "", userID).
+		First(&preset).Error
+	if err != nil {
+		return nil, ErrPresetNotFound
+	}
+	return &preset, nil
+}
+
+// CreatePreset 创建风格预设，ownerID为空时创建全局预设
+func (s *PromptService) CreatePreset(ctx context.Context, ownerID string, req *models.StylePresetRequest) (*models.StylePreset, error) {
+	preset := &models.StylePreset{UserID: ownerID}
+	if err := applyPresetRequest(preset, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(preset).Error; err != nil {
+		return nil, fmt.Errorf("failed to create preset: %w", err)
+	}
+	return preset, nil
+}
+
+// UpdatePreset 整体替换风格预设，只能修改属于ownerID的预设
+func (s *PromptService) UpdatePreset(ctx context.Context, ownerID, id string, req *models.StylePresetRequest) (*models.StylePreset, error) {
+	var preset models.StylePreset
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, ownerID).First(&preset).Error; err != nil {
+		return nil, ErrPresetNotFound
+	}
+	if err := applyPresetRequest(&preset, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(&preset).Error; err != nil {
+		return nil, fmt.Errorf("failed to update preset: %w", err)
+	}
+	return &preset, nil
+}
+
+// DeletePreset 删除属于ownerID的风格预设
+func (s *PromptService) DeletePreset(ctx context.Context, ownerID, id string) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, ownerID).Delete(&models.StylePreset{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete preset: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPresetNotFound
+	}
+	return nil
+}
+
+// Resolve 将生成请求中的模板和风格预设展开为最终的提示词和生成选项
+// 请求中显式给出的选项优先于预设中的选项
+func (s *PromptService) Resolve(ctx context.Context, userID string, req *models.GenerationRequest) error {
+	if req.TemplateID != "" {
+		if strings.TrimSpace(req.Prompt) != "" {
+			return fmt.Errorf("%w: prompt and template_id cannot be used together", ErrInvalidPrompt)
+		}
+		template, err := s.GetTemplate(ctx, userID, req.TemplateID)
+		if err != nil {
+			return err
+		}
+		if req.Prompt, err = prompt.Render(template.Template, req.Variables, template.Defaults); err != nil {
+			return err
+		}
+	}
+
+	if req.PresetID != "" {
+		preset, err := s.GetPreset(ctx, userID, req.PresetID)
+		if err != nil {
+			return err
+		}
+		req.Prompt = prompt.AppendSuffix(req.Prompt, preset.PromptSuffix)
+		if o := preset.Options; o != nil {
+			if req.ResultFormat == "" {
+				req.ResultFormat = o.ResultFormat
+			}
+			req.EnablePBR = req.EnablePBR || o.EnablePBR
+			if req.FaceCount == 0 {
+				req.FaceCount = o.FaceCount
+			}
+			if req.GenerateType == "" {
+				req.GenerateType = o.GenerateType
+			}
+			if req.Tier == "" {
+				req.Tier = o.Tier
+			}
+			if req.Enhance == nil {
+				req.Enhance = o.Enhance
+			}
+		}
+	}
+
+	if !ValidTiers[req.Tier] {
+		return fmt.Errorf("%w: unknown tier %q", ErrInvalidPrompt, req.Tier)
+	}
+	return prompt.CheckLength(req.Prompt)
+}
+
+func applyTemplateRequest(template *models.PromptTemplate, req *models.PromptTemplateRequest) error {
+	variables, err := prompt.Placeholders(req.Template)
+	if err != nil {
+		return err
+	}
+	// 模板展开后也不能超过接口限制，用默认值和占位符名估算不准确，这里只检查模板本身
+	if err := prompt.CheckLength(req.Template); err != nil {
+		return err
+	}
+
+	template.Name = strings.TrimSpace(req.Name)
+	template.Description = req.Description
+	template.Template = req.Template
+	template.Variables = variables
+	template.Defaults = req.Defaults
+	return nil
+}
+
+func applyPresetRequest(preset *models.StylePreset, req *models.StylePresetRequest) error {
+	if req.Options != nil && !ValidTiers[req.Options.Tier] {
+		return fmt.Errorf("%w: unknown tier %q", ErrInvalidPreset, req.Options.Tier)
+	}
+	if err := prompt.CheckLength(req.PromptSuffix); err != nil {
+		return err
+	}
+
+	preset.Name = strings.TrimSpace(req.Name)
+	preset.Description = req.Description
+	preset.PromptSuffix = strings.TrimSpace(req.PromptSuffix)
+	preset.Options = req.Options
+	return nil
+}
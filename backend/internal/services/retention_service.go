@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// 存储文件类型
+const (
+	ArtifactUploads     = "uploads"
+	ArtifactResults     = "results"
+	ArtifactDerivatives = "derivatives"
+	ArtifactThumbnails  = "thumbnails"
+)
+
+// artifactPrefixes 文件类型及其存储前缀，按清理顺序排列
+var artifactPrefixes = []struct {
+	Type   string
+	Prefix string
+}{
+	{ArtifactUploads, "images"},
+	{ArtifactResults, "results"},
+	{ArtifactDerivatives, "derived"},
+	{ArtifactThumbnails, "thumbnails"},
+}
+
+// RetentionPolicy 保留策略，TTL为0表示永久保留
+type RetentionPolicy struct {
+	TTL         map[string]time.Duration
+	OrphanGrace time.Duration
+}
+
+// RetentionService 存储保留策略与垃圾回收
+type RetentionService struct {
+	db      *gorm.DB
+	storage *storage.LocalStorage
+	models  *ModelService
+	policy  RetentionPolicy
+	mu      sync.Mutex // 同一时间只允许一次清理
+}
+
+// blobRef 存储对象的引用方
+type blobRef struct {
+	JobID    string
+	UploadID string
+	UserID   string          // 第一个引用方的用户，用于清理报告
+	Users    map[string]bool // 所有引用该文件的用户
+	Active   bool            // 任务仍在进行中，输入文件不能删除
+}
+
+func NewRetentionService(db *gorm.DB, store *storage.LocalStorage, modelService *ModelService, policy RetentionPolicy) *RetentionService {
+	return &RetentionService{
+		db:      db,
+		storage: store,
+		models:  modelService,
+		policy:  policy,
+	}
+}
+
+// Start 启动定时清理
+func (s *RetentionService) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := s.Sweep(ctx, false)
+				if err != nil {
+					log.Printf("Retention sweep failed: %v", err)
+					continue
+				}
+				log.Printf("Retention sweep deleted %d files, freed %d bytes", len(report.Items), report.FreedBytes)
+			}
+		}
+	}()
+}
+
+// Sweep 删除过期或孤立的文件，dryRun为true时只生成报告
+func (s *RetentionService) Sweep(ctx context.Context, dryRun bool) (*models.RetentionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.collectReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &models.RetentionReport{
+		DryRun:    dryRun,
+		StartedAt: now,
+		Items:     []models.RetentionItem{},
+		ByType:    make(map[string]*models.RetentionSummary),
+	}
+
+	for _, artifact := range artifactPrefixes {
+		summary := &models.RetentionSummary{}
+		report.ByType[artifact.Type] = summary
+		ttl := s.policy.TTL[artifact.Type]
+
+		err := s.storage.Walk(artifact.Prefix, func(key string, info os.FileInfo) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			summary.Scanned++
+			summary.ScannedBytes += info.Size()
+
+			age := now.Sub(info.ModTime())
+			ref, referenced := refs[key]
+
+			var reason string
+			switch {
+			case referenced && ref.Active:
+				return nil
+			case !referenced && age > s.policy.OrphanGrace:
+				reason = "orphaned"
+			case ttl > 0 && age > ttl:
+				reason = "expired"
+			default:
+				return nil
+			}
+
+			if !dryRun {
+				if err := s.storage.Delete(key); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", key, err))
+					return nil
+				}
+			}
+
+			summary.Deleted++
+			summary.DeletedBytes += info.Size()
+			report.FreedBytes += info.Size()
+			report.Items = append(report.Items, models.RetentionItem{
+				Key:          key,
+				ArtifactType: artifact.Type,
+				Reason:       reason,
+				Size:         info.Size(),
+				ModTime:      info.ModTime(),
+				JobID:        ref.JobID,
+				UserID:       ref.UserID,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", artifact.Prefix, err)
+		}
+	}
+
+	if !dryRun {
+		if err := s.detachDeleted(report.Items); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// DeleteUnreferenced 删除不再被任何上传记录或任务引用的文件，返回删除的数量
+// 上传的图片按内容哈希存储，可能同时属于其他用户，仍被引用的文件保留
+func (s *RetentionService) DeleteUnreferenced(ctx context.Context, keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.collectReferences(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if _, referenced := refs[key]; referenced {
+			continue
+		}
+		if err := s.storage.Delete(key); err != nil {
+			log.Printf("Failed to delete %s: %v", key, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// GetUserUsage 获取单个用户的存储用量
+func (s *RetentionService) GetUserUsage(ctx context.Context, userID string) (*models.StorageUsage, error) {
+	usage, err := s.computeUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := usage[userID]; ok {
+		return u, nil
+	}
+	return &models.StorageUsage{UserID: userID, ByType: map[string]int64{}}, nil
+}
+
+// GetUsageByUser 获取所有用户的存储用量，按占用空间降序
+func (s *RetentionService) GetUsageByUser(ctx context.Context) ([]models.StorageUsage, error) {
+	usage, err := s.computeUsage(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.StorageUsage, 0, len(usage))
+	for _, u := range usage {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Bytes > result[j].Bytes
+	})
+	return result, nil
+}
+
+// computeUsage 统计被上传记录和任务引用的文件大小，userID为空时统计所有用户
+// 图片按内容哈希存储，不同用户上传的相同图片共用一个文件，共享的文件计入每个引用用户的用量，
+// 因此各用户用量之和可能大于实际占用
+func (s *RetentionService) computeUsage(ctx context.Context, userID string) (map[string]*models.StorageUsage, error) {
+	refs, err := s.collectReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]*models.StorageUsage)
+	for key, ref := range refs {
+		if userID != "" && !ref.Users[userID] {
+			continue
+		}
+		info, err := s.storage.Stat(key)
+		if err != nil {
+			continue
+		}
+
+		for owner := range ref.Users {
+			if userID != "" && owner != userID {
+				continue
+			}
+			u, ok := usage[owner]
+			if !ok {
+				u = &models.StorageUsage{UserID: owner, ByType: map[string]int64{}}
+				usage[owner] = u
+			}
+			u.Files++
+			u.Bytes += info.Size()
+			u.ByType[artifactType(key)] += info.Size()
+		}
+	}
+	return usage, nil
+}
+
+// collectReferences 收集所有上传记录和任务引用的存储key
+func (s *RetentionService) collectReferences(ctx context.Context) (map[string]blobRef, error) {
+	refs := make(map[string]blobRef)
+
+	var uploads []models.Upload
+	err := s.db.WithContext(ctx).FindInBatches(&uploads, 500, func(tx *gorm.DB, batch int) error {
+		for _, upload := range uploads {
+			refs[upload.StorageKey] = mergeRef(refs[upload.StorageKey], blobRef{
+				UploadID: upload.ID,
+				UserID:   upload.UserID,
+			})
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect upload references: %w", err)
+	}
+
+	var jobs []models.GenerationJob
+	err = s.db.WithContext(ctx).FindInBatches(&jobs, 500, func(tx *gorm.DB, batch int) error {
+		for _, job := range jobs {
+			ref := blobRef{
+				JobID:  job.ID,
+				UserID: job.UserID,
+				Active: job.Status != "completed" && job.Status != "failed" && job.Status != "cancelled",
+			}
+			if key, ok := s.storage.KeyFromURL(job.ImageURL); ok {
+				refs[key] = mergeRef(refs[key], ref)
+			}
+			for _, view := range job.ViewImages {
+				if key, ok := s.storage.KeyFromURL(view.ViewImageURL); ok {
+					refs[key] = mergeRef(refs[key], ref)
+				}
+			}
+			for _, file := range job.ResultFiles {
+				if file.Key != "" {
+					refs[file.Key] = mergeRef(refs[file.Key], ref)
+				}
+			}
+			for _, thumb := range job.Thumbnails {
+				if thumb.Key != "" {
+					refs[thumb.Key] = mergeRef(refs[thumb.Key], ref)
+				}
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect references: %w", err)
+	}
+	return refs, nil
+}
+
+// mergeRef 合并同一文件的多个引用方，记录所有引用用户；只要有一个任务进行中就视为活跃
+func mergeRef(existing, ref blobRef) blobRef {
+	if existing.Users == nil {
+		ref.Users = map[string]bool{ref.UserID: true}
+		return ref
+	}
+	existing.Users[ref.UserID] = true
+	if existing.JobID == "" {
+		existing.JobID = ref.JobID
+	}
+	if existing.UploadID == "" {
+		existing.UploadID = ref.UploadID
+	}
+	existing.Active = existing.Active || ref.Active
+	return existing
+}
+
+// detachDeleted 删除已过期图片的上传记录，并从任务记录中移除已删除文件的引用
+func (s *RetentionService) detachDeleted(items []models.RetentionItem) error {
+	var uploadKeys []string
+	deleted := make(map[string]map[string]bool)
+	for _, item := range items {
+		if item.ArtifactType == ArtifactUploads {
+			uploadKeys = append(uploadKeys, item.Key)
+			continue
+		}
+		if item.JobID == "" {
+			continue
+		}
+		if deleted[item.JobID] == nil {
+			deleted[item.JobID] = make(map[string]bool)
+		}
+		deleted[item.JobID][item.Key] = true
+	}
+
+	// 同内容的文件可能在清理后被重新上传，只删除文件确实已不存在的上传记录
+	var removed []string
+	for _, key := range uploadKeys {
+		if _, err := s.storage.Stat(key); os.IsNotExist(err) {
+			removed = append(removed, key)
+		}
+	}
+	if len(removed) > 0 {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			uploadIDs := tx.Model(&models.Upload{}).Select("id").Where("storage_key IN ?", removed)
+			if err := tx.Where("upload_id IN (?)", uploadIDs).Delete(&models.UploadSetView{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("storage_key IN ?", removed).Delete(&models.Upload{}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete upload records: %w", err)
+		}
+	}
+
+	for jobID, keys := range deleted {
+		if err := s.models.DetachFiles(jobID, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func artifactType(key string) string {
+	for _, artifact := range artifactPrefixes {
+		if strings.HasPrefix(key, artifact.Prefix+"/") {
+			return artifact.Type
+		}
+	}
+	return "other"
+}
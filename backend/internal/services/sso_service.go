@@ -0,0 +1,463 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSSOProviderNotFound    = errors.New("单点登录提供方不存在")
+	ErrSSOProviderUnavailable = errors.New("单点登录提供方暂时不可用")
+	ErrSSOInvalidState        = errors.New("登录请求无效或已过期，请重新登录")
+	ErrSSOAuthFailed          = errors.New("单点登录认证失败")
+	ErrSSOEmailNotVerified    = errors.New("身份提供方未提供已验证的邮箱")
+	ErrSSODomainNotAllowed    = errors.New("该邮箱域名不允许通过此方式登录")
+	ErrSSOSignupDisabled      = errors.New("该邮箱未注册，请联系管理员")
+	ErrSSOAccountUnverified   = errors.New("该邮箱已注册但尚未验证，请先验证邮箱或通过找回密码登录后再使用单点登录")
+	ErrIdentityNotFound       = errors.New("外部身份不存在")
+	ErrLastLoginMethod        = errors.New("这是账号唯一的登录方式，请先设置密码")
+)
+
+const (
+	// SSOStateExpiry 授权请求的有效期，也是绑定浏览器的cookie的有效期
+	SSOStateExpiry     = 10 * time.Minute
+	ssoLoginCodeExpiry = 2 * time.Minute
+	ssoHTTPTimeout     = 10 * time.Second
+)
+
+// OIDCProviderConfig OIDC身份提供方配置
+type OIDCProviderConfig struct {
+	Name           string // 路由中使用的标识，例如 corp
+	DisplayName    string // 登录页面显示的名称
+	Issuer         string // 用于发现配置的issuer地址
+	ClientID       string
+	ClientSecret   string   // 公共客户端为空，仅使用PKCE
+	Scopes         []string // 额外申请的scope，openid、email、profile总会申请
+	AllowedDomains []string // 为空时不限制邮箱域名
+	AllowSignup    bool     // 邮箱未注册时是否自动创建账号
+	RedirectURL    string   // 本服务的回调地址，需在身份提供方处登记
+}
+
+// SSOService OIDC单点登录：授权码+PKCE流程，按subject或已验证的邮箱关联到本地用户
+// 身份提供方的发现配置在首次使用时获取，提供方不可用不影响服务启动；JWKS由go-oidc缓存并在密钥轮换时刷新
+type SSOService struct {
+	db          *gorm.DB
+	providers   map[string]*ssoProvider
+	names       []string
+	client      *http.Client
+	adminEmails []string // 尚无管理员时，这些邮箱经身份提供方验证后成为管理员
+}
+
+type ssoProvider struct {
+	cfg OIDCProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	oidc     *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+// ssoClaims 从ID令牌（或userinfo）中读取的身份信息
+type ssoClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+func NewSSOService(db *gorm.DB, providers []OIDCProviderConfig, adminEmails []string) (*SSOService, error) {
+	s := &SSOService{
+		db:          db,
+		providers:   make(map[string]*ssoProvider, len(providers)),
+		client:      &http.Client{Timeout: ssoHTTPTimeout},
+		adminEmails: adminEmails,
+	}
+	for _, cfg := range providers {
+		if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc provider %q: name, issuer, client id and redirect url are required", cfg.Name)
+		}
+		if _, exists := s.providers[cfg.Name]; exists {
+			return nil, fmt.Errorf("duplicate oidc provider %q", cfg.Name)
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = cfg.Name
+		}
+		for i, domain := range cfg.AllowedDomains {
+			cfg.AllowedDomains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		}
+		s.providers[cfg.Name] = &ssoProvider{cfg: cfg}
+		s.names = append(s.names, cfg.Name)
+	}
+	return s, nil
+}
+
+// Providers 已配置的提供方，按配置顺序
+func (s *SSOService) Providers() []models.SSOProvider {
+	providers := make([]models.SSOProvider, 0, len(s.names))
+	for _, name := range s.names {
+		providers = append(providers, models.SSOProvider{Name: name, DisplayName: s.providers[name].cfg.DisplayName})
+	}
+	return providers
+}
+
+// Begin 创建授权请求，返回身份提供方的授权地址和浏览器绑定值
+// 绑定值需保存在发起请求的浏览器中（cookie），回调时一并提交，防止他人把自己的回调地址交给受害者完成登录
+func (s *SSOService) Begin(ctx context.Context, providerName string) (authURL, binding string, err error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	if err := s.db.WithContext(ctx).Create(&models.SSOState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(SSOStateExpiry),
+	}).Error; err != nil {
+		return "", "", fmt.Errorf("failed to save sso state: %w", err)
+	}
+
+	return provider.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), hashToken(state), nil
+}
+
+// Callback 处理授权回调：校验state及其与浏览器的绑定，用授权码和PKCE verifier换取令牌，校验ID令牌，返回关联的本地用户
+func (s *SSOService) Callback(ctx context.Context, providerName, state, binding, code string) (*models.User, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(hashToken(state)), []byte(binding)) != 1 {
+		return nil, ErrSSOInvalidState
+	}
+	record, err := s.consumeState(ctx, providerName, state)
+	if err != nil {
+		return nil, err
+	}
+	if code == "" {
+		return nil, ErrSSOAuthFailed
+	}
+
+	ctx = oidc.ClientContext(ctx, s.client)
+	token, err := provider.oauth.Exchange(ctx, code, oauth2.VerifierOption(record.CodeVerifier))
+	if err != nil {
+		log.Printf("OIDC provider %s code exchange failed: %v", providerName, err)
+		return nil, ErrSSOAuthFailed
+	}
+	claims, err := provider.verify(ctx, token, record.Nonce)
+	if err != nil {
+		log.Printf("OIDC provider %s token validation failed: %v", providerName, err)
+		return nil, ErrSSOAuthFailed
+	}
+
+	return s.resolveUser(ctx, provider.cfg, claims)
+}
+
+// IssueLoginCode 签发一次性登录码，前端用它通过Exchange换取令牌，避免令牌出现在重定向地址中
+func (s *SSOService) IssueLoginCode(ctx context.Context, user *models.User) (string, error) {
+	code, err := randomToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate login code: %w", err)
+	}
+	if err := s.db.WithContext(ctx).Create(&models.AccountToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposeSSOLogin,
+		TokenHash: hashToken(code),
+		Email:     normalizeEmail(user.Email),
+		ExpiresAt: time.Now().Add(ssoLoginCodeExpiry),
+	}).Error; err != nil {
+		return "", fmt.Errorf("failed to save login code: %w", err)
+	}
+	return code, nil
+}
+
+// RedeemLoginCode 使用一次性登录码，返回对应的用户
+func (s *SSOService) RedeemLoginCode(ctx context.Context, code string) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record, err := consumeToken(tx, code, models.TokenPurposeSSOLogin)
+		if err != nil {
+			return ErrSSOInvalidState
+		}
+		if err := tx.Where("id = ? AND is_active = ?", record.UserID, true).First(&user).Error; err != nil {
+			return ErrSSOInvalidState
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListIdentities 获取用户关联的外部身份
+func (s *SSOService) ListIdentities(ctx context.Context, userID string) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	return identities, nil
+}
+
+// Unlink 解除外部身份的关联，没有设置密码时不能解除最后一个外部身份
+func (s *SSOService) Unlink(ctx context.Context, userID, identityID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return ErrUserNotFound
+		}
+		var identity models.UserIdentity
+		if err := tx.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+			return ErrIdentityNotFound
+		}
+		if user.PasswordHash == "" {
+			var count int64
+			if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to count identities: %w", err)
+			}
+			if count <= 1 {
+				return ErrLastLoginMethod
+			}
+		}
+		return tx.Delete(&identity).Error
+	})
+}
+
+// provider 返回已完成发现的提供方，发现失败时下次请求重试
+func (s *SSOService) provider(ctx context.Context, name string) (*ssoProvider, error) {
+	p, ok := s.providers[name]
+	if !ok {
+		return nil, ErrSSOProviderNotFound
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oidc != nil {
+		return p, nil
+	}
+
+	// go-oidc在后续获取JWKS时沿用这里的context，不能使用请求的context
+	discoveryCtx := oidc.ClientContext(context.Background(), s.client)
+	provider, err := oidc.NewProvider(discoveryCtx, p.cfg.Issuer)
+	if err != nil {
+		log.Printf("OIDC discovery for provider %s failed: %v", name, err)
+		return nil, ErrSSOProviderUnavailable
+	}
+
+	scopes := []string{oidc.ScopeOpenID, "email", "profile"}
+	for _, scope := range p.cfg.Scopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	p.oidc = provider
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       scopes,
+	}
+	return p, nil
+}
+
+// verify 校验ID令牌的签名、issuer、audience、有效期和nonce；ID令牌中没有邮箱时从userinfo补充
+func (p *ssoProvider) verify(ctx context.Context, token *oauth2.Token, nonce string) (*ssoClaims, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var raw struct {
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse id_token claims: %w", err)
+	}
+	claims := &ssoClaims{
+		Subject:       idToken.Subject,
+		Email:         raw.Email,
+		EmailVerified: truthy(raw.EmailVerified),
+		Name:          raw.Name,
+	}
+
+	if claims.Email == "" {
+		info, err := p.oidc.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch userinfo: %w", err)
+		}
+		if info.Subject != claims.Subject {
+			return nil, errors.New("userinfo subject mismatch")
+		}
+		claims.Email = info.Email
+		claims.EmailVerified = info.EmailVerified
+		if claims.Name == "" {
+			var profile struct {
+				Name string `json:"name"`
+			}
+			if err := info.Claims(&profile); err == nil {
+				claims.Name = profile.Name
+			}
+		}
+	}
+	return claims, nil
+}
+
+// resolveUser 查找或创建外部身份对应的本地用户
+// 已关联的身份直接登录；未关联时只按身份提供方验证过的邮箱关联已有用户，邮箱未注册时按配置创建账号。
+// 本地账号的邮箱未验证时不关联：注册时填写的邮箱未经确认，他人可能抢先用受害者的邮箱注册并设置了密码、
+// 两步验证或API密钥，关联后这些登录方式仍然有效
+func (s *SSOService) resolveUser(ctx context.Context, cfg OIDCProviderConfig, claims *ssoClaims) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", cfg.Name, claims.Subject).First(&identity).Error
+		if err == nil {
+			if err := tx.Where("id = ? AND is_active = ?", identity.UserID, true).First(&user).Error; err != nil {
+				return ErrUserNotFound
+			}
+			return tx.Model(&identity).Updates(map[string]interface{}{"email": claims.Email, "last_login_at": now}).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to find identity: %w", err)
+		}
+
+		email := normalizeEmail(claims.Email)
+		if email == "" || !claims.EmailVerified {
+			return ErrSSOEmailNotVerified
+		}
+		if !domainAllowed(email, cfg.AllowedDomains) {
+			return ErrSSODomainNotAllowed
+		}
+
+		err = tx.Where("LOWER(email) = ?", email).First(&user).Error
+		switch {
+		case err == nil:
+			if !user.IsActive {
+				return ErrUserNotFound
+			}
+			if !user.EmailVerified {
+				return ErrSSOAccountUnverified
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !cfg.AllowSignup {
+				return ErrSSOSignupDisabled
+			}
+			name := claims.Name
+			if name == "" {
+				name, _, _ = strings.Cut(email, "@")
+			}
+			// 没有密码，只能通过单点登录或找回密码登录
+			user = models.User{
+				Email:           email,
+				Name:            name,
+				Role:            models.RoleUser,
+				IsActive:        true,
+				EmailVerified:   true,
+				EmailVerifiedAt: &now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+			log.Printf("Created user %s from OIDC provider %s", user.ID, cfg.Name)
+		default:
+			return fmt.Errorf("failed to find user: %w", err)
+		}
+
+		if err := tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    cfg.Name,
+			Subject:     claims.Subject,
+			Email:       email,
+			LastLoginAt: &now,
+		}).Error; err != nil {
+			return err
+		}
+		return bootstrapAdmin(tx, s.adminEmails, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// consumeState 校验并作废授权请求的state，state只能属于发起请求的提供方
+func (s *SSOService) consumeState(ctx context.Context, providerName, state string) (*models.SSOState, error) {
+	if state == "" {
+		return nil, ErrSSOInvalidState
+	}
+	var record models.SSOState
+	if err := s.db.WithContext(ctx).Where("state_hash = ?", hashToken(state)).First(&record).Error; err != nil {
+		return nil, ErrSSOInvalidState
+	}
+	if record.Provider != providerName || record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, ErrSSOInvalidState
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.SSOState{}).
+		Where("id = ? AND used_at IS NULL", record.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume sso state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrSSOInvalidState
+	}
+	return &record, nil
+}
+
+func domainAllowed(email string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	return slices.Contains(domains, domain)
+}
+
+// truthy 部分身份提供方将email_verified以字符串"true"返回
+func truthy(v any) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(value, "true")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/totp"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("未开启两步验证")
+	ErrTwoFactorAlreadyEnabled = errors.New("已开启两步验证")
+	ErrTwoFactorNotSetUp       = errors.New("请先获取两步验证密钥")
+	ErrInvalidTwoFactorCode    = errors.New("验证码错误")
+	ErrInvalidPassword         = errors.New("密码错误")
+)
+
+const (
+	// RecoveryCodeCount 每次生成的恢复码数量
+	RecoveryCodeCount = 10
+
+	recoveryCodeLength = 10
+	qrCodeSize         = 256
+)
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// TwoFactorService TOTP两步验证：注册、启用、关闭和恢复码
+// TOTP密钥需要还原才能计算验证码，使用AES-GCM加密保存；恢复码只保存带密钥的HMAC
+type TwoFactorService struct {
+	db     *gorm.DB
+	aead   cipher.AEAD
+	macKey []byte
+	issuer string
+}
+
+// NewTwoFactorService key为任意长度的服务端密钥，通过SHA-256派生加密密钥和HMAC密钥
+func NewTwoFactorService(db *gorm.DB, key, issuer string) (*TwoFactorService, error) {
+	if key == "" {
+		return nil, errors.New("two-factor encryption key is required")
+	}
+	aead, err := newAEAD("totp-encryption", key)
+	if err != nil {
+		return nil, err
+	}
+	macKey := sha256.Sum256([]byte("recovery-code:" + key))
+	return &TwoFactorService{
+		db:     db,
+		aead:   aead,
+		macKey: macKey[:],
+		issuer: issuer,
+	}, nil
+}
+
+// Status 两步验证状态和剩余的恢复码数量
+func (s *TwoFactorService) Status(ctx context.Context, userID string) (*models.TwoFactorStatusResponse, error) {
+	user, err := s.activeUser(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.TwoFactorStatusResponse{Enabled: user.TwoFactorEnabled}
+	if user.TwoFactorEnabled {
+		if err := s.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Count(&status.RecoveryCodesRemaining).Error; err != nil {
+			return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// Setup 生成新的待确认密钥，覆盖之前未确认的密钥；已开启时需先关闭
+func (s *TwoFactorService) Setup(ctx context.Context, userID string) (*models.TwoFactorSetupResponse, error) {
+	user, err := s.activeUser(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	sealed, err := s.seal(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(user).Updates(map[string]interface{}{
+		"totp_secret":       sealed,
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save secret: %w", err)
+	}
+
+	uri := totp.URI(s.issuer, user.Email, secret)
+	png, err := totp.QRCodePNG(uri, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render qr code: %w", err)
+	}
+	return &models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// Enable 用验证码确认待确认的密钥并开启两步验证，返回首批恢复码
+func (s *TwoFactorService) Enable(ctx context.Context, userID, code string) ([]string, error) {
+	var codes []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.activeUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TwoFactorEnabled {
+			return ErrTwoFactorAlreadyEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrTwoFactorNotSetUp
+		}
+		if err := s.verifyTOTP(tx, user, code); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("two_factor_enabled", true).Error; err != nil {
+			return fmt.Errorf("failed to enable two-factor: %w", err)
+		}
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable 关闭两步验证，需要密码和验证码（或恢复码），同时删除密钥和恢复码
+func (s *TwoFactorService) Disable(ctx context.Context, userID, password, code string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.activeUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled {
+			return ErrTwoFactorNotEnabled
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return ErrInvalidPassword
+		}
+		if err := s.Verify(tx, user, code); err != nil {
+			return err
+		}
+		return clearTwoFactor(tx, userID)
+	})
+}
+
+// RegenerateRecoveryCodes 用验证码确认后生成新的恢复码，之前的恢复码全部作废
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	var codes []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.activeUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled {
+			return ErrTwoFactorNotEnabled
+		}
+		if err := s.verifyTOTP(tx, user, code); err != nil {
+			return err
+		}
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify 校验6位验证码或恢复码，使用过的恢复码随即作废
+func (s *TwoFactorService) Verify(tx *gorm.DB, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(tx, user, code)
+	}
+	return s.useRecoveryCode(tx, user.ID, code)
+}
+
+// verifyTOTP 校验验证码，并以条件更新记录使用的时间步，同一验证码只能使用一次
+func (s *TwoFactorService) verifyTOTP(tx *gorm.DB, user *models.User, code string) error {
+	secret, err := s.open(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+	counter, ok := totp.Validate(secret, code, time.Now())
+	if !ok || counter <= user.TOTPLastCounter {
+		return ErrInvalidTwoFactorCode
+	}
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+		Update("totp_last_counter", counter)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record totp counter: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	user.TOTPLastCounter = counter
+	return nil
+}
+
+func (s *TwoFactorService) useRecoveryCode(tx *gorm.DB, userID, code string) error {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return ErrInvalidTwoFactorCode
+	}
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, s.hashRecoveryCode(normalized)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to use recovery code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 删除旧的恢复码并生成新的一批，返回明文，格式为xxxxx-xxxxx
+func (s *TwoFactorService) replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := recoveryEncoding.EncodeToString(b)[:recoveryCodeLength]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: s.hashRecoveryCode(code)})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+func (s *TwoFactorService) hashRecoveryCode(code string) string {
+	mac := hmac.New(sha256.New, s.macKey)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *TwoFactorService) activeUser(tx *gorm.DB, userID string) (*models.User, error) {
+	var user models.User
+	if err := tx.Where("id = ? AND is_active = ?", userID, true).First(&user).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// seal 加密TOTP密钥
+func (s *TwoFactorService) seal(secret string) (string, error) {
+	return sealBytes(s.aead, []byte(secret))
+}
+
+func (s *TwoFactorService) open(sealed string) (string, error) {
+	secret, err := openBytes(s.aead, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// clearTwoFactor 关闭两步验证并删除密钥和恢复码
+func clearTwoFactor(tx *gorm.DB, userID string) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled": false,
+		"totp_secret":        "",
+		"totp_last_counter":  0,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to disable two-factor: %w", err)
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和分隔符
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '-' || r == ' ':
+			return -1
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return r
+		}
+	}, code)
+}
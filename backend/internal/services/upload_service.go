@@ -0,0 +1,279 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"3d-model-generator-backend/internal/imaging"
+	"3d-model-generator-backend/internal/models"
+	"3d-model-generator-backend/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// 混元生3D接口对输入图片的限制
+const (
+	MaxUploadSize     = 8 * 1024 * 1024
+	MinImageDimension = 128
+	MaxImageDimension = 5000
+
+	// MaxSubmitImageSize base64编码前的图片大小上限，编码后不超过8MB
+	MaxSubmitImageSize = 6 * 1024 * 1024
+	// MaxSubmitImageURLSize 通过ImageUrl提交时不需要编码，图片本身不超过8MB即可
+	MaxSubmitImageURLSize = 8 * 1024 * 1024
+)
+
+var (
+	ErrUploadTooLarge   = errors.New("upload exceeds size limit")
+	ErrUnsupportedImage = errors.New("unsupported image")
+	ErrImageDimensions  = errors.New("image dimensions out of range")
+	ErrCorruptImage     = errors.New("image data is truncated or corrupt")
+	ErrUploadNotFound   = errors.New("upload not found")
+)
+
+// UploadService 图片上传服务
+type UploadService struct {
+	db           *gorm.DB
+	storage      *storage.LocalStorage
+	sessions     *storage.LocalStorage // 断点续传的临时文件，位于对外提供的存储目录之外
+	sessionTTL   time.Duration         // 断点续传会话无活动后的过期时间
+	maxSessions  int                   // 每个用户同时未完成的会话数上限，0表示不限制
+	sessionLocks keyedMutex
+}
+
+func NewUploadService(db *gorm.DB, store, sessionStore *storage.LocalStorage, sessionTTL time.Duration, maxSessions int) *UploadService {
+	return &UploadService{
+		db:          db,
+		storage:     store,
+		sessions:    sessionStore,
+		sessionTTL:  sessionTTL,
+		maxSessions: maxSessions,
+	}
+}
+
+// SaveImage 读取上传的图片（最多MaxUploadSize字节），校验后保存并记录上传者
+// 用户重复上传相同内容时返回已有的记录，existing为true
+func (s *UploadService) SaveImage(ctx context.Context, userID, filename string, r io.Reader) (upload *models.Upload, existing bool, err error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadSize+1))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, false, ErrUploadTooLarge
+		}
+		return nil, false, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if len(data) > MaxUploadSize {
+		return nil, false, ErrUploadTooLarge
+	}
+	return s.createUpload(ctx, userID, filename, data)
+}
+
+// ListUploads 分页获取用户的图片库，按上传时间倒序
+func (s *UploadService) ListUploads(ctx context.Context, userID string, limit, offset int) ([]models.Upload, int64, error) {
+	var total int64
+	query := s.db.WithContext(ctx).Model(&models.Upload{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count uploads: %w", err)
+	}
+
+	var uploads []models.Upload
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&uploads).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list uploads: %w", err)
+	}
+	return uploads, total, nil
+}
+
+// GetUpload 获取用户的单个上传记录
+func (s *UploadService) GetUpload(ctx context.Context, userID, uploadID string) (*models.Upload, error) {
+	var upload models.Upload
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", uploadID, userID).First(&upload).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+	return &upload, nil
+}
+
+// Preview 生成上传图片的预览图，size为最长边像素
+func (s *UploadService) Preview(ctx context.Context, userID, uploadID string, size int) ([]byte, imaging.Format, error) {
+	upload, err := s.GetUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := s.storage.Open(upload.StorageKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: image file is missing", ErrUploadNotFound)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, MaxUploadSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	return imaging.Thumbnail(data, size)
+}
+
+// DeleteUpload 删除用户的上传记录，没有其他记录或进行中的任务引用该文件时同时删除文件
+func (s *UploadService) DeleteUpload(ctx context.Context, userID, uploadID string) error {
+	upload, err := s.GetUpload(ctx, userID, uploadID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("upload_id = ?", upload.ID).Delete(&models.UploadSetView{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(upload).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+
+	// 文件按内容存储，可能被其他用户的上传记录共享
+	var refs int64
+	err = s.db.WithContext(ctx).Model(&models.Upload{}).Where("storage_key = ?", upload.StorageKey).Count(&refs).Error
+	if err != nil || refs > 0 {
+		return err
+	}
+
+	inUse, err := s.usedByActiveJob(ctx, upload.StorageKey)
+	if err != nil || inUse {
+		return err
+	}
+
+	return s.storage.Delete(upload.StorageKey)
+}
+
+// usedByActiveJob 检查文件是否是进行中任务的输入图片，按存储key匹配，地址的主机和签名参数不影响结果
+func (s *UploadService) usedByActiveJob(ctx context.Context, key string) (bool, error) {
+	var jobs []models.GenerationJob
+	err := s.db.WithContext(ctx).Select("id", "image_url", "view_images").
+		Where("status NOT IN ?", []string{"completed", "failed", "cancelled"}).
+		Find(&jobs).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check active jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		if k, ok := s.storage.KeyFromURL(job.ImageURL); ok && k == key {
+			return true, nil
+		}
+		for _, view := range job.ViewImages {
+			if k, ok := s.storage.KeyFromURL(view.ViewImageURL); ok && k == key {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// CheckOwnership 检查本地上传的图片是否属于该用户，外部URL不做检查
+func (s *UploadService) CheckOwnership(ctx context.Context, userID, imageURL string) error {
+	key, ok := s.storage.KeyFromURL(imageURL)
+	if !ok || !strings.HasPrefix(key, "images/") {
+		return nil
+	}
+
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Upload{}).
+		Where("storage_key = ? AND user_id = ?", key, userID).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check upload: %w", err)
+	}
+	if count == 0 {
+		return ErrUploadNotFound
+	}
+	return nil
+}
+
+// createUpload 校验格式和分辨率，去除元数据后按内容哈希保存
+// 同一用户上传相同内容时不新建记录，直接返回已有记录
+func (s *UploadService) createUpload(ctx context.Context, userID, filename string, data []byte) (*models.Upload, bool, error) {
+	cfg, format, err := imaging.DecodeConfig(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if cfg.Width < MinImageDimension || cfg.Height < MinImageDimension ||
+		cfg.Width > MaxImageDimension || cfg.Height > MaxImageDimension {
+		return nil, false, fmt.Errorf("%w: %dx%d, each side must be between %d and %d pixels",
+			ErrImageDimensions, cfg.Width, cfg.Height, MinImageDimension, MaxImageDimension)
+	}
+	if err := imaging.Verify(data); err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrCorruptImage, err)
+	}
+
+	clean, err := imaging.StripMetadata(data, format)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	sum := sha256.Sum256(clean)
+	hash := hex.EncodeToString(sum[:])
+	key := "images/" + hash + format.Ext()
+
+	// 相同内容的文件只保存一份；已有的文件更新修改时间，存储清理从最近一次上传开始计算保留期
+	if err := s.storage.Touch(key); err != nil {
+		if _, err := s.storage.Put(key, bytes.NewReader(clean)); err != nil {
+			return nil, false, fmt.Errorf("failed to save upload: %w", err)
+		}
+	}
+
+	var existing models.Upload
+	err = s.db.WithContext(ctx).Where("user_id = ? AND sha256 = ?", userID, hash).First(&existing).Error
+	if err == nil {
+		return &existing, true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("failed to check existing upload: %w", err)
+	}
+
+	upload := &models.Upload{
+		UserID:       userID,
+		StorageKey:   key,
+		URL:          s.storage.URL(key),
+		OriginalName: sanitizeFilename(filename),
+		ContentType:  format.MIMEType(),
+		Size:         int64(len(clean)),
+		Width:        cfg.Width,
+		Height:       cfg.Height,
+		SHA256:       hash,
+	}
+	if err := s.db.WithContext(ctx).Create(upload).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to create upload record: %w", err)
+	}
+	return upload, false, nil
+}
+
+// sanitizeFilename 只保留文件名部分并去除控制字符，仅用于展示
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	if name == "." || name == "/" || name == "" {
+		return "image"
+	}
+	return name
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"3d-model-generator-backend/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// 断点续传分片大小
+const (
+	DefaultChunkSize = 1024 * 1024
+	MaxChunkSize     = 4 * 1024 * 1024
+)
+
+var (
+	ErrSessionNotFound  = errors.New("upload session not found")
+	ErrOffsetMismatch   = errors.New("upload offset mismatch")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrInvalidChecksum  = errors.New("invalid checksum")
+	ErrUploadIncomplete = errors.New("upload incomplete")
+	ErrChunkTooLarge    = errors.New("chunk exceeds remaining size or chunk limit")
+	ErrTooManySessions  = errors.New("too many open upload sessions")
+	ErrInvalidViewSet   = errors.New("set_id and view_type must be provided together")
+	ErrViewInProgress   = errors.New("another upload for this view is in progress")
+	ErrMissingFrontView = errors.New("upload set has no front view")
+)
+
+// 多视角图片组的视角顺序，front为主图，其余视角对应混元生3D的MultiViewImages
+var viewTypes = []string{"front", "back", "left", "right"}
+
+// CreateSession 创建断点续传会话
+func (s *UploadService) CreateSession(ctx context.Context, userID string, req *models.CreateUploadSessionRequest) (*models.UploadSession, error) {
+	if req.Size > MaxUploadSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	checksum := strings.ToLower(req.SHA256)
+	if checksum != "" {
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("%w: sha256 must be 64 hex characters", ErrInvalidChecksum)
+		}
+	}
+
+	if (req.SetID == "") != (req.ViewType == "") {
+		return nil, ErrInvalidViewSet
+	}
+
+	// 会话ID是访问会话的唯一凭据之一，使用随机值而不是可推测的时间戳
+	id, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+	session := &models.UploadSession{
+		ID:        id,
+		UserID:    userID,
+		Filename:  sanitizeFilename(req.Filename),
+		Size:      req.Size,
+		SHA256:    checksum,
+		SetID:     req.SetID,
+		ViewType:  req.ViewType,
+		ExpiresAt: time.Now().Add(s.sessionTTL),
+	}
+
+	// 同一用户的创建请求串行执行，并发请求不能绕过会话数上限
+	unlock := s.lockSession("user:" + userID)
+	defer unlock()
+
+	now := time.Now()
+	var open int64
+	err = s.db.WithContext(ctx).Model(&models.UploadSession{}).
+		Where("user_id = ? AND expires_at > ?", userID, now).
+		Count(&open).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count upload sessions: %w", err)
+	}
+	if s.maxSessions > 0 && open >= int64(s.maxSessions) {
+		return nil, ErrTooManySessions
+	}
+
+	if session.SetID != "" {
+		var pending int64
+		err = s.db.WithContext(ctx).Model(&models.UploadSession{}).
+			Where("user_id = ? AND set_id = ? AND view_type = ? AND expires_at > ?", userID, session.SetID, session.ViewType, now).
+			Count(&pending).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to check upload sessions: %w", err)
+		}
+		if pending > 0 {
+			return nil, ErrViewInProgress
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+	return session, nil
+}
+
+// GetSession 获取会话状态，已过期的会话视为不存在
+func (s *UploadService) GetSession(ctx context.Context, userID, sessionID string) (*models.UploadSession, error) {
+	var session models.UploadSession
+	err := s.db.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND expires_at > ?", sessionID, userID, time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// AppendChunk 在offset处追加一个分片，checksum为分片的SHA-256摘要
+// offset与服务端记录不一致时返回ErrOffsetMismatch和当前会话，客户端应从会话的offset继续上传
+func (s *UploadService) AppendChunk(ctx context.Context, userID, sessionID string, offset int64, checksum []byte, r io.Reader) (*models.UploadSession, error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return session, ErrOffsetMismatch
+	}
+
+	limit := min(session.Size-session.Offset, int64(MaxChunkSize))
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return session, ErrChunkTooLarge
+	}
+
+	// 校验通过后才写入，损坏的分片不会影响已接收的数据
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], checksum) {
+		return session, ErrChecksumMismatch
+	}
+
+	if len(data) > 0 {
+		if _, err := s.sessions.WriteAt(sessionKey(session.ID), offset, bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to write chunk: %w", err)
+		}
+	}
+
+	session.Offset += int64(len(data))
+	session.ExpiresAt = time.Now().Add(s.sessionTTL)
+	session.UpdatedAt = time.Now()
+	err = s.db.WithContext(ctx).Model(session).Select("offset", "expires_at", "updated_at").Updates(session).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to update upload session: %w", err)
+	}
+	return session, nil
+}
+
+// CompleteSession 校验完整文件并生成与普通上传相同的上传记录
+func (s *UploadService) CompleteSession(ctx context.Context, userID, sessionID string) (upload *models.Upload, existing bool, err error) {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, false, err
+	}
+	if session.Offset != session.Size {
+		return nil, false, fmt.Errorf("%w: received %d of %d bytes", ErrUploadIncomplete, session.Offset, session.Size)
+	}
+
+	data, err := s.readSessionData(session)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if session.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != session.SHA256 {
+			s.removeSession(ctx, session)
+			return nil, false, ErrChecksumMismatch
+		}
+	}
+
+	upload, existing, err = s.createUpload(ctx, userID, session.Filename, data)
+	if err != nil {
+		// 文件本身不合法时重传也无济于事，直接清理会话
+		if errors.Is(err, ErrUnsupportedImage) || errors.Is(err, ErrImageDimensions) {
+			s.removeSession(ctx, session)
+		}
+		return nil, false, err
+	}
+
+	if session.SetID != "" {
+		view := &models.UploadSetView{
+			UserID:   userID,
+			SetID:    session.SetID,
+			ViewType: session.ViewType,
+			UploadID: upload.ID,
+		}
+		// 重新上传同一视角时替换原来的图片
+		err = s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(view).Error
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to add upload to set: %w", err)
+		}
+	}
+
+	s.removeSession(ctx, session)
+	return upload, existing, nil
+}
+
+// GetUploadSet 按视角顺序返回多视角图片组中的图片，ViewImageURL为站内路径
+// 图片组在第一个视角上传完成时创建，不存在或已被清空的组返回ErrUploadNotFound
+func (s *UploadService) GetUploadSet(ctx context.Context, userID, setID string) ([]models.ViewImage, error) {
+	var rows []struct {
+		ViewType string
+		UploadID string
+		URL      string
+	}
+	err := s.db.WithContext(ctx).Model(&models.UploadSetView{}).
+		Select("upload_set_views.view_type, upload_set_views.upload_id, uploads.url").
+		Joins("JOIN uploads ON uploads.id = upload_set_views.upload_id AND uploads.user_id = upload_set_views.user_id").
+		Where("upload_set_views.user_id = ? AND upload_set_views.set_id = ?", userID, setID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload set: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrUploadNotFound
+	}
+
+	views := make([]models.ViewImage, 0, len(rows))
+	for _, viewType := range viewTypes {
+		for _, row := range rows {
+			if row.ViewType == viewType {
+				views = append(views, models.ViewImage{ViewType: row.ViewType, ViewImageURL: row.URL, UploadID: row.UploadID})
+			}
+		}
+	}
+	return views, nil
+}
+
+// DeleteSessionData 删除会话已接收的数据，会话记录由调用方处理
+func (s *UploadService) DeleteSessionData(sessionID string) error {
+	return s.sessions.Delete(sessionKey(sessionID))
+}
+
+// AbortSession 取消会话并删除已接收的数据
+func (s *UploadService) AbortSession(ctx context.Context, userID, sessionID string) error {
+	unlock := s.lockSession(sessionID)
+	defer unlock()
+
+	session, err := s.GetSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	return s.removeSession(ctx, session)
+}
+
+// Start 定时清理过期未完成的会话
+func (s *UploadService) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				count, err := s.CleanupSessions(ctx)
+				if err != nil {
+					log.Printf("Upload session cleanup failed: %v", err)
+					continue
+				}
+				if count > 0 {
+					log.Printf("Removed %d expired upload sessions", count)
+				}
+			}
+		}
+	}()
+}
+
+// CleanupSessions 删除所有已过期的会话及其数据
+func (s *UploadService) CleanupSessions(ctx context.Context) (int, error) {
+	var sessions []models.UploadSession
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Find(&sessions).Error; err != nil {
+		return 0, fmt.Errorf("failed to list expired sessions: %w", err)
+	}
+
+	count := 0
+	for i := range sessions {
+		if err := s.removeSession(ctx, &sessions[i]); err != nil {
+			log.Printf("Failed to remove upload session %s: %v", sessions[i].ID, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *UploadService) readSessionData(session *models.UploadSession) ([]byte, error) {
+	f, err := s.sessions.Open(sessionKey(session.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload data: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, MaxUploadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload data: %w", err)
+	}
+	if int64(len(data)) != session.Size {
+		return nil, fmt.Errorf("%w: stored %d of %d bytes", ErrUploadIncomplete, len(data), session.Size)
+	}
+	return data, nil
+}
+
+func (s *UploadService) removeSession(ctx context.Context, session *models.UploadSession) error {
+	if err := s.DeleteSessionData(session.ID); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Delete(session).Error
+}
+
+func sessionKey(sessionID string) string {
+	return sessionID + ".part"
+}
+
+// lockSession 同一会话的分片必须串行写入
+func (s *UploadService) lockSession(sessionID string) func() {
+	return s.sessionLocks.lock(sessionID)
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStorage 基于本地文件系统的对象存储
+// key 使用"/"分隔的相对路径，例如 "derived/<job_id>/lod_50.obj"
+type LocalStorage struct {
+	root      string
+	urlPrefix string
+}
+
+func NewLocalStorage(root, urlPrefix string) *LocalStorage {
+	return &LocalStorage{
+		root:      root,
+		urlPrefix: strings.TrimRight(urlPrefix, "/"),
+	}
+}
+
+// Put 写入对象，先写临时文件再重命名，避免读到不完整的文件
+func (s *LocalStorage) Put(key string, r io.Reader) (int64, error) {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close object: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return 0, fmt.Errorf("failed to commit object: %w", err)
+	}
+
+	return written, nil
+}
+
+// WriteAt 从offset处写入数据，并截断offset之后原有的内容，用于断点续传
+func (s *LocalStorage) WriteAt(key string, offset int64, r io.Reader) (int64, error) {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open object: %w", err)
+	}
+	defer f.Close()
+
+	// 丢弃上次失败的写入残留在offset之后的数据
+	if err := f.Truncate(offset); err != nil {
+		return 0, fmt.Errorf("failed to truncate object: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek object: %w", err)
+	}
+
+	written, err := io.Copy(f, r)
+	if err != nil {
+		return written, fmt.Errorf("failed to write object: %w", err)
+	}
+	return written, f.Close()
+}
+
+// Open 打开对象
+func (s *LocalStorage) Open(key string) (*os.File, error) {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+// Stat 获取对象信息
+func (s *LocalStorage) Stat(key string) (os.FileInfo, error) {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(fullPath)
+}
+
+// Touch 将对象的修改时间更新为当前时间，对象不存在时返回错误
+// 存储清理按修改时间计算文件的存放时长，被重新引用的文件应当重新计时
+func (s *LocalStorage) Touch(key string) error {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(fullPath, now, now)
+}
+
+// Delete 删除对象，对象不存在时不报错
+func (s *LocalStorage) Delete(key string) error {
+	fullPath, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Walk 遍历指定前缀下的所有对象
+func (s *LocalStorage) Walk(prefix string, fn func(key string, info os.FileInfo) error) error {
+	dir, err := s.resolve(prefix)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info)
+	})
+}
+
+// Path 返回对象在本地磁盘上的路径
+func (s *LocalStorage) Path(key string) (string, error) {
+	return s.resolve(key)
+}
+
+// URL 返回对象的访问路径
+func (s *LocalStorage) URL(key string) string {
+	return s.urlPrefix + "/" + strings.TrimLeft(key, "/")
+}
+
+// KeyFromURL 从访问URL（绝对或相对）中解析出对象key
+func (s *LocalStorage) KeyFromURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	prefix := s.urlPrefix + "/"
+	if !strings.HasPrefix(u.Path, prefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(path.Clean(u.Path), prefix)
+	if key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+// resolve 将key转换为磁盘路径，Clean之后的key不会跳出根目录
+func (s *LocalStorage) resolve(key string) (string, error) {
+	if strings.Contains(key, "\\") || strings.ContainsRune(key, 0) {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	cleaned := path.Clean("/" + key)
+	return filepath.Join(s.root, filepath.FromSlash(strings.TrimPrefix(cleaned, "/"))), nil
+}
@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B的SHA1测试密钥"12345678901234567890"的Base32编码
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 附录B的SHA1测试向量，验证码取8位结果的后6位
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, tt := range rfcVectors {
+		got, err := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	for _, secret := range []string{"", "not base32!", "1"} {
+		if _, err := Code(secret, 1); err != ErrInvalidSecret {
+			t.Errorf("Code(%q) error = %v, want ErrInvalidSecret", secret, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	counter := Counter(now)
+
+	tests := []struct {
+		name    string
+		secret  string
+		code    string
+		want    bool
+		counter int64
+	}{
+		{"current step", rfcSecret, "050471", true, counter},
+		{"previous step within skew", rfcSecret, "081804", true, counter - 1},
+		{"surrounding whitespace", rfcSecret, " 050471\n", true, counter},
+		{"lowercase secret with padding", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", "050471", true, counter},
+		{"step outside skew", rfcSecret, "287082", false, 0},
+		{"wrong code", rfcSecret, "123456", false, 0},
+		{"too short", rfcSecret, "05047", false, 0},
+		{"too long", rfcSecret, "0050471", false, 0},
+		{"invalid secret", "not base32!", "050471", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.want || got != tt.counter {
+				t.Errorf("Validate = (%d, %v), want (%d, %v)", got, ok, tt.counter, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNextStep(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	next, err := Code(rfcSecret, Counter(now)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := Validate(rfcSecret, next, now); !ok || got != Counter(now)+1 {
+		t.Errorf("Validate next step = (%d, %v), want (%d, true)", got, ok, Counter(now)+1)
+	}
+}
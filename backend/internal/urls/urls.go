@@ -0,0 +1,170 @@
+package urls
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Builder 生成对外访问的绝对地址（上传文件、下载链接、邮件和回调中的链接等）
+// 配置了PublicURL时始终使用它；否则根据请求推断，只有来自可信代理的请求才采用X-Forwarded-Proto/X-Forwarded-Host
+type Builder struct {
+	publicURL string // 不带末尾的"/"
+	fallback  string // 未配置PublicURL且没有请求上下文时使用
+	trusted   []netip.Prefix
+}
+
+// NewBuilder publicURL和fallback为"scheme://host[:port][/path]"形式，trustedProxies为IP或CIDR
+func NewBuilder(publicURL, fallback string, trustedProxies []string) (*Builder, error) {
+	b := &Builder{}
+
+	var err error
+	if publicURL != "" {
+		if b.publicURL, err = normalizeBase(publicURL); err != nil {
+			return nil, fmt.Errorf("invalid public url: %w", err)
+		}
+	}
+	if b.fallback, err = normalizeBase(fallback); err != nil {
+		return nil, fmt.Errorf("invalid fallback url: %w", err)
+	}
+
+	for _, proxy := range trustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		b.trusted = append(b.trusted, prefix)
+	}
+	return b, nil
+}
+
+// Base 返回对外访问的根地址，不带末尾的"/"
+// r为nil时（邮件、回调等不在请求上下文中的场景）只使用配置，不会采用任何请求头
+func (b *Builder) Base(r *http.Request) string {
+	if b.publicURL != "" {
+		return b.publicURL
+	}
+	if r == nil {
+		return b.fallback
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if b.trustedProxy(r.RemoteAddr) {
+		if proto := strings.ToLower(firstValue(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := firstValue(r.Header.Get("X-Forwarded-Host")); validHost(forwarded) {
+			host = forwarded
+		}
+	}
+
+	if !validHost(host) {
+		return b.fallback
+	}
+	return scheme + "://" + host
+}
+
+// Absolute 将站内路径转换为绝对地址，已经是绝对地址（例如腾讯云的结果地址）时原样返回
+func (b *Builder) Absolute(r *http.Request, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	if u, err := url.Parse(ref); err == nil && u.IsAbs() {
+		return ref
+	}
+	return b.Base(r) + "/" + strings.TrimLeft(ref, "/")
+}
+
+// Local 判断地址是否指向本服务：站内相对路径，或与配置的根地址（PublicURL，未配置时为fallback）同源且位于其路径下
+// 不采用请求推断的地址，请求头中的Host可以由客户端任意指定
+func (b *Builder) Local(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if !u.IsAbs() && u.Host == "" {
+		return strings.HasPrefix(u.Path, "/")
+	}
+
+	base, err := url.Parse(b.Base(nil))
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) || u.User != nil {
+		return false
+	}
+	return base.Path == "" || u.Path == base.Path || strings.HasPrefix(u.Path, base.Path+"/")
+}
+
+// trustedProxy 判断直接连接的对端是否为可信代理
+func (b *Builder) trustedProxy(remoteAddr string) bool {
+	if len(b.trusted) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range b.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeBase(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("must be scheme://host[:port][/path]")
+	}
+	return u.Scheme + "://" + u.Host + strings.TrimRight(u.EscapedPath(), "/"), nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// firstValue 代理链会追加多个值，取最靠近客户端的第一个
+func firstValue(header string) string {
+	value, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(value)
+}
+
+// validHost 只接受"host[:port]"，防止通过请求头注入路径或其他地址成分
+func validHost(host string) bool {
+	if host == "" || strings.ContainsAny(host, "/\\@?# \t\r\n") {
+		return false
+	}
+	u, err := url.Parse("http://" + host)
+	return err == nil && u.Host == host && u.Hostname() != ""
+}
@@ -55,44 +55,7 @@ func (c *Client) SubmitTextTo3DJob(ctx context.Context, prompt string, options *
 	request := ai3d.NewSubmitHunyuanTo3DJobRequest()
 	request.Prompt = &prompt
 
-	// 设置选项
-	if options != nil {
-		if options.ResultFormat != "" {
-			request.ResultFormat = &options.ResultFormat
-		}
-		request.EnablePBR = &options.EnablePBR
-	}
-
-	// 重试机制
-	var response *ai3d.SubmitHunyuanTo3DJobResponse
-	var err error
-	maxRetries := 3
-
-	for i := 0; i < maxRetries; i++ {
-		// 为每次重试创建新的context，避免context被取消
-		retryCtx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
-		response, err = c.ai3dClient.SubmitHunyuanTo3DJobWithContext(retryCtx, request)
-		cancel()
-
-		if err == nil {
-			break
-		}
-
-		// 如果是最后一次重试，直接返回错误
-		if i == maxRetries-1 {
-			return nil, fmt.Errorf("failed to submit job after %d retries: %w", maxRetries, err)
-		}
-
-		// 等待一段时间后重试
-		time.Sleep(time.Duration(i+1) * 2 * time.Second)
-	}
-
-	return &models.GenerationResponse{
-		JobID:         *response.Response.JobId,
-		Status:        "processing",
-		Message:       "Job submitted successfully",
-		EstimatedTime: 300, // 5分钟
-	}, nil
+	return c.submitJob(request, options)
 }
 
 // SubmitImageTo3DJob 提交图片生成3D任务
@@ -102,12 +65,32 @@ func (c *Client) SubmitImageTo3DJob(ctx context.Context, imageBase64 string, opt
 	request := ai3d.NewSubmitHunyuanTo3DJobRequest()
 	request.ImageBase64 = &imageBase64
 
+	return c.submitJob(request, options)
+}
+
+// SubmitImageURLTo3DJob 通过图片地址提交图片生成3D任务，图片由腾讯云直接下载，地址必须可以从公网访问
+func (c *Client) SubmitImageURLTo3DJob(ctx context.Context, imageURL string, options *GenerationOptions) (*models.GenerationResponse, error) {
+	// 创建请求
+	request := ai3d.NewSubmitHunyuanTo3DJobRequest()
+	request.ImageUrl = &imageURL
+
+	return c.submitJob(request, options)
+}
+
+// submitJob 设置选项并提交任务，失败时重试
+func (c *Client) submitJob(request *ai3d.SubmitHunyuanTo3DJobRequest, options *GenerationOptions) (*models.GenerationResponse, error) {
 	// 设置选项
 	if options != nil {
 		if options.ResultFormat != "" {
 			request.ResultFormat = &options.ResultFormat
 		}
 		request.EnablePBR = &options.EnablePBR
+		for _, view := range options.MultiViewImages {
+			request.MultiViewImages = append(request.MultiViewImages, &ai3d.ViewImage{
+				ViewType:     common.StringPtr(view.ViewType),
+				ViewImageUrl: common.StringPtr(view.ViewImageURL),
+			})
+		}
 	}
 
 	// 重试机制
@@ -230,8 +213,15 @@ const (
 )
 
 type GenerationOptions struct {
-	ResultFormat string
-	EnablePBR    bool
+	ResultFormat    string
+	EnablePBR       bool
+	MultiViewImages []ViewImage // 图片生成时的其他视角图片，地址必须可以从公网访问
+}
+
+// ViewImage 多视角图片，ViewType取值back、left、right
+type ViewImage struct {
+	ViewType     string
+	ViewImageURL string
 }
 
 type File3D struct {